@@ -37,7 +37,8 @@ func main() {
 		}
 	}
 
-	// Versions 1.0-1.22 did not have OpenAPIV3 schemas.
+	// Versions 1.0-1.22 did not have OpenAPIV3 schemas. Their OpenAPIV2
+	// schemas are converted, starting with the oldest version supported.
 	one27Fetcher := openapiclient.NewGitHubBuiltins("1.27")
 	one27Paths, err := one27Fetcher.Paths()
 
@@ -48,7 +49,7 @@ func main() {
 	apiregistrationV1Path := "apis/apiregistration.k8s.io/v1"
 	one27APIRegistrationV1 := one27Paths[apiregistrationV1Path]
 
-	for i := 23; ; i++ {
+	for i := 19; ; i++ {
 		version := fmt.Sprintf("1.%d", i)
 		fetcher := openapiclient.NewGitHubBuiltins(version)
 		// fetcher := openapiclient.NewHardcodedBuiltins(version)
@@ -136,8 +137,4 @@ func main() {
 			}
 		}
 	}
-
-	//TODO: Download OpenAPIV3 schemas and convert to V3?
-	// might be error prone since some (very few like IntOrString) types are
-	// handled differently
 }
@@ -1,25 +1,20 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
 
 	"sigs.k8s.io/kubectl-validate/pkg/cmd"
 )
 
 func main() {
+	// Give up on fetching schemas once interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	rootCmd := cmd.NewRootCommand()
-	if err := rootCmd.Execute(); err != nil {
-		switch err.(type) {
-		case cmd.ValidationError:
-			os.Exit(1)
-		case cmd.ArgumentError:
-			os.Exit(2)
-		case cmd.InternalError:
-			os.Exit(3)
-		default:
-			// This case should not get hit, but in case it does,
-			// Treat unknown error as internal error
-			os.Exit(3)
-		}
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		os.Exit(cmd.ExitCode(err))
 	}
 }
@@ -0,0 +1,32 @@
+// Command kubectl-validate is kubectl-validate built with the checks of
+// native types of k8s.io/kubernetes, which --native-validation runs.
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kubectl-validate/native/pkg/nativevalidation"
+	"sigs.k8s.io/kubectl-validate/pkg/cmd"
+)
+
+func main() {
+	// Give up on fetching schemas once interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	rootCmd := newRootCommand()
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		os.Exit(cmd.ExitCode(err))
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	return cmd.NewNativeRootCommand(cmd.NativeValidation{
+		Version:           nativevalidation.Version,
+		Validate:          nativevalidation.Validate,
+		DeclarativeScheme: nativevalidation.DeclarativeScheme,
+	})
+}
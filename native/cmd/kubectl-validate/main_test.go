@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/kubectl-validate/native/pkg/nativevalidation"
+	"sigs.k8s.io/kubectl-validate/pkg/cmd"
+)
+
+func TestNativeValidationVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "configmap.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata: {name: config}\n"), 0o644))
+
+	run := func(version string) string {
+		var errOut bytes.Buffer
+		rootCmd := newRootCommand()
+		rootCmd.SetArgs([]string{path, "--native-validation", "--version", version, "--cache-dir", ""})
+		rootCmd.SetOut(io.Discard)
+		rootCmd.SetErr(&errOut)
+		require.NoError(t, rootCmd.Execute())
+		return errOut.String()
+	}
+
+	// --version defaults to the release of the checks
+	assert.Equal(t, nativevalidation.Version.String(), newRootCommand().Flags().Lookup("version").DefValue)
+	assert.Contains(t, run("1.24"), "native validation runs the checks of Kubernetes "+nativevalidation.Version.String()+", which may differ from those of 1.24")
+	assert.NotContains(t, run(nativevalidation.Version.String()), "native validation")
+}
+
+func TestDeclarativeValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replicationcontroller.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+apiVersion: v1
+kind: ReplicationController
+metadata: {name: Web_1}
+spec:
+  replicas: -1
+  selector: {app: web}
+  template:
+    metadata: {labels: {app: web}}
+    spec:
+      containers: [{name: web, image: nginx}]
+`), 0o644))
+
+	run := func(version string) []string {
+		var out bytes.Buffer
+		rootCmd := newRootCommand()
+		rootCmd.SetArgs([]string{path, "--version", version, "--output", "json", "--cache-dir", ""})
+		rootCmd.SetOut(&out)
+		rootCmd.SetErr(io.Discard)
+		assert.IsType(t, cmd.ValidationError{}, rootCmd.Execute())
+
+		var res map[string][]metav1.Status
+		require.NoError(t, json.Unmarshal(out.Bytes(), &res))
+		require.Len(t, res[path], 1)
+		var fields []string
+		for _, cause := range res[path][0].Details.Causes {
+			fields = append(fields, cause.Field)
+		}
+		return fields
+	}
+
+	// The declarative rule on the name of the object flags it like the
+	// schema does, and is only reported once
+	assert.Equal(t, []string{"metadata.name", "spec.replicas"}, run(nativevalidation.Version.String()))
+	// Earlier releases do not have the declarative rule on replicas
+	assert.Equal(t, []string{"metadata.name"}, run("1.34"))
+}
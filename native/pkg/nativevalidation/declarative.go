@@ -0,0 +1,61 @@
+package nativevalidation
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/version"
+	appsv1beta1 "k8s.io/kubernetes/pkg/apis/apps/v1beta1"
+	appsv1beta2 "k8s.io/kubernetes/pkg/apis/apps/v1beta2"
+	certificatesv1 "k8s.io/kubernetes/pkg/apis/certificates/v1"
+	certificatesv1alpha1 "k8s.io/kubernetes/pkg/apis/certificates/v1alpha1"
+	certificatesv1beta1 "k8s.io/kubernetes/pkg/apis/certificates/v1beta1"
+	corev1 "k8s.io/kubernetes/pkg/apis/core/v1"
+	rbacv1 "k8s.io/kubernetes/pkg/apis/rbac/v1"
+	rbacv1alpha1 "k8s.io/kubernetes/pkg/apis/rbac/v1alpha1"
+	rbacv1beta1 "k8s.io/kubernetes/pkg/apis/rbac/v1beta1"
+	resourcev1 "k8s.io/kubernetes/pkg/apis/resource/v1"
+	resourcev1beta1 "k8s.io/kubernetes/pkg/apis/resource/v1beta1"
+	resourcev1beta2 "k8s.io/kubernetes/pkg/apis/resource/v1beta2"
+	storagev1 "k8s.io/kubernetes/pkg/apis/storage/v1"
+	storagev1alpha1 "k8s.io/kubernetes/pkg/apis/storage/v1alpha1"
+	storagev1beta1 "k8s.io/kubernetes/pkg/apis/storage/v1beta1"
+)
+
+// DeclarativeScheme has the versioned native types with validation generated
+// from their +k8s: tags in k8s.io/kubernetes, along with their
+// defaults. The declarative rules of autoscaling only cover the scale
+// subresource, which manifests do not have.
+var DeclarativeScheme = func() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{
+		appsv1beta1.AddToScheme,
+		appsv1beta2.AddToScheme,
+		certificatesv1.AddToScheme,
+		certificatesv1alpha1.AddToScheme,
+		certificatesv1beta1.AddToScheme,
+		corev1.AddToScheme,
+		rbacv1.AddToScheme,
+		rbacv1alpha1.AddToScheme,
+		rbacv1beta1.AddToScheme,
+		resourcev1.AddToScheme,
+		resourcev1beta1.AddToScheme,
+		resourcev1beta2.AddToScheme,
+		storagev1.AddToScheme,
+		storagev1alpha1.AddToScheme,
+		storagev1beta1.AddToScheme,
+	} {
+		utilruntime.Must(addToScheme(scheme))
+	}
+	return scheme
+}()
+
+// DeclarativeAppliesTo returns true if the rules of DeclarativeScheme apply
+// to the given Kubernetes release.
+//
+// Rules are only ever added in later releases, and existing objects are
+// exempt from new rules through ratcheting. So the rules of the vendored
+// release are a subset of those of later releases, but may reject objects
+// accepted by earlier ones.
+func DeclarativeAppliesTo(v *version.Version) bool {
+	return v != nil && v.AtLeast(Version)
+}
@@ -0,0 +1,214 @@
+// Package nativevalidation runs the checks the apiserver performs for native
+// types beyond what their OpenAPI schemas describe, such as a workload's
+// selector matching its template's labels.
+//
+// Objects are decoded into the internal types of k8s.io/kubernetes and
+// defaulted like the apiserver does, then checked by the create strategies
+// of its registry, which run the upstream validation in
+// k8s.io/kubernetes/pkg/apis/*/validation. Results are the same as those
+// returned by the apiserver.
+package nativevalidation
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"runtime/debug"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/version"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	baseversion "k8s.io/component-base/version"
+	"k8s.io/kubernetes/pkg/api/legacyscheme"
+	_ "k8s.io/kubernetes/pkg/apis/admissionregistration/install"
+	_ "k8s.io/kubernetes/pkg/apis/apps/install"
+	_ "k8s.io/kubernetes/pkg/apis/autoscaling/install"
+	_ "k8s.io/kubernetes/pkg/apis/batch/install"
+	_ "k8s.io/kubernetes/pkg/apis/coordination/install"
+	_ "k8s.io/kubernetes/pkg/apis/core/install"
+	_ "k8s.io/kubernetes/pkg/apis/discovery/install"
+	_ "k8s.io/kubernetes/pkg/apis/networking/install"
+	_ "k8s.io/kubernetes/pkg/apis/node/install"
+	_ "k8s.io/kubernetes/pkg/apis/policy/install"
+	_ "k8s.io/kubernetes/pkg/apis/rbac/install"
+	_ "k8s.io/kubernetes/pkg/apis/scheduling/install"
+	_ "k8s.io/kubernetes/pkg/apis/storage/install"
+	"k8s.io/kubernetes/pkg/registry/admissionregistration/mutatingwebhookconfiguration"
+	"k8s.io/kubernetes/pkg/registry/admissionregistration/validatingwebhookconfiguration"
+	"k8s.io/kubernetes/pkg/registry/apps/daemonset"
+	"k8s.io/kubernetes/pkg/registry/apps/deployment"
+	"k8s.io/kubernetes/pkg/registry/apps/replicaset"
+	"k8s.io/kubernetes/pkg/registry/apps/statefulset"
+	"k8s.io/kubernetes/pkg/registry/autoscaling/horizontalpodautoscaler"
+	"k8s.io/kubernetes/pkg/registry/batch/cronjob"
+	"k8s.io/kubernetes/pkg/registry/batch/job"
+	"k8s.io/kubernetes/pkg/registry/coordination/lease"
+	"k8s.io/kubernetes/pkg/registry/core/configmap"
+	"k8s.io/kubernetes/pkg/registry/core/limitrange"
+	"k8s.io/kubernetes/pkg/registry/core/namespace"
+	"k8s.io/kubernetes/pkg/registry/core/persistentvolume"
+	"k8s.io/kubernetes/pkg/registry/core/persistentvolumeclaim"
+	"k8s.io/kubernetes/pkg/registry/core/pod"
+	"k8s.io/kubernetes/pkg/registry/core/podtemplate"
+	"k8s.io/kubernetes/pkg/registry/core/replicationcontroller"
+	"k8s.io/kubernetes/pkg/registry/core/resourcequota"
+	"k8s.io/kubernetes/pkg/registry/core/secret"
+	"k8s.io/kubernetes/pkg/registry/core/service"
+	"k8s.io/kubernetes/pkg/registry/core/serviceaccount"
+	"k8s.io/kubernetes/pkg/registry/discovery/endpointslice"
+	"k8s.io/kubernetes/pkg/registry/networking/ingress"
+	"k8s.io/kubernetes/pkg/registry/networking/ingressclass"
+	"k8s.io/kubernetes/pkg/registry/networking/networkpolicy"
+	"k8s.io/kubernetes/pkg/registry/node/runtimeclass"
+	"k8s.io/kubernetes/pkg/registry/policy/poddisruptionbudget"
+	"k8s.io/kubernetes/pkg/registry/rbac/clusterrole"
+	"k8s.io/kubernetes/pkg/registry/rbac/clusterrolebinding"
+	"k8s.io/kubernetes/pkg/registry/rbac/role"
+	"k8s.io/kubernetes/pkg/registry/rbac/rolebinding"
+	"k8s.io/kubernetes/pkg/registry/scheduling/priorityclass"
+	"k8s.io/kubernetes/pkg/registry/storage/storageclass"
+)
+
+// Version is the Kubernetes release the checks are those of, that of the
+// k8s.io/kubernetes module the binary is built with. Binaries without module
+// information fall back to the release of k8s.io/component-base, which is
+// pinned to the same one.
+var Version = func() *version.Version {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path != "k8s.io/kubernetes" {
+				continue
+			}
+			if v, err := version.ParseSemantic(dep.Version); err == nil {
+				return version.MajorMinor(v.Major(), v.Minor())
+			}
+		}
+	}
+	return version.MustParseGeneric(baseversion.DefaultKubeBinaryVersion)
+}()
+
+// AppliesTo returns true if the checks are those of the given release.
+// Other releases may reject objects the checks accept, or the other way
+// around.
+func AppliesTo(v *version.Version) bool {
+	return v != nil && v.Major() == Version.Major() && v.Minor() == Version.Minor()
+}
+
+// Strategies the apiserver creates objects of each native kind with
+var strategies = map[schema.GroupKind]rest.RESTCreateStrategy{
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}:   mutatingwebhookconfiguration.Strategy,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}: validatingwebhookconfiguration.Strategy,
+
+	{Group: "apps", Kind: "DaemonSet"}:   daemonset.Strategy,
+	{Group: "apps", Kind: "Deployment"}:  deployment.Strategy,
+	{Group: "apps", Kind: "ReplicaSet"}:  replicaset.Strategy,
+	{Group: "apps", Kind: "StatefulSet"}: statefulset.Strategy,
+
+	{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}: horizontalpodautoscaler.Strategy,
+
+	{Group: "batch", Kind: "CronJob"}: cronjob.Strategy,
+	{Group: "batch", Kind: "Job"}:     job.Strategy,
+
+	{Group: "coordination.k8s.io", Kind: "Lease"}: lease.Strategy,
+
+	{Kind: "ConfigMap"}:             configmap.Strategy,
+	{Kind: "LimitRange"}:            limitrange.Strategy,
+	{Kind: "Namespace"}:             namespace.Strategy,
+	{Kind: "PersistentVolume"}:      persistentvolume.Strategy,
+	{Kind: "PersistentVolumeClaim"}: persistentvolumeclaim.Strategy,
+	{Kind: "Pod"}:                   pod.Strategy,
+	{Kind: "PodTemplate"}:           podtemplate.Strategy,
+	{Kind: "ReplicationController"}: replicationcontroller.Strategy,
+	{Kind: "ResourceQuota"}:         resourcequota.Strategy,
+	{Kind: "Secret"}:                secret.Strategy,
+	{Kind: "Service"}:               service.Strategy,
+	{Kind: "ServiceAccount"}:        serviceaccount.Strategy,
+
+	{Group: "discovery.k8s.io", Kind: "EndpointSlice"}: endpointslice.Strategy,
+
+	{Group: "networking.k8s.io", Kind: "Ingress"}:       ingress.Strategy,
+	{Group: "networking.k8s.io", Kind: "IngressClass"}:  ingressclass.Strategy,
+	{Group: "networking.k8s.io", Kind: "NetworkPolicy"}: networkpolicy.Strategy,
+
+	{Group: "node.k8s.io", Kind: "RuntimeClass"}: runtimeclass.Strategy,
+
+	{Group: "policy", Kind: "PodDisruptionBudget"}: poddisruptionbudget.Strategy,
+
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:        clusterrole.Strategy,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}: clusterrolebinding.Strategy,
+	{Group: "rbac.authorization.k8s.io", Kind: "Role"}:               role.Strategy,
+	{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}:        rolebinding.Strategy,
+
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}: priorityclass.Strategy,
+
+	{Group: "storage.k8s.io", Kind: "StorageClass"}: storageclass.Strategy,
+}
+
+// decoder reads objects into their internal type, applying the defaults of
+// their version first
+var decoder = legacyscheme.Codecs.UniversalDecoder()
+
+// Supports returns true if there are checks for the GVK
+func Supports(gvk schema.GroupVersionKind) bool {
+	_, ok := strategies[gvk.GroupKind()]
+	return ok && legacyscheme.Scheme.Recognizes(gvk)
+}
+
+// Validate runs the checks the apiserver performs on creation of objects of
+// the native type of the given GVK against obj, with the deadline and values
+// of ctx. Objects of types without checks return no errors.
+func Validate(ctx context.Context, gvk schema.GroupVersionKind, obj *unstructured.Unstructured) (field.ErrorList, error) {
+	if !Supports(gvk) {
+		return nil, nil
+	}
+	strategy := strategies[gvk.GroupKind()]
+
+	// The object may have been adapted to the strategy of custom resources,
+	// such as core types getting a "core" group
+	obj = &unstructured.Unstructured{Object: maps.Clone(obj.UnstructuredContent())}
+	obj.SetGroupVersionKind(gvk)
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	internal, _, err := decoder.Decode(data, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %v to its native type: %w", gvk, err)
+	}
+	accessor, err := meta.Accessor(internal)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like the apiserver on create, take the namespace of the request, which
+	// defaults to that of the kubeconfig context when applying a manifest,
+	// and generate the name and system fields such as the UID
+	rest.FillObjectMetaSystemFields(accessor)
+	if !strategy.NamespaceScoped() {
+		accessor.SetNamespace(metav1.NamespaceNone)
+	} else if accessor.GetNamespace() == "" {
+		accessor.SetNamespace(metav1.NamespaceDefault)
+	}
+	if accessor.GetName() == "" && accessor.GetGenerateName() != "" {
+		accessor.SetName(strategy.GenerateName(accessor.GetGenerateName()))
+	}
+
+	resource, _ := meta.UnsafeGuessKindToResource(gvk)
+	ctx = genericapirequest.WithNamespace(ctx, accessor.GetNamespace())
+	ctx = genericapirequest.WithRequestInfo(ctx, &genericapirequest.RequestInfo{
+		IsResourceRequest: true,
+		Verb:              "create",
+		APIGroup:          gvk.Group,
+		APIVersion:        gvk.Version,
+		Namespace:         accessor.GetNamespace(),
+		Resource:          resource.Resource,
+		Name:              accessor.GetName(),
+	})
+	strategy.PrepareForCreate(ctx, internal)
+	return strategy.Validate(ctx, internal), nil
+}
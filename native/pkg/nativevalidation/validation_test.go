@@ -0,0 +1,327 @@
+package nativevalidation
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/version"
+	baseversion "k8s.io/component-base/version"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+	"sigs.k8s.io/kubectl-validate/pkg/validator"
+	"sigs.k8s.io/kubectl-validate/pkg/validator/validatortest"
+	"sigs.k8s.io/yaml"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     []string
+	}{{
+		name: "valid deployment",
+		document: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web}
+spec:
+  selector: {matchLabels: {app: web}}
+  template:
+    metadata: {labels: {app: web, tier: frontend}}
+    spec:
+      containers:
+      - name: web
+        image: nginx
+        ports: [{name: http, containerPort: 80}]
+        livenessProbe: {httpGet: {port: http}}
+`,
+	}, {
+		name: "deployment",
+		document: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web}
+spec:
+  selector: {matchLabels: {app: web}}
+  strategy:
+    type: RollingUpdate
+    rollingUpdate: {maxSurge: 0, maxUnavailable: "0%"}
+  template:
+    metadata: {labels: {app: other}}
+    spec:
+      containers:
+      - name: web
+        image: nginx
+        ports: [{name: http, containerPort: 80}, {name: http, containerPort: 81}]
+        livenessProbe: {httpGet: {port: 80}, exec: {command: ["true"]}, successThreshold: 2}
+        volumeMounts: [{name: data, mountPath: /data}]
+        resources:
+          requests: {cpu: "2"}
+          limits: {cpu: "1"}
+      - name: web
+        image: nginx
+`,
+		want: []string{
+			"spec.template.metadata.labels: Invalid value",
+			"spec.template.spec.containers[0].ports[1].name: Duplicate value",
+			"spec.template.spec.containers[0].volumeMounts[0].name: Not found",
+			"spec.template.spec.containers[0].resources.requests: Invalid value",
+			"spec.template.spec.containers[0].livenessProbe.httpGet: Forbidden",
+			"spec.template.spec.containers[0].livenessProbe.successThreshold: Invalid value",
+			"spec.template.spec.containers[1].name: Duplicate value",
+			"spec.strategy.rollingUpdate.maxUnavailable: Invalid value",
+		},
+	}, {
+		name: "host network ports",
+		document: `
+apiVersion: v1
+kind: Pod
+metadata: {name: pod}
+spec:
+  hostNetwork: true
+  containers:
+  - {name: a, image: nginx, ports: [{containerPort: 80}]}
+  - {name: b, image: nginx, ports: [{containerPort: 80}]}
+  initContainers:
+  - {name: init, image: busybox, readinessProbe: {exec: {command: ["true"]}}}
+`,
+		want: []string{
+			"spec.containers[1].ports[0].hostPort: Duplicate value",
+			"spec.initContainers[0].readinessProbe: Forbidden",
+		},
+	}, {
+		name: "job",
+		document: `
+apiVersion: batch/v1
+kind: Job
+metadata: {name: job}
+spec:
+  template:
+    spec:
+      restartPolicy: Always
+      containers: [{name: job, image: busybox}]
+`,
+		want: []string{"spec.template.spec.restartPolicy: Required value"},
+	}, {
+		name: "ingress",
+		document: `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata: {name: web}
+spec:
+  rules:
+  - host: 10.0.0.1
+    http:
+      paths:
+      - path: api
+        pathType: Prefix
+        backend: {service: {name: api, port: {number: 80, name: http}}}
+`,
+		want: []string{
+			"spec.rules[0].host: Invalid value",
+			"spec.rules[0].http.paths[0].path: Invalid value",
+			"spec.rules[0].http.paths[0].backend: Invalid value",
+		},
+	}, {
+		name: "pdb",
+		document: `
+apiVersion: policy/v1
+kind: PodDisruptionBudget
+metadata: {name: pdb}
+spec:
+  minAvailable: 1
+  maxUnavailable: 150%
+`,
+		want: []string{
+			"spec: Invalid value",
+			"spec.maxUnavailable: Invalid value",
+		},
+	}, {
+		name: "cluster role binding",
+		document: `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata: {name: binding}
+roleRef: {apiGroup: rbac.authorization.k8s.io, kind: Role, name: role}
+subjects:
+- {kind: ServiceAccount, name: default}
+- {kind: Robot, name: r2d2}
+`,
+		want: []string{
+			"roleRef.kind: Unsupported value",
+			"subjects[0].namespace: Required value",
+			"subjects[1].kind: Unsupported value",
+		},
+	}, {
+		name: "role",
+		document: `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata: {name: role}
+rules:
+- {verbs: [get], nonResourceURLs: [/healthz]}
+- {verbs: [], resources: [pods]}
+`,
+		want: []string{
+			"rules[0].nonResourceURLs: Invalid value",
+			"rules[1].verbs: Required value",
+			"rules[1].apiGroups: Required value",
+		},
+	}, {
+		name: "hpa",
+		document: `
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata: {name: hpa}
+spec:
+  scaleTargetRef: {apiVersion: apps/v1, kind: Deployment, name: web}
+  minReplicas: 5
+  maxReplicas: 2
+  metrics:
+  - type: Resource
+    resource: {name: cpu, target: {type: Value, value: "1"}}
+`,
+		want: []string{
+			"spec.maxReplicas: Invalid value",
+			"spec.metrics[0].resource.target.averageUtilization: Required value",
+		},
+	}, {
+		name: "namespaced without namespace and generated name",
+		document: `
+apiVersion: v1
+kind: ConfigMap
+metadata: {generateName: config-}
+`,
+	}, {
+		name: "custom resource",
+		document: `
+apiVersion: stable.example.com/v1
+kind: CronTab
+metadata: {name: crontab}
+`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{}
+			require.NoError(t, yaml.Unmarshal([]byte(tt.document), &obj.Object))
+
+			errs, err := Validate(context.Background(), obj.GroupVersionKind(), obj)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, summarize(errs))
+		})
+	}
+}
+
+func TestValidateWorkloadMessages(t *testing.T) {
+	for _, kind := range []string{"DaemonSet", "ReplicaSet", "StatefulSet"} {
+		t.Run(kind, func(t *testing.T) {
+			obj := &unstructured.Unstructured{}
+			require.NoError(t, yaml.Unmarshal([]byte(`
+apiVersion: apps/v1
+kind: `+kind+`
+metadata: {name: web}
+spec:
+  selector: {matchLabels: {app: web}}
+  template:
+    metadata: {labels: {app: web}}
+    spec:
+      activeDeadlineSeconds: 10
+      containers: [{name: web, image: nginx}]
+`), &obj.Object))
+
+			errs, err := Validate(context.Background(), obj.GroupVersionKind(), obj)
+			require.NoError(t, err)
+			require.Len(t, errs, 1)
+			assert.Equal(t, "activeDeadlineSeconds in "+kind+" is not Supported", errs[0].Detail)
+		})
+	}
+}
+
+func summarize(errs field.ErrorList) []string {
+	var res []string
+	for _, err := range errs {
+		res = append(res, err.Field+": "+err.Type.String())
+	}
+	return res
+}
+
+func TestVersion(t *testing.T) {
+	goMod, err := os.ReadFile("../../go.mod")
+	require.NoError(t, err)
+	match := regexp.MustCompile(`k8s.io/kubernetes v(\d+\.\d+)\.`).FindSubmatch(goMod)
+	require.NotNil(t, match)
+	assert.Equal(t, string(match[1]), Version.String(), "Version is not that of k8s.io/kubernetes")
+	assert.Equal(t, baseversion.DefaultKubeBinaryVersion, Version.String(), "k8s.io/component-base is not of the release of k8s.io/kubernetes")
+
+	assert.True(t, AppliesTo(version.MustParseGeneric(Version.String()+".3")))
+	assert.False(t, AppliesTo(version.MustParseGeneric("1.24")))
+	assert.False(t, AppliesTo(nil))
+}
+
+func TestDeclarativeScheme(t *testing.T) {
+	v, err := validator.New(openapiclient.NewHardcodedBuiltins("1.30"), validator.WithDeclarativeValidation(DeclarativeScheme))
+	require.NoError(t, err)
+	validate := func(document string) []string {
+		_, obj, err := v.Parse([]byte(document))
+		require.NoError(t, err)
+		var statusErr *k8serrors.StatusError
+		if !errors.As(v.Validate(obj), &statusErr) {
+			return nil
+		}
+		var res []string
+		for _, cause := range statusErr.ErrStatus.Details.Causes {
+			res = append(res, cause.Field+": "+string(cause.Type))
+		}
+		return res
+	}
+
+	assert.Equal(t, []string{"metadata.name: FieldValueInvalid", "spec.replicas: FieldValueInvalid"}, validate(`
+apiVersion: v1
+kind: ReplicationController
+metadata: {name: Web_1}
+spec:
+  replicas: -1
+  selector: {app: web}
+`))
+	// Unset replicas are defaulted rather than missing
+	assert.Empty(t, validate(`
+apiVersion: v1
+kind: ReplicationController
+metadata: {name: web}
+spec:
+  selector: {app: web}
+`))
+
+	assert.True(t, DeclarativeAppliesTo(version.MajorMinor(Version.Major(), Version.Minor()+1)))
+	assert.False(t, DeclarativeAppliesTo(version.MustParseGeneric("1.34")))
+}
+
+func TestValidatorOption(t *testing.T) {
+	v := validatortest.New(t, openapiclient.NewHardcodedBuiltins(Version.String()), validator.WithNativeValidation(Validate))
+
+	labels := map[string]string{"app": "web"}
+	obj := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To[int32](-1),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "nginx"}}},
+			},
+		},
+	}
+	v.AssertInvalid(t, obj, "spec.replicas")
+	v.AssertInvalid(t, obj, "spec.template.metadata.labels")
+}
@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/kubectl-validate/pkg/utils"
+	"sigs.k8s.io/kubectl-validate/pkg/validator"
+	"sigs.k8s.io/yaml"
+)
+
+// projectConfigFile is the name of the project configuration, looked up in
+// the directories of the manifests and above them
+const projectConfigFile = ".kubectl-validate.yaml"
+
+// projectConfig is the project configuration, which maps manifests to the
+// profiles to validate them with
+type projectConfig struct {
+	// Profiles by name
+	Profiles map[string]profileConfig `json:"profiles,omitempty"`
+	// Rules mapping manifests to profiles, the first matching rule winning.
+	// Manifests matching no rule are validated as configured by flags.
+	Rules []profileRule `json:"rules,omitempty"`
+
+	// Path of the configuration
+	path string
+}
+
+// profileConfig configures the validation of the manifests of a profile.
+// Unset fields are taken from flags.
+type profileConfig struct {
+	// Kubernetes version to validate against
+	Version string `json:"version,omitempty"`
+	// Sources of schemas in priority order, as declared in --schema-sources
+	Sources []schemaSourceConfig `json:"sources,omitempty"`
+	// Directory of schema patches, as passed to --schema-patches
+	Patches string `json:"patches,omitempty"`
+	// How objects with unknown or duplicate fields are treated: Strict,
+	// Warn or Ignore
+	FieldValidation validator.FieldValidation `json:"fieldValidation,omitempty"`
+	// Kinds whose objects are skipped, such as Secret or Widget.example.com
+	IgnoreKinds []string `json:"ignoreKinds,omitempty"`
+}
+
+type profileRule struct {
+	// Globs of the paths of manifests, relative to the configuration. "**"
+	// matches any number of directories.
+	Paths   []string `json:"paths"`
+	Profile string   `json:"profile"`
+}
+
+// loadProjectConfig reads and checks the project configuration at path
+func loadProjectConfig(path string) (*projectConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project configuration: %w", err)
+	}
+	res := &projectConfig{}
+	if err := yaml.UnmarshalStrict(data, res); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	res.path = path
+
+	for name, profile := range res.Profiles {
+		switch profile.FieldValidation {
+		case "", validator.FieldValidationStrict, validator.FieldValidationWarn, validator.FieldValidationIgnore:
+		default:
+			return nil, fmt.Errorf("profile %s of %s has invalid fieldValidation %q, expected one of %s, %s or %s", name, path, profile.FieldValidation,
+				validator.FieldValidationStrict, validator.FieldValidationWarn, validator.FieldValidationIgnore)
+		}
+	}
+	for i, rule := range res.Rules {
+		if _, ok := res.Profiles[rule.Profile]; !ok {
+			return nil, fmt.Errorf("rule %d of %s refers to unknown profile %q", i, path, rule.Profile)
+		} else if len(rule.Paths) == 0 {
+			return nil, fmt.Errorf("rule %d of %s must have paths", i, path)
+		}
+		for _, pattern := range rule.Paths {
+			if _, err := utils.MatchGlob(pattern, ""); err != nil {
+				return nil, fmt.Errorf("rule %d of %s has invalid path %q: %w", i, path, pattern, err)
+			}
+		}
+	}
+	return res, nil
+}
+
+// findProjectConfig returns the path of the project configuration in dir or
+// the closest of its parents, or an empty string if there is none
+func findProjectConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, projectConfigFile)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// profileFor returns the name of the profile of the manifest at path, or an
+// empty string if no rule matches it
+func (p *projectConfig) profileFor(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(filepath.Dir(p.path), abs)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", nil
+	}
+	rel = filepath.ToSlash(rel)
+	for _, rule := range p.Rules {
+		for _, pattern := range rule.Paths {
+			// Patterns were checked when loading the configuration
+			if ok, _ := utils.MatchGlob(pattern, rel); ok {
+				return rule.Profile, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// withProfile returns the flags to validate the manifests of the named
+// profile of config with
+func (c *commandFlags) withProfile(config *projectConfig, name string) (*commandFlags, error) {
+	profile := config.Profiles[name]
+	dir := filepath.Dir(config.path)
+	if c.lockValidators == nil {
+		// Shared with the flags of every profile
+		c.lockValidators = map[string]*validator.Validator{}
+	}
+	res := *c
+	res.profile = name
+	if profile.Version != "" {
+		res.version = profile.Version
+	}
+	if len(profile.Sources) > 0 {
+		res.schemaSourcesFile = config.path
+		res.declaredSources = profile.Sources
+		res.declaredSourcesField = "profiles." + name + ".sources"
+	}
+	if profile.Patches != "" {
+		res.schemaPatchesDir = profile.Patches
+		if !filepath.IsAbs(profile.Patches) {
+			res.schemaPatchesDir = filepath.Join(dir, profile.Patches)
+		}
+	}
+	if profile.FieldValidation != "" {
+		res.fieldValidation = profile.FieldValidation
+	}
+	if len(profile.IgnoreKinds) > 0 {
+		ignoreKinds, err := parseKinds(profile.IgnoreKinds)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s of %s has invalid ignoreKinds: %w", name, config.path, err)
+		}
+		res.ignoreKinds = ignoreKinds
+		res.ignoreKindsByProfile = true
+	}
+	return &res, nil
+}
+
+// fileProfile is how a manifest is validated
+type fileProfile struct {
+	flags     *commandFlags
+	validator *validator.Validator
+}
+
+// profileRef is the profile of a manifest, whose config is nil and name is
+// empty if it is validated as configured by flags
+type profileRef struct {
+	config *projectConfig
+	name   string
+}
+
+// profilesOf returns the profile of each of the files, from --config or the
+// project configuration closest to them
+func (c *commandFlags) profilesOf(files []string) (map[string]profileRef, error) {
+	res := map[string]profileRef{}
+	if c.noConfig {
+		return res, nil
+	}
+	configs := map[string]*projectConfig{}
+	load := func(path string) (*projectConfig, error) {
+		if config, ok := configs[path]; ok || path == "" {
+			return config, nil
+		}
+		config, err := loadProjectConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		configs[path] = config
+		return config, nil
+	}
+	// Configurations found by directory
+	found := map[string]string{}
+	for _, file := range files {
+		path := c.configFile
+		if path == "" {
+			dir := filepath.Dir(file)
+			if file == stdinPath {
+				dir = "."
+			}
+			var ok bool
+			if path, ok = found[dir]; !ok {
+				var err error
+				if path, err = findProjectConfig(dir); err != nil {
+					return nil, err
+				}
+				found[dir] = path
+			}
+		}
+		config, err := load(path)
+		if err != nil {
+			return nil, err
+		}
+		ref := profileRef{config: config}
+		if config != nil && file != stdinPath {
+			if ref.name, err = config.profileFor(file); err != nil {
+				return nil, err
+			}
+		}
+		res[file] = ref
+	}
+	return res, nil
+}
+
+// fileProfiles returns how each of the files is validated, building a
+// validator for each profile used
+func (c *commandFlags) fileProfiles(ctx context.Context, refs map[string]profileRef, files []string) (map[string]*fileProfile, error) {
+	byProfile := map[profileRef]*fileProfile{}
+	res := map[string]*fileProfile{}
+	for _, file := range files {
+		ref := refs[file]
+		if ref.name == "" {
+			// Configurations only matter through their profiles
+			ref.config = nil
+		}
+		profile, ok := byProfile[ref]
+		if !ok {
+			flags := c
+			if ref.name != "" {
+				var err error
+				if flags, err = c.withProfile(ref.config, ref.name); err != nil {
+					return nil, err
+				}
+			}
+			v, err := flags.newValidator(ctx, flags.version)
+			if err != nil {
+				if ref.name != "" {
+					err = fmt.Errorf("profile %s of %s: %w", ref.name, ref.config.path, err)
+				}
+				return nil, err
+			}
+			profile = &fileProfile{flags: flags, validator: v}
+			byProfile[ref] = profile
+		}
+		res[file] = profile
+	}
+	return res, nil
+}
@@ -11,3 +11,22 @@ type ValidationError struct {
 type InternalError struct {
 	error
 }
+
+// ExitCode returns the code the binary exits with after the root command
+// returned err
+func ExitCode(err error) int {
+	switch err.(type) {
+	case nil:
+		return 0
+	case ValidationError:
+		return 1
+	case ArgumentError:
+		return 2
+	case InternalError:
+		return 3
+	default:
+		// This case should not get hit, but in case it does,
+		// Treat unknown error as internal error
+		return 3
+	}
+}
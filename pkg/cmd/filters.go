@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kubectl-validate/pkg/validator"
+	"sigs.k8s.io/yaml"
+)
+
+// parseKinds parses kinds, optionally qualified by their group such as
+// Widget.example.com
+func parseKinds(kinds []string) ([]schema.GroupKind, error) {
+	var res []schema.GroupKind
+	for _, kind := range kinds {
+		gk := schema.ParseGroupKind(kind)
+		if gk.Kind == "" || strings.Contains(gk.Kind, "/") {
+			return nil, fmt.Errorf("invalid kind %q, expected a kind such as Secret or Widget.example.com", kind)
+		}
+		res = append(res, gk)
+	}
+	return res, nil
+}
+
+// matchesKinds returns true if the GVK is one of kinds
+func matchesKinds(kinds []schema.GroupKind, gvk schema.GroupVersionKind) bool {
+	for _, gk := range kinds {
+		// Kinds without a group match in any group
+		if gk.Kind == gvk.Kind && (gk.Group == "" || gk.Group == gvk.Group) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseObjectFilters parses the flags filtering the objects validated
+func (c *commandFlags) parseObjectFilters() error {
+	var err error
+	if c.kinds, err = parseKinds(c.kindsArg); err != nil {
+		return fmt.Errorf("invalid --kinds: %w", err)
+	}
+	if c.skipKinds, err = parseKinds(c.skipKindsArg); err != nil {
+		return fmt.Errorf("invalid --skip-kinds: %w", err)
+	}
+	if c.ignoreKinds, err = parseKinds(c.ignoreKindsArg); err != nil {
+		return fmt.Errorf("invalid --ignore-kinds: %w", err)
+	}
+	c.selector = nil
+	if c.selectorArg != "" {
+		if c.selector, err = labels.Parse(c.selectorArg); err != nil {
+			return fmt.Errorf("invalid --selector: %w", err)
+		}
+	}
+	return nil
+}
+
+// skipReason returns why the document is skipped by the filters of objects
+// or by its profile, or an empty string if it is validated. Documents which
+// cannot be parsed are validated, to report why.
+func (c *commandFlags) skipReason(document []byte) string {
+	if document == nil || (len(c.ignoreKinds) == 0 && len(c.kinds) == 0 && len(c.skipKinds) == 0 && c.namespace == "" && c.selector == nil) {
+		return ""
+	}
+	var partial struct {
+		metav1.TypeMeta `json:",inline"`
+		Metadata        struct {
+			Namespace string            `json:"namespace"`
+			Labels    map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	if yaml.Unmarshal(document, &partial) != nil {
+		return ""
+	}
+	gvk := partial.GroupVersionKind()
+	if gvk.Empty() {
+		return ""
+	}
+	switch {
+	case matchesKinds(c.ignoreKinds, gvk) && c.ignoreKindsByProfile:
+		return fmt.Sprintf("kind %s is ignored by profile %s", gvk.GroupKind(), c.profile)
+	case matchesKinds(c.ignoreKinds, gvk):
+		return fmt.Sprintf("kind %s is ignored by --ignore-kinds", gvk.GroupKind())
+	case len(c.kinds) > 0 && !matchesKinds(c.kinds, gvk):
+		return fmt.Sprintf("kind %s is not one of --kinds", gvk.GroupKind())
+	case matchesKinds(c.skipKinds, gvk):
+		return fmt.Sprintf("kind %s is skipped by --skip-kinds", gvk.GroupKind())
+	// Objects without a namespace are in that of wherever they are applied
+	case c.namespace != "" && partial.Metadata.Namespace != "" && partial.Metadata.Namespace != c.namespace:
+		return fmt.Sprintf("namespace %s is not --namespace %s", partial.Metadata.Namespace, c.namespace)
+	case c.selector != nil && !c.selector.Matches(labels.Set(partial.Metadata.Labels)):
+		return fmt.Sprintf("labels do not match --selector %s", c.selector)
+	}
+	return ""
+}
+
+// Policies of --missing-schemas and --non-k8s-documents
+const (
+	policyError = "error"
+	policyWarn  = "warn"
+	policySkip  = "skip"
+)
+
+func (c *commandFlags) checkDocumentPolicies() error {
+	switch c.missingSchemas {
+	case policyError, policyWarn, policySkip:
+	default:
+		return fmt.Errorf("invalid --missing-schemas %q, expected one of %s, %s or %s", c.missingSchemas, policyError, policyWarn, policySkip)
+	}
+	switch c.nonK8sDocuments {
+	case policyError, policySkip:
+	default:
+		return fmt.Errorf("invalid --non-k8s-documents %q, expected one of %s or %s", c.nonK8sDocuments, policyError, policySkip)
+	}
+	return nil
+}
+
+// applyDocumentPolicies skips the objects of kinds no schema is known for and
+// the documents which are not Kubernetes objects, if --missing-schemas or
+// --non-k8s-documents tolerate them. They keep their category.
+//
+// Only objects of group versions no schema is known for at all are
+// tolerated, such as custom resources whose CRDs were not supplied. Unknown
+// kinds of known group versions, such as a misspelled v1 ConfigMapp, and
+// objects something is suggested for, such as an Ingress of a group version
+// it is not served in, are mistakes which still fail.
+func (c *commandFlags) applyDocumentPolicies(res Result) Result {
+	var notFound *validator.SchemaNotFoundError
+	switch {
+	case res.Category == CategoryMissingSchema && c.missingSchemas != policyError && errors.As(res.err, &notFound) &&
+		notFound.GroupVersionNotFound && notFound.Suggestion == "":
+		res.Skipped = notFound.Error()
+		if c.missingSchemas == policyWarn {
+			res.addWarning(metav1.StatusCause{Type: CauseTypeMissingSchema, Message: res.Skipped})
+		}
+	case res.Category == CategoryNotKubernetesObject && c.nonK8sDocuments == policySkip:
+		res.Skipped = "not a Kubernetes object, it has no apiVersion and kind"
+	default:
+		return res
+	}
+	res.Errors, res.err = nil, nil
+	return res
+}
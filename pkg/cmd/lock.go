@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/kubectl-validate/pkg/validator"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultLockFile = "kubectl-validate.lock"
+	lockAPIVersion  = "kubectl-validate.sigs.k8s.io/v1alpha1"
+	lockKind        = "SchemaLock"
+	lockHeader      = "# Written by kubectl-validate --write-lock. Validation with --locked fails\n# if the schemas of these group versions change.\n"
+)
+
+// schemaLock is the file written by --write-lock and enforced by --locked
+type schemaLock struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// Documents of the group versions used for each Kubernetes version
+	// validated against, qualified by their profile if any, keyed by the path
+	// of their group version
+	Versions map[string]map[string]validator.LockedDocument `json:"versions"`
+}
+
+// lockOptions returns the options locking the documents of the validator for
+// the given Kubernetes version, if --locked
+func (c *commandFlags) lockOptions(k8sVersion string) ([]validator.Option, error) {
+	if c.writeLock && c.locked {
+		return nil, errors.New("--write-lock and --locked cannot be used together")
+	} else if !c.locked {
+		return nil, nil
+	}
+	data, err := os.ReadFile(c.lockFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file, write it with --write-lock: %w", err)
+	}
+	var lock schemaLock
+	if err := yaml.UnmarshalStrict(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %s: %w", c.lockFile, err)
+	} else if lock.APIVersion != lockAPIVersion || lock.Kind != lockKind {
+		return nil, fmt.Errorf("lock file %s is a %s %s, expected %s %s", c.lockFile, lock.APIVersion, lock.Kind, lockAPIVersion, lockKind)
+	}
+	return []validator.Option{validator.WithLockedDocuments(lock.Versions[c.lockKey(k8sVersion)])}, nil
+}
+
+// lockKey returns the key of the documents of the validator for the given
+// Kubernetes version in the lock file, which is qualified by the profile of
+// the project configuration validated with, if any
+func (c *commandFlags) lockKey(k8sVersion string) string {
+	if c.profile == "" {
+		return k8sVersion
+	}
+	return c.profile + "/" + k8sVersion
+}
+
+// recordLock remembers the validator for the given Kubernetes version, to
+// write the documents it used to the lock file if --write-lock
+func (c *commandFlags) recordLock(k8sVersion string, v *validator.Validator) {
+	if !c.writeLock {
+		return
+	}
+	if c.lockValidators == nil {
+		c.lockValidators = map[string]*validator.Validator{}
+	}
+	c.lockValidators[c.lockKey(k8sVersion)] = v
+}
+
+// writeLockFile writes the documents used by the recorded validators to the
+// lock file
+func (c *commandFlags) writeLockFile() error {
+	if len(c.lockValidators) == 0 {
+		return nil
+	}
+	lock := schemaLock{
+		APIVersion: lockAPIVersion,
+		Kind:       lockKind,
+		Versions:   map[string]map[string]validator.LockedDocument{},
+	}
+	for key, v := range c.lockValidators {
+		lock.Versions[key] = v.LockedDocuments()
+	}
+	data, err := yaml.Marshal(lock)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.lockFile, append([]byte(lockHeader), data...), 0o644); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/kubectl-validate/pkg/validator"
+	"sigs.k8s.io/yaml"
+)
+
+// parseVersions expands a list of Kubernetes versions and inclusive version
+// ranges such as 1.28-1.32 into a list of major.minor versions
+func parseVersions(values []string) ([]string, error) {
+	var res []string
+	seen := map[string]bool{}
+	for _, value := range values {
+		from, to, isRange := strings.Cut(strings.TrimSpace(value), "-")
+		start, err := version.ParseMajorMinor(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q: %w", value, err)
+		}
+		end := start
+		if isRange {
+			end, err = version.ParseMajorMinor(to)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version range %q: %w", value, err)
+			} else if end.Major() != start.Major() || end.LessThan(start) {
+				return nil, fmt.Errorf("invalid version range %q", value)
+			}
+		}
+		for v := start; !end.LessThan(v); v = v.AddMinor(1) {
+			s := fmt.Sprintf("%d.%d", v.Major(), v.Minor())
+			if !seen[s] {
+				seen[s] = true
+				res = append(res, s)
+			}
+		}
+	}
+	return res, nil
+}
+
+// matrixResult holds the results of validating a single document against
+// each of the requested versions
+type matrixResult struct {
+	// Kind and name of the object, or its index within the file if it could
+	// not be parsed
+	Object string `json:"object"`
+
+	// Status of the object for each version
+	Results map[string]metav1.Status `json:"results"`
+
+	// Causes which are not reported by every version, keyed by the versions
+	// which report them
+	Differences map[string][]metav1.StatusCause `json:"differences,omitempty"`
+}
+
+func (r matrixResult) passed(v string) bool {
+	return r.Results[v].Status == metav1.StatusSuccess
+}
+
+// runMatrix validates every document against each version. Each version is
+// also used as the target version, so APIs removed in it fail. Files are read
+// once, the same documents being validated against every version.
+func (c *commandFlags) runMatrix(ctx context.Context, cmd *cobra.Command, files []string) error {
+	versions, err := parseVersions(c.versions)
+	if err != nil {
+		return ArgumentError{fmt.Errorf("invalid --versions: %w", err)}
+	}
+
+	validators := make([]*validator.Validator, len(versions))
+	targets := make([]*version.Version, len(versions))
+	for i, v := range versions {
+		if validators[i], err = c.newValidator(ctx, v); err != nil {
+			return ArgumentError{err}
+		}
+		targets[i] = version.MustParseGeneric(v)
+	}
+
+	hasError := false
+	res := map[string][]matrixResult{}
+	for _, path := range files {
+		documents, readErr := readDocuments(cmd.InOrStdin(), path)
+		results := make([]matrixResult, len(documents))
+		for i, document := range documents {
+			results[i] = matrixResult{Object: objectLabel(document, i), Results: map[string]metav1.Status{}}
+			for j, v := range versions {
+				doc := c.validateDocument(ctx, document, validators[j], targets[j])
+				results[i].Results[v] = doc.Status()
+				hasError = hasError || !doc.Valid()
+			}
+		}
+		if readErr != nil {
+			// The error is reported after the documents read before it, the
+			// same for every version
+			status := newResult(readErr).Status()
+			result := matrixResult{Object: objectLabel(nil, len(documents)), Results: map[string]metav1.Status{}}
+			for _, v := range versions {
+				result.Results[v] = status
+			}
+			results = append(results, result)
+			hasError = true
+		}
+		for i := range results {
+			results[i].Differences = differingCauses(results[i].Results, versions)
+		}
+		res[path] = results
+	}
+
+	if c.outputFormat == OutputHuman {
+		printMatrix(cmd, files, versions, res)
+	} else {
+		data, e := json.MarshalIndent(res, "", "    ")
+		if e != nil {
+			return InternalError{fmt.Errorf("failed to render results into JSON: %w", e)}
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(data)) //nolint:errcheck
+	}
+
+	if hasError {
+		return ValidationError{errors.New("validation failed")}
+	}
+	return nil
+}
+
+func objectLabel(document []byte, index int) string {
+	obj := metav1.PartialObjectMetadata{}
+	if document == nil || yaml.Unmarshal(document, &obj) != nil || len(obj.Kind) == 0 {
+		return fmt.Sprintf("document %d", index)
+	}
+	if len(obj.Name) == 0 {
+		return obj.Kind
+	}
+	return obj.Kind + "/" + obj.Name
+}
+
+// statusCauses returns the causes of a status. Failures without causes are
+// represented by a single cause holding their message.
+func statusCauses(status metav1.Status) []metav1.StatusCause {
+	if status.Details != nil && len(status.Details.Causes) > 0 {
+		return status.Details.Causes
+	} else if status.Status == metav1.StatusFailure {
+		return []metav1.StatusCause{{Message: status.Message}}
+	}
+	return nil
+}
+
+// differingCauses groups the causes which are not reported by every version
+// by the versions which report them.
+func differingCauses(results map[string]metav1.Status, versions []string) map[string][]metav1.StatusCause {
+	reportedBy := map[metav1.StatusCause][]string{}
+	var order []metav1.StatusCause
+	for _, v := range versions {
+		for _, cause := range statusCauses(results[v]) {
+			if _, ok := reportedBy[cause]; !ok {
+				order = append(order, cause)
+			}
+			reportedBy[cause] = append(reportedBy[cause], v)
+		}
+	}
+
+	res := map[string][]metav1.StatusCause{}
+	for _, cause := range order {
+		if len(reportedBy[cause]) == len(versions) {
+			continue
+		}
+		key := strings.Join(reportedBy[cause], ",")
+		res[key] = append(res[key], cause)
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+func printMatrix(cmd *cobra.Command, files []string, versions []string, res map[string][]matrixResult) {
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "FILE\tOBJECT\t%s\n", strings.Join(versions, "\t")) //nolint:errcheck
+	for _, path := range files {
+		for _, result := range res[path] {
+			row := make([]string, len(versions))
+			for i, v := range versions {
+				if result.passed(v) {
+					row[i] = "OK"
+				} else {
+					row[i] = "ERROR"
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", path, result.Object, strings.Join(row, "\t")) //nolint:errcheck
+		}
+	}
+	w.Flush() //nolint:errcheck
+
+	for _, path := range files {
+		for _, result := range res[path] {
+			failed := slices.ContainsFunc(versions, func(v string) bool { return !result.passed(v) })
+			if !failed && len(result.Differences) == 0 {
+				continue
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "\n\033[1m%s %s\033[0m\n", path, result.Object) //nolint:errcheck
+			differing := map[metav1.StatusCause]bool{}
+			for _, causes := range result.Differences {
+				for _, cause := range causes {
+					differing[cause] = true
+				}
+			}
+			for _, cause := range statusCauses(result.Results[versions[0]]) {
+				if !differing[cause] {
+					fmt.Fprintf(cmd.ErrOrStderr(), "  all: %s\n", formatCause(cause)) //nolint:errcheck
+				}
+			}
+			keys := make([]string, 0, len(result.Differences))
+			for key := range result.Differences {
+				keys = append(keys, key)
+			}
+			sort.Slice(keys, func(i, j int) bool {
+				// Groups are ordered by the first version reporting them
+				first := func(key string) int {
+					v, _, _ := strings.Cut(key, ",")
+					return slices.Index(versions, v)
+				}
+				if first(keys[i]) != first(keys[j]) {
+					return first(keys[i]) < first(keys[j])
+				}
+				return keys[i] < keys[j]
+			})
+			for _, key := range keys {
+				for _, cause := range result.Differences[key] {
+					fmt.Fprintf(cmd.ErrOrStderr(), "  only %s: %s\n", key, formatCause(cause)) //nolint:errcheck
+				}
+			}
+		}
+	}
+}
+
+func formatCause(cause metav1.StatusCause) string {
+	if len(cause.Field) == 0 {
+		return cause.Message
+	}
+	return cause.Field + ": " + cause.Message
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/kubectl-validate/pkg/migrate"
+	"sigs.k8s.io/kubectl-validate/pkg/utils"
+)
+
+type migrateFlags struct {
+	commandFlags
+	toVersion string
+	write     bool
+}
+
+func newMigrateCommand() *cobra.Command {
+	invoked := &migrateFlags{}
+	res := &cobra.Command{
+		Use:          "migrate [manifests to migrate]",
+		Short:        "Migrate manifests from deprecated API versions",
+		Long:         "Rewrites objects using deprecated or removed API versions to the API versions served by the target Kubernetes version, and validates the result against its schemas",
+		Args:         cobra.MinimumNArgs(1),
+		RunE:         invoked.Run,
+		SilenceUsage: true,
+	}
+	res.Flags().StringVarP(&invoked.toVersion, "to-version", "", "", "Kubernetes version to migrate the manifests to")
+	res.Flags().BoolVarP(&invoked.write, "write", "w", false, "Write the migrated manifests back to their files instead of printing them")
+	invoked.addSchemaSourceFlags(res.Flags())
+	_ = res.MarkFlagRequired("to-version")
+	return res
+}
+
+func (c *migrateFlags) Run(cmd *cobra.Command, args []string) error {
+	err := c.run(cmd, args)
+	if lockErr := c.writeLockFile(); lockErr != nil {
+		return errors.Join(err, InternalError{lockErr})
+	}
+	return err
+}
+
+func (c *migrateFlags) run(cmd *cobra.Command, args []string) error {
+	target, err := version.ParseGeneric(c.toVersion)
+	if err != nil {
+		return ArgumentError{fmt.Errorf("invalid --to-version: %w", err)}
+	}
+
+	ctx, cancel := c.context(cmd)
+	defer cancel()
+
+	factory, err := c.newValidator(ctx, c.toVersion)
+	if err != nil {
+		return ArgumentError{err}
+	}
+
+	files, err := utils.FindFiles(args...)
+	if err != nil {
+		return ArgumentError{err}
+	}
+
+	hasError := false
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return InternalError{fmt.Errorf("error reading file: %w", err)}
+		}
+		migrated, results, err := migrate.File(data, utils.IsJson(path), target)
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s: \033[31mERROR\033[0m %v\n", path, err) //nolint:errcheck
+			hasError = true
+			continue
+		}
+
+		converted := false
+		for _, res := range results {
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s: %s", path, res) //nolint:errcheck
+			var errs []error
+			if res.Converted() {
+				converted = true
+				if err := ValidateDocumentContext(ctx, res.Document, factory).Err(); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			if res.Failed(target) {
+				errs = append(errs, fmt.Errorf("%s %s is not served in v%s", res.To.GroupVersion(), res.To.Kind, c.toVersion))
+			}
+
+			if len(errs) != 0 {
+				fmt.Fprintln(cmd.ErrOrStderr(), " \033[31mERROR\033[0m") //nolint:errcheck
+				hasError = true
+			} else {
+				fmt.Fprintln(cmd.ErrOrStderr(), " \033[32mOK\033[0m") //nolint:errcheck
+			}
+			for _, err := range errs {
+				fmt.Fprintf(cmd.ErrOrStderr(), "    %v\n", err) //nolint:errcheck
+			}
+			for _, problem := range res.Problems {
+				fmt.Fprintf(cmd.ErrOrStderr(), "    \033[33mWARNING\033[0m %s\n", problem) //nolint:errcheck
+			}
+		}
+
+		if c.write {
+			if converted {
+				info, err := os.Stat(path)
+				if err != nil {
+					return InternalError{err}
+				}
+				if err := os.WriteFile(path, migrated, info.Mode().Perm()); err != nil {
+					return InternalError{fmt.Errorf("error writing file: %w", err)}
+				}
+			}
+		} else {
+			if len(files) > 1 {
+				fmt.Fprintf(cmd.OutOrStdout(), "---\n# Source: %s\n", path) //nolint:errcheck
+			}
+			cmd.OutOrStdout().Write(migrated) //nolint:errcheck
+		}
+	}
+
+	if hasError {
+		return ValidationError{errors.New("migration failed")}
+	}
+	return nil
+}
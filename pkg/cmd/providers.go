@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/openapi"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+	"sigs.k8s.io/yaml"
+)
+
+// schemaProviderPriority is where a schema provider is consulted among the
+// other sources of schemas. Sources consulted first win for the definitions
+// they serve.
+type schemaProviderPriority string
+
+const (
+	// Consulted before any other source, including local schemas
+	priorityOverride schemaProviderPriority = "override"
+	// Consulted after local schemas and CRDs, before the cluster and builtin
+	// schemas
+	priorityDefault schemaProviderPriority = "default"
+	// Only consulted for what no other source serves
+	priorityFallback schemaProviderPriority = "fallback"
+)
+
+// schemaProvidersConfig is the file passed to --schema-providers
+type schemaProvidersConfig struct {
+	Providers []schemaProviderConfig `json:"providers"`
+}
+
+type schemaProviderConfig struct {
+	openapiclient.ExecProvider `json:",inline"`
+	Priority                   schemaProviderPriority `json:"priority,omitempty"`
+	// Time after which to give up on each run of the provider
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// schemaProviders returns the clients of the configured schema providers by
+// their priority
+func (c *commandFlags) schemaProviders() (map[schemaProviderPriority][]openapi.Client, error) {
+	if c.schemaProvidersFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(c.schemaProvidersFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --schema-providers: %w", err)
+	}
+	var config schemaProvidersConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse --schema-providers: %w", err)
+	}
+
+	res := map[schemaProviderPriority][]openapi.Client{}
+	names := sets.New[string]()
+	for i, provider := range config.Providers {
+		if provider.Name == "" || provider.Command == "" {
+			return nil, fmt.Errorf("schema provider %d of %s must have a name and command", i, c.schemaProvidersFile)
+		} else if names.Has(provider.Name) {
+			return nil, fmt.Errorf("schema provider %s is configured more than once in %s", provider.Name, c.schemaProvidersFile)
+		}
+		names.Insert(provider.Name)
+
+		priority := provider.Priority
+		switch priority {
+		case "":
+			priority = priorityDefault
+		case priorityOverride, priorityDefault, priorityFallback:
+		default:
+			return nil, fmt.Errorf("schema provider %s has invalid priority %q, expected one of %s, %s or %s", provider.Name, priority, priorityOverride, priorityDefault, priorityFallback)
+		}
+		res[priority] = append(res[priority], openapiclient.NewTimeout(provider.Timeout.Duration, openapiclient.NewExec(provider.ExecProvider)))
+	}
+	return res, nil
+}
@@ -0,0 +1,326 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/kubectl-validate/pkg/validator"
+	"sigs.k8s.io/yaml"
+
+	yamlv2 "sigs.k8s.io/yaml/goyaml.v2"
+)
+
+// Category classifies the outcome of validating a document
+type Category string
+
+const (
+	// The document is a valid object, or empty
+	CategoryValid Category = "Valid"
+	// The document is not valid YAML or JSON, or lacks an apiVersion or kind
+	CategoryParseError Category = "ParseError"
+	// The object has fields its schema does not know of
+	CategoryUnknownField Category = "UnknownField"
+	// The object violates its schema or the checks of its kind
+	CategorySchemaViolation Category = "SchemaViolation"
+	// No schema is known for the kind of the object
+	CategoryMissingSchema Category = "MissingSchema"
+	// The document has no apiVersion and kind, such as Helm values
+	CategoryNotKubernetesObject Category = "NotKubernetesObject"
+	// The object uses an API version removed in the target version
+	CategoryRemovedAPI Category = "RemovedAPI"
+	// Validation failed for another reason, such as the file being unreadable
+	CategoryError Category = "Error"
+	// The object was not validated, such as for being of an ignored kind
+	CategorySkipped Category = "Skipped"
+)
+
+// Result is the outcome of validating a single document. Its JSON
+// representation is stable.
+type Result struct {
+	// File the document was read from, "-" for stdin. Empty for documents
+	// validated on their own.
+	File string `json:"file,omitempty"`
+	// Index of the document within its file
+	Index int `json:"index"`
+
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name,omitempty"`
+
+	Category Category `json:"category"`
+	// Problems found with the document. Empty if it is valid.
+	Errors []FieldError `json:"errors,omitempty"`
+	// Problems which do not make the document invalid, such as the use of
+	// deprecated API versions
+	Warnings []string `json:"warnings,omitempty"`
+	// Why the document was not validated, if skipped by filters,
+	// --missing-schemas or --non-k8s-documents
+	Skipped string `json:"skipped,omitempty"`
+
+	// Where the schema of the kind came from, such as "builtin" or
+	// "local-crds". See package openapiclient.
+	SchemaSource string `json:"schemaSource,omitempty"`
+
+	err error
+	// Causes of the Warnings which are not about deprecated API versions
+	warningCauses []metav1.StatusCause
+}
+
+// FieldError is a problem found with a document
+type FieldError struct {
+	// Path of the field at fault, empty if the problem is not with a field
+	Field string `json:"field,omitempty"`
+	// Type of the problem, such as FieldValueInvalid
+	Type    metav1.CauseType `json:"type,omitempty"`
+	Message string           `json:"message"`
+	// What the field accepts according to its schema, for schema violations
+	Schema *validator.FieldSchema `json:"schema,omitempty"`
+}
+
+// Valid returns true if the document is a valid object, or empty, or was
+// skipped
+func (r Result) Valid() bool {
+	return r.Category == CategoryValid || r.Skipped != ""
+}
+
+// Err returns the error the document failed validation with, or nil if it
+// is valid. Results decoded from JSON carry no error.
+func (r Result) Err() error {
+	return r.err
+}
+
+// GroupVersionKind returns the GVK of the object, empty if not known
+func (r Result) GroupVersionKind() schema.GroupVersionKind {
+	return schema.FromAPIVersionAndKind(r.APIVersion, r.Kind)
+}
+
+// Status returns the status reported for the document by --output=json.
+// That of a result decoded from JSON is derived from its category and errors,
+// differing at most in its message.
+func (r Result) Status() metav1.Status {
+	status := r.errorStatus()
+	// Told apart from schema violations and internal errors
+	switch r.Category {
+	case CategoryMissingSchema:
+		status.Reason, status.Code = StatusReasonMissingSchema, http.StatusNotFound
+	case CategoryNotKubernetesObject:
+		status.Reason, status.Code = StatusReasonNotKubernetesObject, http.StatusBadRequest
+	}
+	if r.err != nil && status.Reason != errorToStatus(r.err).Reason {
+		// Rather than that of an internal error
+		status.Message = r.err.Error()
+	}
+	if r.Skipped != "" {
+		status = metav1.Status{Status: metav1.StatusSuccess, Reason: status.Reason, Message: "skipped: " + r.Skipped}
+		if r.Category == CategorySkipped {
+			status.Reason = ""
+		}
+	}
+	return withWarnings(status, r.Warnings, r.warningCauses)
+}
+
+// errorStatus returns the status of the error the document failed with, or
+// one derived from the category and errors of results carrying no error
+func (r Result) errorStatus() metav1.Status {
+	if r.err != nil || r.Valid() {
+		return errorToStatus(r.err)
+	}
+
+	causes := make([]metav1.StatusCause, len(r.Errors))
+	messages := make([]string, len(r.Errors))
+	for i, e := range r.Errors {
+		causes[i] = metav1.StatusCause{Type: e.Type, Message: e.Message, Field: e.Field}
+		messages[i] = e.Message
+		if e.Field != "" {
+			messages[i] = e.Field + ": " + e.Message
+		}
+	}
+	message := strings.Join(messages, ", ")
+	if len(messages) > 1 {
+		message = "[" + message + "]"
+	}
+
+	switch r.Category {
+	case CategoryUnknownField, CategorySchemaViolation, CategoryRemovedAPI:
+		return metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusUnprocessableEntity,
+			Reason:  metav1.StatusReasonInvalid,
+			Details: &metav1.StatusDetails{Causes: causes},
+			Message: fmt.Sprintf("%s %q is invalid: %s", r.GroupVersionKind().GroupKind(), r.Name, message),
+		}
+	}
+	// Other problems, such as parse errors, are reported as internal errors
+	status := k8serrors.NewInternalError(errors.New(message)).ErrStatus
+	status.Details.Causes = causes
+	return status
+}
+
+// documentStatus is the status reported for a document by --output=json,
+// with what the fields at fault accept according to their schema. It is the
+// legacy format kept for the programs parsing it, Result being the stable
+// one written by --output=ndjson.
+type documentStatus struct {
+	metav1.Status `json:",inline"`
+	// Schemas of the fields at fault, by path
+	FieldSchemas map[string]*validator.FieldSchema `json:"fieldSchemas,omitempty"`
+}
+
+func (r Result) documentStatus() documentStatus {
+	res := documentStatus{Status: r.Status()}
+	for _, e := range r.Errors {
+		if e.Schema != nil {
+			if res.FieldSchemas == nil {
+				res.FieldSchemas = map[string]*validator.FieldSchema{}
+			}
+			res.FieldSchemas[e.Field] = e.Schema
+		}
+	}
+	return res
+}
+
+// withFieldSchemas sets what the fields at fault of a schema violation
+// accept according to the schema of the kind
+func (r Result) withFieldSchemas(ctx context.Context, resolver *validator.Validator, gvk schema.GroupVersionKind) Result {
+	if r.Category != CategorySchemaViolation {
+		return r
+	}
+	for i, e := range r.Errors {
+		if e.Field != "" {
+			r.Errors[i].Schema = resolver.FieldSchema(ctx, gvk, e.Field)
+		}
+	}
+	return r
+}
+
+const (
+	// Reason of the statuses of objects of kinds no schema is known for
+	StatusReasonMissingSchema metav1.StatusReason = "MissingSchema"
+	// Reason of the statuses of documents without an apiVersion and kind
+	StatusReasonNotKubernetesObject metav1.StatusReason = "NotKubernetesObject"
+)
+
+// addWarning adds a warning which is not about a deprecated API version
+func (r *Result) addWarning(cause metav1.StatusCause) {
+	r.Warnings = append(r.Warnings, cause.Message)
+	r.warningCauses = append(r.warningCauses, cause)
+}
+
+// skippedResult returns the result of a document which was not validated
+func skippedResult(reason string) Result {
+	return Result{Category: CategorySkipped, Skipped: reason}
+}
+
+// newResult returns the result of a document which failed with err, or was
+// valid if it is nil
+func newResult(err error) Result {
+	res := Result{Category: categorize(err), err: err}
+	if err == nil {
+		return res
+	}
+	status := errorToStatus(err)
+	if status.Details != nil {
+		for _, cause := range status.Details.Causes {
+			res.Errors = append(res.Errors, FieldError{Field: cause.Field, Type: cause.Type, Message: cause.Message})
+		}
+	}
+	if len(res.Errors) == 0 {
+		res.Errors = []FieldError{{Message: status.Message}}
+	}
+	return res
+}
+
+// withObject sets the identity of the object in the result. The document is
+// only parsed again if the object could not be.
+func (r Result) withObject(document []byte, gvk schema.GroupVersionKind, obj *unstructured.Unstructured) Result {
+	if gvk.Empty() || obj == nil {
+		// Fields of the metadata may be of the wrong type
+		var partial struct {
+			metav1.TypeMeta `json:",inline"`
+			Metadata        map[string]interface{} `json:"metadata"`
+		}
+		if yaml.Unmarshal(document, &partial) != nil {
+			return r
+		}
+		r.APIVersion, r.Kind = partial.APIVersion, partial.Kind
+		r.Namespace, _ = partial.Metadata["namespace"].(string)
+		r.Name, _ = partial.Metadata["name"].(string)
+		return r
+	}
+	r.APIVersion, r.Kind = gvk.ToAPIVersionAndKind()
+	r.Namespace, r.Name = obj.GetNamespace(), obj.GetName()
+	return r
+}
+
+// categorize returns the category of documents failing with err
+func categorize(err error) Category {
+	var notFound *validator.SchemaNotFoundError
+	var documentErr *validator.DocumentError
+	var statusErr *k8serrors.StatusError
+	if err == nil {
+		return CategoryValid
+	} else if errors.As(err, &notFound) {
+		return CategoryMissingSchema
+	} else if errors.Is(err, validator.ErrEmptyGVK) {
+		return CategoryNotKubernetesObject
+	} else if errors.As(err, &documentErr) {
+		return CategoryParseError
+	} else if errors.As(err, &statusErr) {
+		if statusErr.ErrStatus.Reason != metav1.StatusReasonInvalid {
+			return CategoryError
+		}
+		return CategorySchemaViolation
+	}
+
+	category := CategoryError
+	for _, e := range flattenErrors(err) {
+		var unknownErr *validator.UnknownFieldError
+		var fieldErr *field.Error
+		var typeErr *json.UnmarshalTypeError
+		var yamlErr *yamlv2.TypeError
+		switch {
+		case errors.As(e, &unknownErr):
+			// Unknown fields are reported by decoding, which stops before
+			// any other check
+			return CategoryUnknownField
+		case errors.As(e, &fieldErr), errors.As(e, &typeErr):
+			category = CategorySchemaViolation
+		case runtime.IsStrictDecodingError(e), errors.As(e, &yamlErr):
+			if category == CategoryError {
+				category = CategoryParseError
+			}
+		}
+	}
+	return category
+}
+
+// flattenErrors returns the errors joined or aggregated within err
+func flattenErrors(err error) []error {
+	var aggregate utilerrors.Aggregate
+	if errors.As(err, &aggregate) {
+		var res []error
+		for _, e := range aggregate.Errors() {
+			res = append(res, flattenErrors(e)...)
+		}
+		return res
+	} else if joined, ok := err.(joinedErrors); ok {
+		var res []error
+		for _, e := range joined.Unwrap() {
+			res = append(res, flattenErrors(e)...)
+		}
+		return res
+	}
+	return []error{err}
+}
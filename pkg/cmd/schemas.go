@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+)
+
+func newSchemasCommand() *cobra.Command {
+	res := &cobra.Command{
+		Use:   "schemas",
+		Short: "Manage schemas for offline use",
+		Args:  cobra.NoArgs,
+	}
+	res.AddCommand(newSchemasPullCommand())
+	return res
+}
+
+type schemasPullFlags struct {
+	kubeConfigOverrides clientcmd.ConfigOverrides
+	out                 string
+	timeout             time.Duration
+}
+
+func newSchemasPullCommand() *cobra.Command {
+	invoked := &schemasPullFlags{}
+	res := &cobra.Command{
+		Use:          "pull",
+		Short:        "Snapshot the schemas served by a cluster",
+		Long:         "Writes the OpenAPI documents served by the cluster of the current kubeconfig context, including those of CRDs and aggregated APIs, to a directory to pass to --local-schemas, along with a manifest of the server version and the hashes of the documents",
+		Args:         cobra.NoArgs,
+		RunE:         invoked.Run,
+		SilenceUsage: true,
+	}
+	res.Flags().StringVarP(&invoked.out, "out", "", "", "Directory to write the snapshot to")
+	res.Flags().DurationVarP(&invoked.timeout, "timeout", "", 0, "Time after which to give up on the snapshot, such as 1m. Zero means no timeout")
+	clientcmd.BindOverrideFlags(&invoked.kubeConfigOverrides, res.Flags(), clientcmd.RecommendedConfigOverrideFlags("kube-"))
+	_ = res.MarkFlagRequired("out")
+	return res
+}
+
+func (c *schemasPullFlags) Run(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithCancel(cmd.Context())
+	if c.timeout > 0 {
+		ctx, cancel = context.WithTimeout(cmd.Context(), c.timeout)
+	}
+	defer cancel()
+
+	manifest, err := openapiclient.WriteSnapshot(ctx, openapiclient.NewKubeConfig(c.kubeConfigOverrides), c.out)
+	if err != nil {
+		return InternalError{err}
+	}
+	serverVersion := "an unknown version"
+	if manifest.ServerVersion != nil {
+		serverVersion = manifest.ServerVersion.GitVersion
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Wrote %d group versions of %s to %s\n", len(manifest.GroupVersions), serverVersion, c.out) //nolint:errcheck
+	return nil
+}
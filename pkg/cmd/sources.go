@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/openapi"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+	"sigs.k8s.io/yaml"
+)
+
+// schemaSourceType is the kind of a source of schemas declared in
+// --schema-sources
+type schemaSourceType string
+
+const (
+	// A directory of documents by group version or an OpenAPI v2 document,
+	// as passed to --local-schemas
+	sourceLocalSchemas schemaSourceType = "localSchemas"
+	// A directory of CRDs, as passed to --local-crds
+	sourceLocalCRDs schemaSourceType = "localCRDs"
+	// A catalog of JSON schemas, as passed to --schema-catalog
+	sourceCatalog schemaSourceType = "catalog"
+	// The cluster of a kubeconfig context
+	sourceCluster schemaSourceType = "cluster"
+	// The builtin schemas embedded in kubectl-validate
+	sourceEmbedded schemaSourceType = "embedded"
+	// The builtin schemas of GitHub or of a mirror of its API
+	sourceGitHub schemaSourceType = "github"
+	// A program run for schemas, as configured in --schema-providers
+	sourceExec schemaSourceType = "exec"
+	// Merges the schemas of its sources, earlier sources winning for the
+	// definitions they serve
+	sourceMerge schemaSourceType = "merge"
+	// Serves the schemas of the first of its sources which does not fail
+	sourceFallback schemaSourceType = "fallback"
+)
+
+// schemaSourcesConfig is the file passed to --schema-sources
+type schemaSourcesConfig struct {
+	// Sources merged in priority order
+	Sources []schemaSourceConfig `json:"sources"`
+}
+
+type schemaSourceConfig struct {
+	Type schemaSourceType `json:"type"`
+	// Path of the local schemas, CRDs or catalog, relative to the file
+	Path string `json:"path,omitempty"`
+	// Kubeconfig context of the cluster, the current one if empty
+	Context string `json:"context,omitempty"`
+	// URL of the GitHub API, such as that of a GitHub Enterprise server
+	URL string `json:"url,omitempty"`
+	// Program run for exec sources
+	openapiclient.ExecProvider `json:",inline"`
+	// Time after which to give up on each request of cluster, GitHub and
+	// exec sources. Defaults to --cluster-timeout and --github-timeout.
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	// Whether to apply the patches kubectl-validate has for builtin schemas
+	BuiltinPatches bool `json:"builtinPatches,omitempty"`
+	// Directory of patches to apply to the schemas, relative to the file
+	Patches string `json:"patches,omitempty"`
+	// Sources of merge and fallback sources
+	Sources []schemaSourceConfig `json:"sources,omitempty"`
+}
+
+// schemaSources returns the sources of schemas in priority order, as
+// declared in --schema-sources or by default
+func (c *commandFlags) schemaSources(k8sVersion string) ([]openapi.Client, error) {
+	if c.schemaSourcesFile == "" {
+		return c.defaultSchemaSources(k8sVersion)
+	}
+	if c.localSchemasDir != "" || len(c.localCRDsDir) > 0 || len(c.schemaCatalogs) > 0 || c.schemaProvidersFile != "" {
+		return nil, errors.New("--schema-sources cannot be used with --local-schemas, --local-crds, --schema-catalog or --schema-providers, declare those sources in it instead")
+	}
+	if c.declaredSources != nil {
+		// Declared by a profile of the project configuration
+		return c.buildSchemaSources(k8sVersion, filepath.Dir(c.schemaSourcesFile), c.declaredSourcesField, c.declaredSources)
+	}
+	data, err := os.ReadFile(c.schemaSourcesFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --schema-sources: %w", err)
+	}
+	var config schemaSourcesConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse --schema-sources: %w", err)
+	}
+	if len(config.Sources) == 0 {
+		return nil, fmt.Errorf("%s declares no sources", c.schemaSourcesFile)
+	}
+	return c.buildSchemaSources(k8sVersion, filepath.Dir(c.schemaSourcesFile), "sources", config.Sources)
+}
+
+func (c *commandFlags) buildSchemaSources(k8sVersion, dir, field string, sources []schemaSourceConfig) ([]openapi.Client, error) {
+	res := make([]openapi.Client, 0, len(sources))
+	for i, source := range sources {
+		client, err := c.buildSchemaSource(k8sVersion, dir, fmt.Sprintf("%s[%d]", field, i), source)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, client)
+	}
+	return res, nil
+}
+
+// buildSchemaSource returns the client of a source declared in
+// --schema-sources, whose relative paths are relative to dir
+func (c *commandFlags) buildSchemaSource(k8sVersion, dir, field string, source schemaSourceConfig) (openapi.Client, error) {
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+	timeout := func(fallback time.Duration) time.Duration {
+		if source.Timeout.Duration != 0 {
+			return source.Timeout.Duration
+		}
+		return fallback
+	}
+
+	switch source.Type {
+	case sourceLocalSchemas, sourceLocalCRDs, sourceCatalog:
+		if source.Path == "" {
+			return nil, fmt.Errorf("%s of %s: %s source must have a path", field, c.schemaSourcesFile, source.Type)
+		}
+	case sourceExec:
+		if source.Name == "" || source.Command == "" {
+			return nil, fmt.Errorf("%s of %s: exec source must have a name and command", field, c.schemaSourcesFile)
+		}
+	case sourceMerge, sourceFallback:
+		if len(source.Sources) == 0 {
+			return nil, fmt.Errorf("%s of %s: %s source must have sources", field, c.schemaSourcesFile, source.Type)
+		}
+	case sourceCluster, sourceEmbedded, sourceGitHub:
+	default:
+		return nil, fmt.Errorf("%s of %s: invalid type %q, expected one of %s, %s, %s, %s, %s, %s, %s, %s or %s", field, c.schemaSourcesFile, source.Type,
+			sourceLocalSchemas, sourceLocalCRDs, sourceCatalog, sourceCluster, sourceEmbedded, sourceGitHub, sourceExec, sourceMerge, sourceFallback)
+	}
+	if len(source.Sources) > 0 && source.Type != sourceMerge && source.Type != sourceFallback {
+		return nil, fmt.Errorf("%s of %s: only %s and %s sources have sources", field, c.schemaSourcesFile, sourceMerge, sourceFallback)
+	}
+
+	var res openapi.Client
+	switch source.Type {
+	case sourceLocalSchemas:
+		res = newLocalSchemas(resolve(source.Path))
+	case sourceLocalCRDs:
+		res = openapiclient.NewLocalCRDFiles(os.DirFS(resolve(source.Path)))
+	case sourceCatalog:
+		res = newSchemaCatalog(resolve(source.Path))
+	case sourceCluster:
+		overrides := c.kubeConfigOverrides
+		if source.Context != "" {
+			overrides.CurrentContext = source.Context
+		}
+		res = openapiclient.NewTimeout(timeout(c.clusterTimeout), openapiclient.NewKubeConfig(overrides))
+	case sourceEmbedded:
+		res = openapiclient.NewHardcodedBuiltins(k8sVersion)
+	case sourceGitHub:
+		if source.URL != "" {
+			res = openapiclient.NewGitHubMirrorBuiltins(source.URL, k8sVersion)
+		} else {
+			res = openapiclient.NewGitHubBuiltins(k8sVersion)
+		}
+		res = openapiclient.NewTimeout(timeout(c.githubTimeout), res)
+	case sourceExec:
+		res = openapiclient.NewTimeout(timeout(0), openapiclient.NewExec(source.ExecProvider))
+	case sourceMerge, sourceFallback:
+		sources, err := c.buildSchemaSources(k8sVersion, dir, field+".sources", source.Sources)
+		if err != nil {
+			return nil, err
+		}
+		if source.Type == sourceMerge {
+			res = openapiclient.NewComposite(sources...)
+		} else {
+			res = openapiclient.NewFallback(sources...)
+		}
+	}
+
+	if source.BuiltinPatches {
+		res = openapiclient.NewOverlay(openapiclient.HardcodedPatchLoader(k8sVersion), res)
+	}
+	if source.Patches != "" {
+		res = openapiclient.NewOverlay(openapiclient.PatchLoaderFromDirectory(os.DirFS(resolve(source.Patches))), res)
+	}
+	return res, nil
+}
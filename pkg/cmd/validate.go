@@ -5,25 +5,33 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
-	"k8s.io/apiextensions-apiserver/pkg/apiserver"
-	"k8s.io/apiextensions-apiserver/pkg/registry/customresourcedefinition"
+	"github.com/spf13/pflag"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/runtime/serializer"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/validation/field"
-	"k8s.io/apiserver/pkg/endpoints/request"
-	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apiserver/pkg/warning"
+	"k8s.io/client-go/openapi"
 	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/kubectl-validate/pkg/deprecation"
 	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
 	"sigs.k8s.io/kubectl-validate/pkg/utils"
 	"sigs.k8s.io/kubectl-validate/pkg/validator"
+	"sigs.k8s.io/yaml"
 
 	yamlv2 "sigs.k8s.io/yaml/goyaml.v2"
 )
@@ -31,8 +39,9 @@ import (
 type OutputFormat string
 
 const (
-	OutputHuman OutputFormat = "human"
-	OutputJSON  OutputFormat = "json"
+	OutputHuman  OutputFormat = "human"
+	OutputJSON   OutputFormat = "json"
+	OutputNDJSON OutputFormat = "ndjson"
 )
 
 // String is used both by fmt.Print and by Cobra in help text
@@ -43,11 +52,11 @@ func (e *OutputFormat) String() string {
 // Set must have pointer receiver so it doesn't change the value of a copy
 func (e *OutputFormat) Set(v string) error {
 	switch v {
-	case "human", "json":
+	case "human", "json", "ndjson":
 		*e = OutputFormat(v)
 		return nil
 	default:
-		return fmt.Errorf(`must be one of "human", or "json"`)
+		return fmt.Errorf(`must be one of "human", "json", or "ndjson"`)
 	}
 }
 
@@ -59,16 +68,93 @@ func (e *OutputFormat) Type() string {
 type commandFlags struct {
 	kubeConfigOverrides clientcmd.ConfigOverrides
 	version             string
+	versions            []string
+	targetVersion       string
 	localSchemasDir     string
 	localCRDsDir        []string
+	schemaCatalogs      []string
 	schemaPatchesDir    string
+	schemaProvidersFile string
+	schemaSourcesFile   string
+	cacheDir            string
+	timeout             time.Duration
+	clusterTimeout      time.Duration
+	githubTimeout       time.Duration
+	lockFile            string
+	writeLock           bool
+	locked              bool
+	nativeValidation    bool
 	outputFormat        OutputFormat
+	configFile          string
+	noConfig            bool
+	missingSchemas      string
+	nonK8sDocuments     string
+
+	// Set by the profile of the project configuration validated with, if any
+	profile              string
+	declaredSources      []schemaSourceConfig
+	declaredSourcesField string
+	fieldValidation      validator.FieldValidation
+	// Those of --ignore-kinds, unless the profile sets its own
+	ignoreKinds          []schema.GroupKind
+	ignoreKindsByProfile bool
+
+	// Filters of the files found in directories and of the objects validated
+	include        []string
+	exclude        []string
+	kindsArg       []string
+	skipKindsArg   []string
+	ignoreKindsArg []string
+	namespace      string
+	selectorArg    string
+	kinds          []schema.GroupKind
+	skipKinds      []schema.GroupKind
+	selector       labels.Selector
+
+	// Validators whose documents are written to the lock file
+	lockValidators map[string]*validator.Validator
+
+	// Checks of the native module, if built with it
+	native *NativeValidation
+
+	// Where warnings about the flags are written, if anywhere
+	errOut io.Writer
+}
+
+// NativeValidation are the checks of native types beyond their OpenAPI
+// schemas, which are those of k8s.io/kubernetes. They are provided by the
+// module in the native directory, so that this one does not depend on
+// k8s.io/kubernetes and can be installed with go install.
+type NativeValidation struct {
+	// Kubernetes release the checks are those of, which --version defaults to
+	Version *version.Version
+	// Validate runs the checks for --native-validation
+	Validate validator.NativeValidationFunc
+	// DeclarativeScheme has the declarative rules of native types, which
+	// apply to Version and later releases
+	DeclarativeScheme *runtime.Scheme
 }
 
 func NewRootCommand() *cobra.Command {
+	return newRootCommand(nil)
+}
+
+// NewNativeRootCommand returns the root command of the binary built with
+// the checks of native types
+func NewNativeRootCommand(native NativeValidation) *cobra.Command {
+	return newRootCommand(&native)
+}
+
+func newRootCommand(native *NativeValidation) *cobra.Command {
 	invoked := &commandFlags{
-		outputFormat: OutputHuman,
-		version:      "1.30",
+		outputFormat:    OutputHuman,
+		version:         "1.30",
+		missingSchemas:  policyError,
+		nonK8sDocuments: policyError,
+		native:          native,
+	}
+	if native != nil {
+		invoked.version = native.Version.String()
 	}
 	res := &cobra.Command{
 		Use:          "kubectl-validate [manifests to validate]",
@@ -79,14 +165,56 @@ func NewRootCommand() *cobra.Command {
 		SilenceUsage: true,
 	}
 	res.Flags().StringVarP(&invoked.version, "version", "", invoked.version, "Kubernetes version to validate native resources against. Required if not connected directly to cluster")
-	res.Flags().StringVarP(&invoked.localSchemasDir, "local-schemas", "", "", "--local-schemas=./path/to/schemas/dir. Path to a directory with format: /apis/<group>/<version>.json for each group-version's schema.")
-	res.Flags().StringSliceVarP(&invoked.localCRDsDir, "local-crds", "", []string{}, "--local-crds=./path/to/crds/dir. Paths to directories containing .yaml or .yml files for CRD definitions.")
-	res.Flags().StringVarP(&invoked.schemaPatchesDir, "schema-patches", "", "", "Path to a directory with format: /apis/<group>/<version>.json for each group-version's schema you wish to jsonpatch to the groupversion's final schema. Patches only apply if the schema exists")
-	res.Flags().VarP(&invoked.outputFormat, "output", "o", "Output format. Choice of: \"human\" or \"json\"")
-	clientcmd.BindOverrideFlags(&invoked.kubeConfigOverrides, res.Flags(), clientcmd.RecommendedConfigOverrideFlags("kube-"))
+	res.Flags().StringSliceVarP(&invoked.versions, "versions", "", nil, "Kubernetes versions or version ranges to validate against, such as 1.28,1.30 or 1.28-1.32. Outputs a matrix of results per object and version, each version also being used as the target version. Overrides --version")
+	res.Flags().StringVarP(&invoked.targetVersion, "target-version", "", "", "Kubernetes version the manifests will be applied to. Objects using API versions removed in this version are reported as errors")
+	res.Flags().BoolVarP(&invoked.nativeValidation, "native-validation", "", false, "Run the checks the apiserver performs for native types beyond their OpenAPI schemas, such as workload selectors matching their template labels. The checks are those of the Kubernetes release the binary is built with, and may differ from those of --version. Requires the binary of the native module, see the README")
+	res.Flags().VarP(&invoked.outputFormat, "output", "o", "Output format. Choice of: \"human\", \"json\", the legacy format of an API status per document of each file, or \"ndjson\", which writes a line of JSON per document as soon as it is validated in the stable format of results")
+	res.Flags().StringSliceVarP(&invoked.include, "include", "", nil, "Globs of the paths of the files to validate within directories, relative to them, such as 'clusters/**'. ** matches any number of directories")
+	res.Flags().StringSliceVarP(&invoked.exclude, "exclude", "", nil, "Globs of the paths of the files or directories to leave out within directories, relative to them, such as '**/values.yaml'. Files and directories listed in "+utils.IgnoreFile+" files are also left out")
+	res.Flags().StringSliceVarP(&invoked.kindsArg, "kinds", "", nil, "Kinds of the objects to validate, such as Deployment or Widget.example.com. Other objects are skipped")
+	res.Flags().StringSliceVarP(&invoked.skipKindsArg, "skip-kinds", "", nil, "Kinds of the objects to skip, such as Secret or Widget.example.com")
+	res.Flags().StringSliceVarP(&invoked.ignoreKindsArg, "ignore-kinds", "", nil, "Kinds of the objects to skip unless the profile of the project configuration they are validated with sets its own ignoreKinds, unlike --skip-kinds which always applies")
+	res.Flags().StringVarP(&invoked.namespace, "namespace", "", "", "Namespace of the objects to validate. Objects of other namespaces are skipped, those without one are validated")
+	res.Flags().StringVarP(&invoked.selectorArg, "selector", "l", "", "Label selector of the objects to validate, such as app=web. Other objects are skipped")
+	res.Flags().StringVarP(&invoked.configFile, "config", "", "", "Path of the project configuration mapping manifests to profiles. Defaults to the "+projectConfigFile+" closest to each manifest, in its directory or above")
+	res.Flags().BoolVarP(&invoked.noConfig, "no-config", "", false, "Ignore project configurations, validating every manifest as configured by flags")
+	res.Flags().StringVarP(&invoked.missingSchemas, "missing-schemas", "", invoked.missingSchemas, "How objects of group versions no schema is known for, such as custom resources without their CRDs, are treated. Choice of: \"error\", \"warn\", which skips them with a warning, or \"skip\". Unknown kinds of known group versions always fail")
+	res.Flags().StringVarP(&invoked.nonK8sDocuments, "non-k8s-documents", "", invoked.nonK8sDocuments, "How documents without an apiVersion and kind, such as Helm values, are treated. Choice of: \"error\" or \"skip\"")
+	invoked.addSchemaSourceFlags(res.Flags())
+	res.AddCommand(newMigrateCommand())
+	res.AddCommand(newSchemasCommand())
 	return res
 }
 
+// addSchemaSourceFlags binds the flags which configure where schemas are
+// loaded from
+func (c *commandFlags) addSchemaSourceFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&c.localSchemasDir, "local-schemas", "", "", "--local-schemas=./path/to/schemas/dir. Path to a directory with format: /apis/<group>/<version>.json for each group-version's schema, or to an OpenAPI v2 document such as a swagger.json.")
+	flags.StringSliceVarP(&c.localCRDsDir, "local-crds", "", []string{}, "--local-crds=./path/to/crds/dir. Paths to directories containing .yaml or .yml files for CRD definitions.")
+	flags.StringVarP(&c.schemaPatchesDir, "schema-patches", "", "", "Path to a directory with format: /apis/<group>/<version>.json for each group-version's schema you wish to jsonpatch to the groupversion's final schema. Patches only apply if the schema exists")
+	flags.StringSliceVarP(&c.schemaCatalogs, "schema-catalog", "", []string{}, "--schema-catalog=./path/to/catalog. Paths to directories of JSON schemas laid out as {group}/{kind}_{version}.json, as used by kubeconform, or path templates such as ./catalog/{{.Group}}/{{.ResourceKind}}_{{.ResourceAPIVersion}}.json.")
+	flags.StringVarP(&c.schemaProvidersFile, "schema-providers", "", "", "Path to a file configuring programs to run for schemas, such as those of an internal registry. See the README for their protocol")
+	flags.StringVarP(&c.schemaSourcesFile, "schema-sources", "", "", "Path to a file declaring the sources of schemas to consult in priority order, replacing the default of local schemas, the cluster, then builtin schemas. See the README for its format")
+	flags.StringVarP(&c.cacheDir, "cache-dir", "", defaultCacheDir(), "Directory to cache resolved schemas in, so later runs skip resolving them again. Set to an empty string to disable caching")
+	flags.DurationVarP(&c.timeout, "timeout", "", 0, "Time after which to give up on fetching schemas, such as 1m. Documents whose schemas are not fetched by then fail. Zero means no timeout")
+	flags.DurationVarP(&c.clusterTimeout, "cluster-timeout", "", 15*time.Second, "Time after which to give up on each request for schemas to the cluster. Zero means no timeout")
+	flags.DurationVarP(&c.githubTimeout, "github-timeout", "", 30*time.Second, "Time after which to give up on each request for schemas to GitHub. Zero means no timeout")
+	flags.StringVarP(&c.lockFile, "lock-file", "", defaultLockFile, "Path of the lock file written by --write-lock and enforced by --locked")
+	flags.BoolVarP(&c.writeLock, "write-lock", "", false, "Write the source and hash of the schemas of each group version used to the lock file")
+	flags.BoolVarP(&c.locked, "locked", "", false, "Fail objects whose group version's schemas are not those of the lock file, such as after builtin, GitHub or cluster schemas changed")
+	clientcmd.BindOverrideFlags(&c.kubeConfigOverrides, flags, clientcmd.RecommendedConfigOverrideFlags("kube-"))
+}
+
+// defaultCacheDir returns the directory of the user's cache reserved for
+// kubectl-validate, or an empty string to disable caching if there is none
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "kubectl-validate")
+}
+
 type joinedErrors interface {
 	Unwrap() []error
 }
@@ -164,87 +292,204 @@ func errorToStatus(err error) metav1.Status {
 	return metav1.Status{Status: metav1.StatusSuccess}
 }
 
-func (c *commandFlags) Run(cmd *cobra.Command, args []string) error {
-	var schemaPatchesFs, localSchemasFs fs.FS
+// context returns the context to validate within, done once --timeout has
+// passed
+func (c *commandFlags) context(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(cmd.Context())
+	}
+	return context.WithTimeout(cmd.Context(), c.timeout)
+}
+
+// newValidator builds a validator for native types of the given Kubernetes
+// version using the configured schema sources
+func (c *commandFlags) newValidator(ctx context.Context, k8sVersion string) (*validator.Validator, error) {
+	var schemaPatchesFs fs.FS
 	if c.schemaPatchesDir != "" {
 		schemaPatchesFs = os.DirFS(c.schemaPatchesDir)
 	}
-	if c.localSchemasDir != "" {
-		localSchemasFs = os.DirFS(c.localSchemasDir)
+	sources, err := c.schemaSources(k8sVersion)
+	if err != nil {
+		return nil, err
 	}
-	var localCRDsFileSystems []fs.FS
-	for _, current := range c.localCRDsDir {
-		localCRDsFileSystems = append(localCRDsFileSystems, os.DirFS(current))
+	opts, err := c.lockOptions(k8sVersion)
+	if err != nil {
+		return nil, err
 	}
-	// tool fetches openapi in the following priority order:
-	factory, err := validator.New(
+	v, versionErr := version.ParseGeneric(k8sVersion)
+	if c.nativeValidation && c.native != nil {
+		opts = append(opts, validator.WithNativeValidation(c.native.Validate))
+		if c.errOut != nil && versionErr == nil && (v.Major() != c.native.Version.Major() || v.Minor() != c.native.Version.Minor()) {
+			fmt.Fprintf(c.errOut, "\033[33mWARNING\033[0m native validation runs the checks of Kubernetes %s, which may differ from those of %s\n", c.native.Version, k8sVersion) //nolint:errcheck
+		}
+	}
+	if versionErr == nil {
+		opts = append(opts, validator.WithKubernetesVersion(v))
+	}
+	// Declarative rules only exist from the release of the native module
+	// onwards, so they would reject objects earlier releases accept
+	if c.native != nil && c.native.DeclarativeScheme != nil && versionErr == nil && v.AtLeast(c.native.Version) {
+		opts = append(opts, validator.WithDeclarativeValidation(c.native.DeclarativeScheme))
+	}
+	if c.cacheDir != "" {
+		opts = append(opts, validator.WithSchemaCache(c.cacheDir))
+	}
+	if c.fieldValidation != "" {
+		opts = append(opts, validator.WithFieldValidation(c.fieldValidation))
+	}
+	res, err := validator.NewContext(
+		ctx,
 		openapiclient.NewOverlay(
 			// apply user defined patches on top of the final schema
 			openapiclient.PatchLoaderFromDirectory(schemaPatchesFs),
-			openapiclient.NewComposite(
-				// consult local OpenAPI
-				openapiclient.NewLocalSchemaFiles(localSchemasFs),
-				// consult local CRDs
-				openapiclient.NewLocalCRDFiles(localCRDsFileSystems...),
-				openapiclient.NewOverlay(
-					// Hand-written hardcoded patches.
-					openapiclient.HardcodedPatchLoader(c.version),
-					// try cluster for schemas first, if they are not available
-					// then fallback to hardcoded or builtin schemas
-					openapiclient.NewFallback(
-						// contact connected cluster for any schemas. (should this be opt-in?)
-						openapiclient.NewKubeConfig(c.kubeConfigOverrides),
-						// try hardcoded builtins first, if they are not available
-						// fall back to GitHub builtins
-						openapiclient.NewFallback(
-							// schemas for known k8s versions are scraped from GH and placed here
-							openapiclient.NewHardcodedBuiltins(c.version),
-							// check github for builtins not hardcoded.
-							// subject to rate limiting. should use a diskcache
-							// since etag requests are not limited
-							openapiclient.NewGitHubBuiltins(c.version),
-						)),
-				),
-			),
+			openapiclient.NewComposite(sources...),
 		),
+		opts...,
 	)
+	if err != nil {
+		return nil, err
+	}
+	c.recordLock(k8sVersion, res)
+	return res, nil
+}
+
+func (c *commandFlags) Run(cmd *cobra.Command, args []string) error {
+	c.errOut = cmd.ErrOrStderr()
+	err := c.run(cmd, args)
+	// Schemas are locked even if objects fail validation against them
+	if lockErr := c.writeLockFile(); lockErr != nil {
+		return errors.Join(err, InternalError{lockErr})
+	}
+	return err
+}
+
+func (c *commandFlags) run(cmd *cobra.Command, args []string) error {
+	filter := utils.FileFilter{Include: c.include, Exclude: c.exclude}
+	if err := filter.Validate(); err != nil {
+		return ArgumentError{fmt.Errorf("invalid --include or --exclude: %w", err)}
+	}
+	if err := c.parseObjectFilters(); err != nil {
+		return ArgumentError{err}
+	}
+	if err := c.checkDocumentPolicies(); err != nil {
+		return ArgumentError{err}
+	}
+	if c.nativeValidation && c.native == nil {
+		return ArgumentError{errors.New("--native-validation requires the binary built from the native module, see the README")}
+	}
+	var files []string
+	for _, arg := range args {
+		if arg == stdinPath {
+			files = append(files, arg)
+			continue
+		}
+		found, err := utils.FindFilesFiltered(filter, arg)
+		if err != nil {
+			return ArgumentError{err}
+		}
+		files = append(files, found...)
+	}
+
+	ctx, cancel := c.context(cmd)
+	defer cancel()
+
+	refs, err := c.profilesOf(files)
 	if err != nil {
 		return ArgumentError{err}
 	}
+	if len(c.versions) > 0 {
+		for _, path := range files {
+			if ref := refs[path]; ref.name != "" {
+				return ArgumentError{fmt.Errorf("--versions cannot be used with profiles, %s is validated with profile %s of %s", path, ref.name, ref.config.path)}
+			}
+		}
+		return c.runMatrix(ctx, cmd, files)
+	}
 
-	files, err := utils.FindFiles(args...)
+	profiles, err := c.fileProfiles(ctx, refs, files)
 	if err != nil {
 		return ArgumentError{err}
 	}
 
+	var targetVersion *version.Version
+	if len(c.targetVersion) > 0 {
+		targetVersion, err = version.ParseGeneric(c.targetVersion)
+		if err != nil {
+			return ArgumentError{fmt.Errorf("invalid --target-version: %w", err)}
+		}
+	}
+
 	hasError := false
-	if c.outputFormat == OutputHuman {
+	switch c.outputFormat {
+	case OutputHuman:
+		var summary summary
 		for _, path := range files {
 			fmt.Fprintf(cmd.OutOrStdout(), "\n\033[1m%v\033[0m...", path) //nolint:errcheck
-			var errs []error
-			for _, err := range ValidateFile(path, factory) {
-				if err != nil {
-					errs = append(errs, err)
+			var failures []Result
+			var warnings []string
+			var failed, skipped []Category
+			documents := 0
+			profile := profiles[path]
+			profile.flags.validateStream(ctx, cmd, path, profile.validator, targetVersion, func(res Result) {
+				if res.Err() != nil {
+					failures = append(failures, res)
+					failed = append(failed, res.Category)
 				}
-			}
-			if len(errs) != 0 {
-				fmt.Fprintln(cmd.OutOrStdout(), "\033[31mERROR\033[0m") //nolint:errcheck
-				for _, err := range errs {
-					fmt.Fprintln(cmd.ErrOrStderr(), err.Error()) //nolint:errcheck
+				warnings = append(warnings, res.Warnings...)
+				documents++
+				if res.Skipped != "" {
+					skipped = append(skipped, res.Category)
+				}
+				summary.add(res)
+			})
+			if len(failures) == 0 && len(skipped) > 0 && len(skipped) == documents {
+				fmt.Fprintf(cmd.OutOrStdout(), "\033[33m%s\033[0m\n", fileLabel(skipped, "SKIPPED")) //nolint:errcheck
+			} else if len(failures) != 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "\033[31m%s\033[0m\n", fileLabel(failed, "ERROR")) //nolint:errcheck
+				for _, res := range failures {
+					fmt.Fprintln(cmd.ErrOrStderr(), res.Err().Error()) //nolint:errcheck
+					// What the fields at fault accept, to fix them without
+					// looking up the API reference
+					for _, e := range res.Errors {
+						if e.Schema != nil {
+							fmt.Fprintf(cmd.ErrOrStderr(), "  \033[2m%s: %s\033[0m\n", e.Field, e.Schema) //nolint:errcheck
+						}
+					}
 				}
 				hasError = true
 			} else {
 				fmt.Fprintln(cmd.OutOrStdout(), "\033[32mOK\033[0m") //nolint:errcheck
 			}
+			for _, warning := range warnings {
+				fmt.Fprintf(cmd.ErrOrStderr(), "\033[33mWARNING\033[0m %s\n", warning) //nolint:errcheck
+			}
 		}
-	} else {
-		res := map[string][]metav1.Status{}
+		fmt.Fprintf(cmd.OutOrStdout(), "\n%s\n", summary) //nolint:errcheck
+	case OutputNDJSON:
+		// One line per document, written as soon as it is validated
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		var renderErr error
 		for _, path := range files {
-			for _, err := range ValidateFile(path, factory) {
-				res[path] = append(res[path], errorToStatus(err))
-				hasError = hasError || err != nil
+			profile := profiles[path]
+			profile.flags.validateStream(ctx, cmd, path, profile.validator, targetVersion, func(doc Result) {
+				hasError = hasError || !doc.Valid()
+				if renderErr == nil {
+					renderErr = encoder.Encode(doc)
+				}
+			})
+			if renderErr != nil {
+				return InternalError{fmt.Errorf("failed to render results into JSON: %w", renderErr)}
 			}
 		}
+	default:
+		res := map[string][]documentStatus{}
+		for _, path := range files {
+			profile := profiles[path]
+			profile.flags.validateStream(ctx, cmd, path, profile.validator, targetVersion, func(doc Result) {
+				res[path] = append(res[path], doc.documentStatus())
+				hasError = hasError || !doc.Valid()
+			})
+		}
 		data, e := json.MarshalIndent(res, "", "    ")
 		if e != nil {
 			return InternalError{fmt.Errorf("failed to render results into JSON: %w", e)}
@@ -258,51 +503,384 @@ func (c *commandFlags) Run(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func ValidateFile(filePath string, resolver *validator.Validator) []error {
-	fileBytes, err := os.ReadFile(filePath)
-	if err != nil {
-		return []error{fmt.Errorf("error reading file: %w", err)}
+// summary counts the outcomes of the documents validated
+type summary struct {
+	valid, invalid, skipped int
+	// Failed or skipped by --missing-schemas or --non-k8s-documents
+	missingSchemas, notKubernetesObjects int
+}
+
+func (s *summary) add(res Result) {
+	switch {
+	case res.Category == CategoryMissingSchema:
+		s.missingSchemas++
+	case res.Category == CategoryNotKubernetesObject:
+		s.notKubernetesObjects++
+	case res.Category == CategorySkipped:
+		s.skipped++
+	case res.Valid():
+		s.valid++
+	default:
+		s.invalid++
 	}
-	if utils.IsYaml(filePath) {
-		documents, err := utils.SplitYamlDocuments(fileBytes)
-		if err != nil {
-			return []error{err}
+}
+
+func (s summary) String() string {
+	total := s.valid + s.invalid + s.skipped + s.missingSchemas + s.notKubernetesObjects
+	res := fmt.Sprintf("%d documents: %d valid, %d invalid, %d skipped", total, s.valid, s.invalid, s.skipped)
+	if s.missingSchemas > 0 {
+		res += fmt.Sprintf(", %d missing schemas", s.missingSchemas)
+	}
+	if s.notKubernetesObjects > 0 {
+		res += fmt.Sprintf(", %d not Kubernetes objects", s.notKubernetesObjects)
+	}
+	return res
+}
+
+// fileLabel returns the label of a file in human output given the categories
+// of its failed or skipped documents, telling missing schemas and documents
+// which are not Kubernetes objects apart from other outcomes
+func fileLabel(categories []Category, fallback string) string {
+	label := ""
+	for _, category := range categories {
+		var l string
+		switch category {
+		case CategoryMissingSchema:
+			l = "MISSING SCHEMA"
+		case CategoryNotKubernetesObject:
+			l = "NOT A KUBERNETES OBJECT"
+		default:
+			return fallback
 		}
-		var errs []error
-		for _, document := range documents {
-			if utils.IsEmptyYamlDocument(document) {
-				errs = append(errs, nil)
-			} else {
-				errs = append(errs, ValidateDocument(document, resolver))
-			}
+		if label != "" && l != label {
+			return fallback
+		}
+		label = l
+	}
+	return label
+}
+
+// stdinPath is the argument standing for the standard input, which is read
+// as a stream of YAML documents
+const stdinPath = "-"
+
+// validateFile returns the results of every document within the file
+func (c *commandFlags) validateFile(ctx context.Context, cmd *cobra.Command, filePath string, resolver *validator.Validator, targetVersion *version.Version) []Result {
+	var res []Result
+	c.validateStream(ctx, cmd, filePath, resolver, targetVersion, func(doc Result) {
+		res = append(res, doc)
+	})
+	return res
+}
+
+// validateStream validates the documents within the file as they are read,
+// passing the result of each to emit. A file which cannot be read results in
+// a single failed document.
+func (c *commandFlags) validateStream(ctx context.Context, cmd *cobra.Command, filePath string, resolver *validator.Validator, targetVersion *version.Version, emit func(Result)) {
+	index := 0
+	err := readDocumentStream(cmd.InOrStdin(), filePath, func(document utils.Document) {
+		res := c.validateDocument(ctx, document, resolver, targetVersion)
+		res.File, res.Index = filePath, index
+		index++
+		emit(res)
+	})
+	if err != nil {
+		res := newResult(err)
+		res.File, res.Index = filePath, index
+		emit(res)
+	}
+}
+
+// validateDocument validates a single document of a file, applying the
+// filters of objects, the target version and the document policies
+func (c *commandFlags) validateDocument(ctx context.Context, document utils.Document, resolver *validator.Validator, targetVersion *version.Version) Result {
+	// Deprecations are reported relative to the version the manifests are
+	// headed for, or the version they are being validated against.
+	referenceVersion := targetVersion
+	if referenceVersion == nil {
+		referenceVersion, _ = version.ParseGeneric(c.version)
+	}
+
+	lifecycle, ok := lifecycleForDocument(document)
+	if reason := c.skipReason(document); reason != "" {
+		return skippedResult(reason).withObject(document, schema.GroupVersionKind{}, nil)
+	} else if ok && lifecycle.RemovedIn(targetVersion) {
+		res := newResult(field.Invalid(field.NewPath("apiVersion"), lifecycle.GroupVersionKind.GroupVersion().String(), lifecycle.String()))
+		res.Category = CategoryRemovedAPI
+		return res.withObject(document, schema.GroupVersionKind{}, nil)
+	}
+	res := c.applyDocumentPolicies(ValidateDocumentContext(ctx, document, resolver))
+	if ok && (lifecycle.DeprecatedIn(referenceVersion) || lifecycle.RemovedIn(referenceVersion)) {
+		res.Warnings = append(res.Warnings, lifecycle.String())
+	}
+	return res
+}
+
+func lifecycleForDocument(document []byte) (deprecation.Lifecycle, bool) {
+	if document == nil {
+		return deprecation.Lifecycle{}, false
+	}
+	metadata := metav1.TypeMeta{}
+	if err := yaml.Unmarshal(document, &metadata); err != nil {
+		// Reported during validation
+		return deprecation.Lifecycle{}, false
+	}
+	return deprecation.ForGVK(metadata.GroupVersionKind())
+}
+
+// CauseTypeAPIDeprecated is the type of causes added to a document's status
+// when it uses a deprecated API version.
+const CauseTypeAPIDeprecated metav1.CauseType = "APIDeprecated"
+
+// CauseTypeFieldWarning is the type of causes added to a document's status
+// for unknown or duplicate fields ignored by a field validation of Warn.
+const CauseTypeFieldWarning metav1.CauseType = "FieldWarning"
+
+// CauseTypeMissingSchema is the type of causes added to a document's status
+// when no schema is known for its kind with --missing-schemas=warn.
+const CauseTypeMissingSchema metav1.CauseType = "MissingSchema"
+
+func withWarnings(status metav1.Status, warnings []string, causes []metav1.StatusCause) metav1.Status {
+	if len(warnings) == 0 {
+		return status
+	}
+	if status.Details == nil {
+		status.Details = &metav1.StatusDetails{}
+	}
+	for _, warning := range warnings {
+		cause := metav1.StatusCause{
+			Type:    CauseTypeAPIDeprecated,
+			Message: warning,
+			Field:   "apiVersion",
 		}
-		return errs
-	} else {
-		return []error{
-			ValidateDocument(fileBytes, resolver),
+		if i := slices.IndexFunc(causes, func(c metav1.StatusCause) bool { return c.Message == warning }); i >= 0 {
+			cause = causes[i]
 		}
+		status.Details.Causes = append(status.Details.Causes, cause)
 	}
+	return status
+}
+
+// ValidateFile validates every document within the file. A file which cannot
+// be read results in a single failed document.
+func ValidateFile(filePath string, resolver *validator.Validator) []Result {
+	return ValidateFileContext(context.Background(), filePath, resolver)
 }
 
-func ValidateDocument(document []byte, resolver *validator.Validator) error {
-	gvk, parsed, err := resolver.Parse(document)
-	if gvk.Group == "apiextensions.k8s.io" && gvk.Kind == "CustomResourceDefinition" {
-		// CRD spec contains an infinite loop which is not supported by K8s
-		// OpenAPI-based validator. Use the handwritten validation based upon
-		// native types for CRD files. There are no other recursive schemas to my
-		// knowledge, and any schema defined in CRD cannot be recursive.
-		// Long term goal is to remove this once k8s upstream has better
-		// support for validating against spec.Schema for native types.
-		obj, _, err := serializer.NewCodecFactory(apiserver.Scheme).UniversalDecoder().Decode(document, nil, nil)
+// ValidateFileContext is like ValidateFile, giving up on fetching schemas
+// once ctx is done
+func ValidateFileContext(ctx context.Context, filePath string, resolver *validator.Validator) []Result {
+	var res []Result
+	index := 0
+	if err := readDocumentStream(nil, filePath, func(document utils.Document) {
+		doc := ValidateDocumentContext(ctx, document, resolver)
+		doc.File, doc.Index = filePath, index
+		index++
+		res = append(res, doc)
+	}); err != nil {
+		doc := newResult(err)
+		doc.File, doc.Index = filePath, index
+		res = append(res, doc)
+	}
+	return res
+}
+
+// ValidateReader validates the YAML or JSON documents read from r one at a
+// time, as they arrive, passing the result of each to fn. The returned error
+// is that of reading r, which stops validation.
+func ValidateReader(r io.Reader, resolver *validator.Validator, fn func(Result)) error {
+	return ValidateReaderContext(context.Background(), r, resolver, fn)
+}
+
+// ValidateReaderContext is like ValidateReader, giving up on fetching schemas
+// once ctx is done
+func ValidateReaderContext(ctx context.Context, r io.Reader, resolver *validator.Validator, fn func(Result)) error {
+	index := 0
+	return readYamlDocuments(r, func(document utils.Document) {
+		res := ValidateDocumentContext(ctx, document, resolver)
+		res.Index = index
+		index++
+		fn(res)
+	})
+}
+
+// readDocuments returns each of the documents within the file, which is read
+// from stdin if named "-". Empty YAML documents are returned as nil. On error,
+// the documents read until then are returned with it.
+func readDocuments(stdin io.Reader, filePath string) ([]utils.Document, error) {
+	var documents []utils.Document
+	err := readDocumentStream(stdin, filePath, func(document utils.Document) {
+		documents = append(documents, document)
+	})
+	return documents, err
+}
+
+// readDocumentStream passes each of the documents within the file to fn as
+// they are read. Empty YAML documents are passed as nil. The file named "-"
+// is read from stdin.
+func readDocumentStream(stdin io.Reader, filePath string, fn func(utils.Document)) error {
+	if filePath == stdinPath && stdin != nil {
+		return readYamlDocuments(stdin, fn)
+	}
+	if !utils.IsYaml(filePath) {
+		fileBytes, err := os.ReadFile(filePath)
 		if err != nil {
+			return fmt.Errorf("error reading file: %w", err)
+		}
+		fn(fileBytes)
+		return nil
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+	defer file.Close() //nolint:errcheck
+	return readYamlDocuments(file, fn)
+}
+
+// readYamlDocuments passes each of the documents read from r to fn as they
+// are read. Empty documents are passed as nil.
+func readYamlDocuments(r io.Reader, fn func(utils.Document)) error {
+	reader := utils.NewYamlDocumentReader(r)
+	for {
+		document, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
 			return err
 		}
+		if utils.IsEmptyYamlDocument(document) {
+			document = nil
+		}
+		fn(document)
+	}
+}
 
-		strat := customresourcedefinition.NewStrategy(apiserver.Scheme)
-		rest.FillObjectMetaSystemFields(obj.(metav1.Object))
-		return rest.BeforeCreate(strat, request.WithNamespace(context.TODO(), ""), obj)
-	} else if err != nil {
-		return err
+// ValidateDocument validates a single YAML or JSON document. Empty documents,
+// passed as nil, are valid.
+func ValidateDocument(document []byte, resolver *validator.Validator) Result {
+	return ValidateDocumentContext(context.Background(), document, resolver)
+}
+
+// ValidateDocumentContext is like ValidateDocument, giving up on fetching the
+// schema of the object once ctx is done
+func ValidateDocumentContext(ctx context.Context, document []byte, resolver *validator.Validator) Result {
+	if document == nil {
+		return newResult(nil)
+	}
+	var warnings warningRecorder
+	ctx = warning.WithWarningRecorder(ctx, &warnings)
+	gvk, parsed, err := resolver.ParseContext(ctx, document)
+	if err == nil {
+		err = resolver.ValidateContext(ctx, parsed)
+	}
+	res := newResult(err).withObject(document, gvk, parsed).withFieldSchemas(ctx, resolver, gvk)
+	for _, warning := range warnings {
+		res.addWarning(metav1.StatusCause{Type: CauseTypeFieldWarning, Message: warning})
 	}
-	return resolver.Validate(parsed)
+	if !gvk.Empty() {
+		res.SchemaSource = resolver.SchemaSource(gvk)
+	}
+	return res
+}
+
+// warningRecorder collects the warnings about unknown or duplicate fields of
+// validating a document with a field validation of Warn
+type warningRecorder []string
+
+func (r *warningRecorder) AddWarning(agent, text string) {
+	if agent == validator.FieldWarningAgent {
+		*r = append(*r, text)
+	}
+}
+
+// defaultSchemaSources returns the sources of schemas consulted unless
+// --schema-sources is given, in priority order
+func (c *commandFlags) defaultSchemaSources(k8sVersion string) ([]openapi.Client, error) {
+	localSchemas := openapiclient.NewLocalSchemaFiles(nil)
+	if c.localSchemasDir != "" {
+		localSchemas = newLocalSchemas(c.localSchemasDir)
+	}
+	var localCRDsFileSystems []fs.FS
+	for _, current := range c.localCRDsDir {
+		localCRDsFileSystems = append(localCRDsFileSystems, os.DirFS(current))
+	}
+	var schemaCatalogs []openapi.Client
+	for _, current := range c.schemaCatalogs {
+		schemaCatalogs = append(schemaCatalogs, newSchemaCatalog(current))
+	}
+	providers, err := c.schemaProviders()
+	if err != nil {
+		return nil, err
+	}
+	// tool fetches openapi in the following priority order:
+	var sources []openapi.Client
+	// consult providers overriding every other source
+	sources = append(sources, providers[priorityOverride]...)
+	sources = append(sources,
+		// consult local OpenAPI
+		localSchemas,
+		// consult local CRDs
+		openapiclient.NewLocalCRDFiles(localCRDsFileSystems...),
+	)
+	// consult catalogs of JSON schemas
+	sources = append(sources, schemaCatalogs...)
+	sources = append(sources, providers[priorityDefault]...)
+	sources = append(sources,
+		openapiclient.NewOverlay(
+			// Hand-written hardcoded patches.
+			openapiclient.HardcodedPatchLoader(k8sVersion),
+			// try cluster for schemas first, if they are not available
+			// then fallback to hardcoded or builtin schemas
+			openapiclient.NewFallback(
+				// contact connected cluster for any schemas. (should this be opt-in?)
+				openapiclient.NewTimeout(c.clusterTimeout, openapiclient.NewKubeConfig(c.kubeConfigOverrides)),
+				// try hardcoded builtins first, if they are not available
+				// fall back to GitHub builtins
+				openapiclient.NewFallback(
+					// schemas for known k8s versions are scraped from GH and placed here
+					openapiclient.NewHardcodedBuiltins(k8sVersion),
+					// check github for builtins not hardcoded.
+					// subject to rate limiting. should use a diskcache
+					// since etag requests are not limited
+					openapiclient.NewTimeout(c.githubTimeout, openapiclient.NewGitHubBuiltins(k8sVersion)),
+				)),
+		),
+	)
+	// consult providers for what no other source serves
+	sources = append(sources, providers[priorityFallback]...)
+	return sources, nil
+}
+
+// splitSchemaCatalog splits a --schema-catalog into the directory of the
+// catalog and the template of the paths of its files within it, if any
+func splitSchemaCatalog(catalog string) (string, string) {
+	i := strings.Index(catalog, "{{")
+	if i < 0 {
+		return catalog, ""
+	}
+	dir, template := ".", catalog
+	if slash := strings.LastIndexAny(catalog[:i], `/`+string(filepath.Separator)); slash >= 0 {
+		dir, template = catalog[:slash], catalog[slash+1:]
+		if dir == "" {
+			dir = "/"
+		}
+	}
+	return dir, filepath.ToSlash(template)
+}
+
+// newLocalSchemas returns the client of a --local-schemas, either a
+// directory of documents by group version or an OpenAPI v2 document
+func newLocalSchemas(path string) openapi.Client {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		// a single OpenAPI v2 document, split by group version
+		return openapiclient.NewLocalOpenAPIV2File(os.DirFS(filepath.Dir(path)), filepath.Base(path))
+	}
+	return openapiclient.NewLocalSchemaFiles(os.DirFS(path))
+}
+
+// newSchemaCatalog returns the client of a --schema-catalog
+func newSchemaCatalog(catalog string) openapi.Client {
+	dir, template := splitSchemaCatalog(catalog)
+	return openapiclient.NewSchemaCatalog(os.DirFS(dir), template)
 }
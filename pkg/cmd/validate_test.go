@@ -2,17 +2,25 @@ package cmd_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/kubectl-validate/pkg/cmd"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
 	"sigs.k8s.io/kubectl-validate/pkg/utils"
+	"sigs.k8s.io/kubectl-validate/pkg/validator"
 )
 
 var (
@@ -29,6 +37,8 @@ func TestValidationErrorsIndividually(t *testing.T) {
 	//!TODO: Change download-builtin-schemas to apply these patches to all
 	//		 versions
 	patchesDir := "../openapiclient/patches/1.23"
+	// Shared by the cases, so that most of them run with a warm cache
+	cacheDir := t.TempDir()
 
 	cases, err := os.ReadDir(manifestDir)
 	require.NoError(t, err)
@@ -92,6 +102,7 @@ func TestValidationErrorsIndividually(t *testing.T) {
 
 			require.NoError(t, rootCmd.Flags().Set("local-crds", crdsDir))
 			require.NoError(t, rootCmd.Flags().Set("schema-patches", patchesDir))
+			require.NoError(t, rootCmd.Flags().Set("cache-dir", cacheDir))
 			require.NoError(t, rootCmd.Flags().Set("output", "json"))
 
 			// There should be no error executing the case, just validation errors
@@ -119,9 +130,1060 @@ func TestReturnsError(t *testing.T) {
 
 	rootCmd := cmd.NewRootCommand()
 	rootCmd.SetArgs([]string{path})
+	require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
 	require.Error(t, rootCmd.Execute(), "expected error")
 
 	rootCmd = cmd.NewRootCommand()
 	rootCmd.SetArgs([]string{successPath})
+	require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
 	require.NoError(t, rootCmd.Execute(), "expected no error")
 }
+
+func TestTargetVersion(t *testing.T) {
+	path := filepath.Join(testcasesDir, "deprecations", "poddisruptionbudget.yaml")
+	deprecated := metav1.StatusCause{
+		Type:    cmd.CauseTypeAPIDeprecated,
+		Message: "policy/v1beta1 PodDisruptionBudget is deprecated in v1.21 and removed in v1.25, use policy/v1 PodDisruptionBudget instead",
+		Field:   "apiVersion",
+	}
+
+	tests := []struct {
+		name          string
+		targetVersion string
+		wantErr       bool
+		want          metav1.Status
+	}{{
+		name: "warns without target",
+		want: metav1.Status{
+			Status:  metav1.StatusSuccess,
+			Details: &metav1.StatusDetails{Causes: []metav1.StatusCause{deprecated}},
+		},
+	}, {
+		name:          "served by target",
+		targetVersion: "1.24",
+		want: metav1.Status{
+			Status:  metav1.StatusSuccess,
+			Details: &metav1.StatusDetails{Causes: []metav1.StatusCause{deprecated}},
+		},
+	}, {
+		name:          "removed in target",
+		targetVersion: "1.25",
+		wantErr:       true,
+		want: metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: ` "" is invalid: apiVersion: Invalid value: "policy/v1beta1": ` + deprecated.Message,
+			Reason:  metav1.StatusReasonInvalid,
+			Details: &metav1.StatusDetails{Causes: []metav1.StatusCause{{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: `Invalid value: "policy/v1beta1": ` + deprecated.Message,
+				Field:   "apiVersion",
+			}}},
+			Code: 422,
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rootCmd := cmd.NewRootCommand()
+
+			var buf bytes.Buffer
+			rootCmd.SetOut(&buf)
+			rootCmd.SetArgs([]string{path})
+			require.NoError(t, rootCmd.Flags().Set("version", "1.24"))
+			require.NoError(t, rootCmd.Flags().Set("output", "json"))
+			require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
+			if len(tt.targetVersion) > 0 {
+				require.NoError(t, rootCmd.Flags().Set("target-version", tt.targetVersion))
+			}
+
+			if err := rootCmd.Execute(); tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			output := map[string][]metav1.Status{}
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+			assert.Equal(t, []metav1.Status{tt.want}, output[path])
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(testcasesDir, "deprecations", "poddisruptionbudget.yaml")
+
+	rootCmd := cmd.NewRootCommand()
+	var stdout, stderr bytes.Buffer
+	rootCmd.SetOut(&stdout)
+	rootCmd.SetErr(&stderr)
+	rootCmd.SetArgs([]string{"migrate", "--to-version", "1.25", "--cache-dir", "", path})
+	require.NoError(t, rootCmd.Execute())
+
+	assert.Contains(t, stdout.String(), "apiVersion: policy/v1\n")
+	assert.Contains(t, stderr.String(), "policy/v1beta1 PodDisruptionBudget -> policy/v1 PodDisruptionBudget")
+
+	rootCmd = cmd.NewRootCommand()
+	rootCmd.SetArgs([]string{"migrate", "--cache-dir", "", path})
+	require.Error(t, rootCmd.Execute(), "expected --to-version to be required")
+}
+
+func TestVersionsMatrix(t *testing.T) {
+	path := filepath.Join(testcasesDir, "deprecations", "poddisruptionbudget.yaml")
+
+	rootCmd := cmd.NewRootCommand()
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{path})
+	require.NoError(t, rootCmd.Flags().Set("versions", "1.23-1.24,1.25"))
+	require.NoError(t, rootCmd.Flags().Set("output", "json"))
+	require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
+	require.Error(t, rootCmd.Execute())
+
+	var output map[string][]struct {
+		Object      string                          `json:"object"`
+		Results     map[string]metav1.Status        `json:"results"`
+		Differences map[string][]metav1.StatusCause `json:"differences"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+	require.Len(t, output[path], 1)
+
+	result := output[path][0]
+	assert.Equal(t, "PodDisruptionBudget/api-deprecation", result.Object)
+	assert.Equal(t, metav1.StatusSuccess, result.Results["1.23"].Status)
+	assert.Equal(t, metav1.StatusSuccess, result.Results["1.24"].Status)
+	assert.Equal(t, metav1.StatusFailure, result.Results["1.25"].Status)
+	assert.Len(t, result.Differences["1.23,1.24"], 1)
+	assert.Len(t, result.Differences["1.25"], 1)
+
+	// Documents read from stdin, which can only be read once, are validated
+	// against every version
+	manifest, err := os.ReadFile(path)
+	require.NoError(t, err)
+	rootCmd = cmd.NewRootCommand()
+	buf.Reset()
+	rootCmd.SetOut(&buf)
+	rootCmd.SetIn(bytes.NewReader(manifest))
+	rootCmd.SetArgs([]string{"-", "--versions", "1.24,1.25", "--output", "json", "--cache-dir", ""})
+	require.Error(t, rootCmd.Execute())
+	output = nil
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+	require.Len(t, output["-"], 1)
+	assert.Equal(t, "PodDisruptionBudget/api-deprecation", output["-"][0].Object)
+	assert.Equal(t, metav1.StatusSuccess, output["-"][0].Results["1.24"].Status)
+	assert.Equal(t, metav1.StatusFailure, output["-"][0].Results["1.25"].Status)
+
+	for _, invalid := range []string{"1.30-1.28", "1.28-2.0", "latest"} {
+		rootCmd = cmd.NewRootCommand()
+		rootCmd.SetArgs([]string{path})
+		require.NoError(t, rootCmd.Flags().Set("versions", invalid))
+		require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
+		assert.IsType(t, cmd.ArgumentError{}, rootCmd.Execute(), invalid)
+	}
+}
+
+func TestNDJSONOutput(t *testing.T) {
+	stdin := strings.NewReader(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: valid
+---
+# empty
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: invalid
+data: []
+`)
+	rootCmd := cmd.NewRootCommand()
+	var buf bytes.Buffer
+	rootCmd.SetIn(stdin)
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"-", filepath.Join(manifestDir, "configmap.yaml")})
+	require.NoError(t, rootCmd.Flags().Set("output", "ndjson"))
+	require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
+	require.Error(t, rootCmd.Execute())
+
+	var lines []cmd.Result
+	for _, text := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var l cmd.Result
+		require.NoError(t, json.Unmarshal([]byte(text), &l), text)
+		lines = append(lines, l)
+	}
+	require.Len(t, lines, 4)
+	for i, want := range []struct {
+		file     string
+		index    int
+		category cmd.Category
+	}{
+		{"-", 0, cmd.CategoryValid},
+		{"-", 1, cmd.CategoryValid},
+		{"-", 2, cmd.CategorySchemaViolation},
+		{filepath.Join(manifestDir, "configmap.yaml"), 0, cmd.CategoryValid},
+	} {
+		assert.Equal(t, want.file, lines[i].File)
+		assert.Equal(t, want.index, lines[i].Index)
+		assert.Equal(t, want.category, lines[i].Category)
+	}
+}
+
+// Shows that documents are validated as they are read, before the rest of
+// the stream is available
+func TestValidateReaderStreams(t *testing.T) {
+	factory, err := validator.New(openapiclient.NewHardcodedBuiltins("1.30"))
+	require.NoError(t, err)
+
+	r, w := io.Pipe()
+	results := make(chan cmd.Result)
+	done := make(chan error)
+	go func() {
+		done <- cmd.ValidateReader(r, factory, func(res cmd.Result) { results <- res })
+	}()
+
+	_, err = io.WriteString(w, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: first\n---\n")
+	require.NoError(t, err)
+	first := <-results
+	assert.True(t, first.Valid())
+	assert.Equal(t, "first", first.Name)
+
+	_, err = io.WriteString(w, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: second\ndata: []\n")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	second := <-results
+	assert.False(t, second.Valid())
+	assert.Equal(t, 1, second.Index)
+	assert.NoError(t, <-done)
+}
+
+func TestResult(t *testing.T) {
+	factory, err := validator.New(openapiclient.NewHardcodedBuiltins("1.30"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		document string
+		want     cmd.Result
+	}{{
+		name:     "valid",
+		document: `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config", "namespace": "apps"}}`,
+		want: cmd.Result{
+			APIVersion:   "v1",
+			Kind:         "ConfigMap",
+			Namespace:    "apps",
+			Name:         "config",
+			Category:     cmd.CategoryValid,
+			SchemaSource: openapiclient.SourceBuiltin,
+		},
+	}, {
+		name:     "not yaml",
+		document: "apiVersion: v1\nkind: [",
+		want: cmd.Result{
+			Category: cmd.CategoryParseError,
+			Errors:   []cmd.FieldError{{Message: "failed to parse yaml: error converting YAML to JSON: yaml: line 2: did not find expected node content"}},
+		},
+	}, {
+		name:     "unknown field",
+		document: `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config"}, "spec": {}}`,
+		want: cmd.Result{
+			APIVersion:   "v1",
+			Kind:         "ConfigMap",
+			Name:         "config",
+			Category:     cmd.CategoryUnknownField,
+			Errors:       []cmd.FieldError{{Field: "spec", Type: metav1.CauseTypeFieldValueInvalid, Message: "Invalid value: value provided for unknown field"}},
+			SchemaSource: openapiclient.SourceBuiltin,
+		},
+	}, {
+		name:     "misspelled field",
+		document: `{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "web"}, "spec": {"contAIN3rz": [{"name": "web", "image": "nginx"}]}}`,
+		want: cmd.Result{
+			APIVersion:   "v1",
+			Kind:         "Pod",
+			Name:         "web",
+			Category:     cmd.CategoryUnknownField,
+			Errors:       []cmd.FieldError{{Field: "spec.contAIN3rz", Type: metav1.CauseTypeFieldValueInvalid, Message: `Invalid value: value provided for unknown field, did you mean "containers"?`}},
+			SchemaSource: openapiclient.SourceBuiltin,
+		},
+	}, {
+		name:     "schema violation",
+		document: `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config"}, "data": []}`,
+		want: cmd.Result{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Name:       "config",
+			Category:   cmd.CategorySchemaViolation,
+			Errors: []cmd.FieldError{{
+				Field:   "data",
+				Type:    "FieldValueTypeInvalid",
+				Message: `Invalid value: "array": data in body must be of type object: "array"`,
+				Schema: &validator.FieldSchema{
+					Type:        "object",
+					Description: "Data contains the configuration data.",
+				},
+			}},
+			SchemaSource: openapiclient.SourceBuiltin,
+		},
+	}, {
+		name:     "missing schema",
+		document: `{"apiVersion": "example.com/v1", "kind": "Widget", "metadata": {"name": "widget"}}`,
+		want: cmd.Result{
+			APIVersion: "example.com/v1",
+			Kind:       "Widget",
+			Name:       "widget",
+			Category:   cmd.CategoryMissingSchema,
+			Errors:     []cmd.FieldError{{Message: "failed to retrieve validator: failed to locate OpenAPI spec for GV: example.com/v1"}},
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cmd.ValidateDocument([]byte(tt.document), factory)
+			assert.Equal(t, tt.want.Valid(), got.Err() == nil)
+
+			// The JSON representation holds everything but the error
+			data, err := json.Marshal(got)
+			require.NoError(t, err)
+			var decoded cmd.Result
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			assert.Equal(t, tt.want, decoded)
+
+			// Which reports the same status, but for its message
+			wantStatus, gotStatus := got.Status(), decoded.Status()
+			assert.Equal(t, wantStatus.Status, gotStatus.Status)
+			assert.Equal(t, wantStatus.Reason, gotStatus.Reason)
+			assert.Equal(t, wantStatus.Code, gotStatus.Code)
+			if wantStatus.Details != nil {
+				require.NotNil(t, gotStatus.Details)
+				assert.Equal(t, wantStatus.Details.Causes, gotStatus.Details.Causes)
+			}
+			assert.Equal(t, got.Valid(), decoded.Valid())
+		})
+	}
+}
+
+// Shows that a cluster which never responds is given up on, either in favor
+// of the builtin schemas once --cluster-timeout has passed, or altogether once
+// --timeout has
+func TestTimeouts(t *testing.T) {
+	cluster := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer cluster.Close()
+	path := filepath.Join(manifestDir, "configmap.yaml")
+
+	for _, tt := range []struct {
+		name    string
+		flags   map[string]string
+		wantErr bool
+	}{{
+		name:  "cluster timeout",
+		flags: map[string]string{"cluster-timeout": "50ms"},
+	}, {
+		name:    "timeout",
+		flags:   map[string]string{"cluster-timeout": "0", "timeout": "50ms"},
+		wantErr: true,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			rootCmd := cmd.NewRootCommand()
+			rootCmd.SetArgs([]string{path})
+			rootCmd.SetOut(io.Discard)
+			rootCmd.SetErr(io.Discard)
+			require.NoError(t, rootCmd.Flags().Set("kube-server", cluster.URL))
+			require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
+			for flag, value := range tt.flags {
+				require.NoError(t, rootCmd.Flags().Set(flag, value))
+			}
+
+			start := time.Now()
+			err := rootCmd.Execute()
+			assert.Less(t, time.Since(start), 10*time.Second)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, context.DeadlineExceeded.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSchemaProviders(t *testing.T) {
+	path := filepath.Join(manifestDir, "configmap.yaml")
+	for _, tt := range []struct {
+		name    string
+		config  string
+		wantErr string
+	}{{
+		name: "failing provider",
+		config: `providers:
+- name: registry
+  command: does-not-exist
+  priority: fallback
+  timeout: 5s`,
+		wantErr: "schema provider registry failed",
+	}, {
+		name: "missing command",
+		config: `providers:
+- name: registry`,
+		wantErr: "must have a name and command",
+	}, {
+		name: "duplicate name",
+		config: `providers:
+- name: registry
+  command: a
+- name: registry
+  command: b`,
+		wantErr: "schema provider registry is configured more than once",
+	}, {
+		name: "invalid priority",
+		config: `providers:
+- name: registry
+  command: a
+  priority: first`,
+		wantErr: `schema provider registry has invalid priority "first"`,
+	}, {
+		name: "unknown field",
+		config: `providers:
+- name: registry
+  cmd: a`,
+		wantErr: "failed to parse --schema-providers",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			config := filepath.Join(t.TempDir(), "providers.yaml")
+			require.NoError(t, os.WriteFile(config, []byte(tt.config), 0o644))
+
+			rootCmd := cmd.NewRootCommand()
+			rootCmd.SetArgs([]string{path})
+			rootCmd.SetOut(io.Discard)
+			rootCmd.SetErr(io.Discard)
+			require.NoError(t, rootCmd.Flags().Set("schema-providers", config))
+			require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
+
+			err := rootCmd.Execute()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSchemaCatalog(t *testing.T) {
+	path := filepath.Join(manifestDir, "error_cel_basic.yaml")
+	catalog := filepath.Join(testcasesDir, "schema-catalog")
+	for _, flag := range []string{
+		catalog,
+		filepath.Join(catalog, "{{.Group}}", "{{.ResourceKind}}_{{.ResourceAPIVersion}}.json"),
+	} {
+		t.Run(flag, func(t *testing.T) {
+			var out bytes.Buffer
+			rootCmd := cmd.NewRootCommand()
+			rootCmd.SetArgs([]string{path})
+			rootCmd.SetOut(&out)
+			rootCmd.SetErr(io.Discard)
+			require.NoError(t, rootCmd.Flags().Set("schema-catalog", flag))
+			require.NoError(t, rootCmd.Flags().Set("output", "json"))
+			require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
+
+			assert.Error(t, rootCmd.Execute())
+			assert.Contains(t, out.String(), "Must be positive non-zero")
+		})
+	}
+}
+
+func TestLocalSchemasOpenAPIV2(t *testing.T) {
+	// ConfigMaps whose data must be made of integers, which local schemas
+	// take precedence over the builtin ones with
+	swagger := filepath.Join(t.TempDir(), "swagger.json")
+	require.NoError(t, os.WriteFile(swagger, []byte(`{
+		"swagger": "2.0",
+		"paths": {},
+		"definitions": {
+			"io.k8s.api.core.v1.ConfigMap": {
+				"type": "object",
+				"properties": {
+					"apiVersion": {"type": "string"},
+					"kind": {"type": "string"},
+					"metadata": {"type": "object"},
+					"data": {"type": "object", "additionalProperties": {"type": "integer"}}
+				},
+				"x-kubernetes-group-version-kind": [{"group": "", "version": "v1", "kind": "ConfigMap"}]
+			}
+		}
+	}`), 0o644))
+
+	var out bytes.Buffer
+	rootCmd := cmd.NewRootCommand()
+	rootCmd.SetArgs([]string{filepath.Join(manifestDir, "configmap.yaml")})
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(io.Discard)
+	require.NoError(t, rootCmd.Flags().Set("local-schemas", swagger))
+	require.NoError(t, rootCmd.Flags().Set("output", "json"))
+	require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
+
+	assert.Error(t, rootCmd.Execute())
+	assert.Contains(t, out.String(), "data.key")
+}
+
+// fakeCluster serves the documents of the builtins of the given version and
+// of the testcase CRDs, as a cluster does
+func fakeCluster(t *testing.T, version string) *httptest.Server {
+	t.Helper()
+	paths, err := openapiclient.NewComposite(
+		openapiclient.NewHardcodedBuiltins(version),
+		openapiclient.NewLocalCRDFiles(os.DirFS(crdsDir)),
+	).Paths()
+	require.NoError(t, err)
+	documents := map[string][]byte{}
+	discovery := map[string]any{}
+	for gvPath, gv := range paths {
+		document, err := gv.Schema("application/json")
+		require.NoError(t, err)
+		documents["/openapi/v3/"+gvPath] = document
+		discovery[gvPath] = map[string]string{"serverRelativeURL": "/openapi/v3/" + gvPath + "?hash=0"}
+	}
+	// Paths which are not group versions are left out of snapshots
+	discovery["apis"] = map[string]string{"serverRelativeURL": "/openapi/v3/apis?hash=0"}
+	index, err := json.Marshal(map[string]any{"paths": discovery})
+	require.NoError(t, err)
+	documents["/openapi/v3"] = index
+	documents["/version"] = []byte(`{"major": "1", "minor": "` + strings.TrimPrefix(version, "1.") + `", "gitVersion": "v` + version + `.0"}`)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		document, ok := documents[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(document)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSchemasPull(t *testing.T) {
+	cluster := fakeCluster(t, "1.30")
+	dir := t.TempDir()
+
+	var out bytes.Buffer
+	rootCmd := cmd.NewRootCommand()
+	rootCmd.SetArgs([]string{"schemas", "pull", "--kube-server", cluster.URL, "--out", dir})
+	rootCmd.SetOut(&out)
+	require.NoError(t, rootCmd.Execute())
+	assert.Contains(t, out.String(), "of v1.30.0 to "+dir)
+
+	data, err := os.ReadFile(filepath.Join(dir, openapiclient.SnapshotManifestFile))
+	require.NoError(t, err)
+	var manifest openapiclient.SnapshotManifest
+	require.NoError(t, json.Unmarshal(data, &manifest))
+	assert.Equal(t, "v1.30.0", manifest.ServerVersion.GitVersion)
+	assert.Contains(t, manifest.GroupVersions, "apis/stable.example.com/v1")
+	assert.NotContains(t, manifest.GroupVersions, "apis")
+
+	// The snapshot validates CRDs without the cluster
+	for manifest, wantErr := range map[string]bool{
+		"configmap.yaml":       false,
+		"error_cel_basic.yaml": true,
+	} {
+		rootCmd := cmd.NewRootCommand()
+		rootCmd.SetArgs([]string{filepath.Join(manifestDir, manifest)})
+		rootCmd.SetOut(io.Discard)
+		rootCmd.SetErr(io.Discard)
+		require.NoError(t, rootCmd.Flags().Set("local-schemas", dir))
+		require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
+		err := rootCmd.Execute()
+		if wantErr {
+			var validationErr cmd.ValidationError
+			assert.ErrorAs(t, err, &validationErr, manifest)
+		} else {
+			assert.NoError(t, err, manifest)
+		}
+	}
+}
+
+func TestLock(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "kubectl-validate.lock")
+	var out bytes.Buffer
+	run := func(flags map[string]string) error {
+		out.Reset()
+		rootCmd := cmd.NewRootCommand()
+		rootCmd.SetArgs([]string{filepath.Join(manifestDir, "configmap.yaml")})
+		rootCmd.SetOut(&out)
+		rootCmd.SetErr(io.Discard)
+		require.NoError(t, rootCmd.Flags().Set("version", "1.30"))
+		require.NoError(t, rootCmd.Flags().Set("lock-file", lockFile))
+		require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
+		for flag, value := range flags {
+			require.NoError(t, rootCmd.Flags().Set(flag, value))
+		}
+		return rootCmd.Execute()
+	}
+
+	assert.ErrorContains(t, run(map[string]string{"locked": "true"}), "write it with --write-lock")
+	require.NoError(t, run(map[string]string{"write-lock": "true"}))
+	data, err := os.ReadFile(lockFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "kind: SchemaLock")
+	assert.Contains(t, string(data), "api/v1:")
+	assert.Contains(t, string(data), "source: builtin")
+
+	require.NoError(t, run(map[string]string{"locked": "true"}))
+	assert.ErrorContains(t, run(map[string]string{"locked": "true", "write-lock": "true"}), "cannot be used together")
+
+	// A lock of other schemas fails validation
+	tampered := regexp.MustCompile(`sha256: [0-9a-f]+`).ReplaceAll(data, []byte("sha256: 0123"))
+	require.NoError(t, os.WriteFile(lockFile, tampered, 0o644))
+	err = run(map[string]string{"locked": "true", "output": "json"})
+	var validationErr cmd.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Contains(t, out.String(), "changed since they were locked")
+}
+
+func TestSchemaSources(t *testing.T) {
+	cluster := fakeCluster(t, "1.30")
+	for _, tt := range []struct {
+		name     string
+		config   string
+		flags    map[string]string
+		manifest string
+		wantOut  string
+		wantErr  string
+	}{{
+		name: "local CRDs relative to the file",
+		config: `sources:
+- type: localCRDs
+  path: CRDS
+- type: embedded`,
+		manifest: "error_cel_basic.yaml",
+		wantOut:  "Must be positive non-zero",
+		wantErr:  "validation failed",
+	}, {
+		name: "embedded only",
+		config: `sources:
+- type: embedded
+  builtinPatches: true`,
+		manifest: "configmap.yaml",
+	}, {
+		// Without CRDs, the kind is unknown
+		name: "no CRDs",
+		config: `sources:
+- type: embedded`,
+		manifest: "error_cel_basic.yaml",
+		wantOut:  "failed to locate OpenAPI spec for GV: stable.example.com/v1",
+		wantErr:  "validation failed",
+	}, {
+		name: "fallback to the cluster",
+		config: `sources:
+- type: fallback
+  sources:
+  - type: exec
+    name: broken
+    command: does-not-exist
+  - type: cluster
+    timeout: 5s`,
+		manifest: "error_cel_basic.yaml",
+		wantOut:  "Must be positive non-zero",
+		wantErr:  "validation failed",
+	}, {
+		name: "invalid type",
+		config: `sources:
+- type: merge
+  sources:
+  - type: embedded
+  - type: registry`,
+		manifest: "configmap.yaml",
+		wantErr:  `sources[0].sources[1] of ` + "CONFIG" + `: invalid type "registry"`,
+	}, {
+		name: "missing path",
+		config: `sources:
+- type: catalog`,
+		manifest: "configmap.yaml",
+		wantErr:  "catalog source must have a path",
+	}, {
+		name:     "no sources",
+		config:   `sources: []`,
+		manifest: "configmap.yaml",
+		wantErr:  "declares no sources",
+	}, {
+		name: "with source flags",
+		config: `sources:
+- type: embedded`,
+		flags:    map[string]string{"local-crds": crdsDir},
+		manifest: "configmap.yaml",
+		wantErr:  "--schema-sources cannot be used with --local-schemas",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			crds, err := filepath.Abs(crdsDir)
+			require.NoError(t, err)
+			crds, err = filepath.Rel(dir, crds)
+			require.NoError(t, err)
+			config := filepath.Join(dir, "sources.yaml")
+			require.NoError(t, os.WriteFile(config, []byte(strings.ReplaceAll(tt.config, "CRDS", crds)), 0o644))
+
+			var out bytes.Buffer
+			rootCmd := cmd.NewRootCommand()
+			rootCmd.SetArgs([]string{filepath.Join(manifestDir, tt.manifest)})
+			rootCmd.SetOut(&out)
+			rootCmd.SetErr(io.Discard)
+			require.NoError(t, rootCmd.Flags().Set("schema-sources", config))
+			require.NoError(t, rootCmd.Flags().Set("kube-server", cluster.URL))
+			require.NoError(t, rootCmd.Flags().Set("output", "json"))
+			require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
+			for flag, value := range tt.flags {
+				require.NoError(t, rootCmd.Flags().Set(flag, value))
+			}
+
+			err = rootCmd.Execute()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, strings.ReplaceAll(tt.wantErr, "CONFIG", config))
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Contains(t, out.String(), tt.wantOut)
+		})
+	}
+}
+
+func TestProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+	crds, err := filepath.Abs(crdsDir)
+	require.NoError(t, err)
+	celBasic, err := os.ReadFile(filepath.Join(manifestDir, "error_cel_basic.yaml"))
+	require.NoError(t, err)
+	files := map[string]string{
+		".kubectl-validate.yaml": `profiles:
+  prod:
+    version: "1.30"
+    sources:
+    - type: localCRDs
+      path: ` + crds + `
+    - type: embedded
+  edge:
+    version: "1.28"
+    fieldValidation: Ignore
+    ignoreKinds: [Secret]
+rules:
+- paths: ["clusters/prod/**"]
+  profile: prod
+- paths: ["clusters/edge/**"]
+  profile: edge
+`,
+		"clusters/prod/apps/cel.yaml": string(celBasic),
+		"clusters/edge/cel.yaml":      string(celBasic),
+		"clusters/edge/unknown.yaml":  `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config"}, "dat": {}}`,
+		"clusters/edge/secret.yaml":   `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "secret"}, "data": "invalid"}`,
+		"other/unknown.yaml":          `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config"}, "dat": {}}`,
+	}
+	for path, data := range files {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	}
+
+	run := func(flags map[string]string) (map[string][]metav1.Status, error) {
+		var out bytes.Buffer
+		rootCmd := cmd.NewRootCommand()
+		rootCmd.SetArgs([]string{filepath.Join(dir, "clusters"), filepath.Join(dir, "other")})
+		rootCmd.SetOut(&out)
+		rootCmd.SetErr(io.Discard)
+		require.NoError(t, rootCmd.Flags().Set("output", "json"))
+		require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
+		for flag, value := range flags {
+			require.NoError(t, rootCmd.Flags().Set(flag, value))
+		}
+		err := rootCmd.Execute()
+		var res map[string][]metav1.Status
+		if out.Len() > 0 {
+			require.NoError(t, json.Unmarshal(out.Bytes(), &res))
+		}
+		return res, err
+	}
+	message := func(res map[string][]metav1.Status, path string) string {
+		statuses := res[filepath.Join(dir, filepath.FromSlash(path))]
+		require.Len(t, statuses, 1, path)
+		return statuses[0].Message
+	}
+
+	res, err := run(nil)
+	assert.Error(t, err)
+	// Validated against the CRDs of its profile
+	assert.Contains(t, message(res, "clusters/prod/apps/cel.yaml"), "Must be positive non-zero")
+	// Whose CRDs are not known to other profiles
+	assert.Contains(t, message(res, "clusters/edge/cel.yaml"), "failed to locate OpenAPI spec")
+	assert.Empty(t, message(res, "clusters/edge/unknown.yaml"))
+	assert.Equal(t, "skipped: kind Secret is ignored by profile edge", message(res, "clusters/edge/secret.yaml"))
+	// Manifests matching no rule are validated as configured by flags
+	assert.Contains(t, message(res, "other/unknown.yaml"), "unknown field")
+
+	res, err = run(map[string]string{"no-config": "true"})
+	assert.Error(t, err)
+	assert.Contains(t, message(res, "clusters/prod/apps/cel.yaml"), "failed to locate OpenAPI spec")
+	assert.Contains(t, message(res, "clusters/edge/unknown.yaml"), "unknown field")
+
+	// Profiles without ignoreKinds keep those of the flags, others replace them
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "clusters/prod/secret.yaml"), []byte(files["clusters/edge/secret.yaml"]), 0o644))
+	res, err = run(map[string]string{"ignore-kinds": "Secret,ConfigMap"})
+	assert.Error(t, err)
+	assert.Equal(t, "skipped: kind Secret is ignored by --ignore-kinds", message(res, "clusters/prod/secret.yaml"))
+	assert.Equal(t, "skipped: kind ConfigMap is ignored by --ignore-kinds", message(res, "other/unknown.yaml"))
+	assert.Equal(t, "skipped: kind Secret is ignored by profile edge", message(res, "clusters/edge/secret.yaml"))
+	assert.Empty(t, message(res, "clusters/edge/unknown.yaml"))
+	require.NoError(t, os.Remove(filepath.Join(dir, "clusters/prod/secret.yaml")))
+
+	_, err = run(map[string]string{"versions": "1.29,1.30"})
+	assert.ErrorContains(t, err, "--versions cannot be used with profiles")
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalid, []byte(`rules:
+- paths: ["**"]
+  profile: staging
+`), 0o644))
+	_, err = run(map[string]string{"config": invalid})
+	assert.ErrorContains(t, err, `refers to unknown profile "staging"`)
+}
+
+func TestFilters(t *testing.T) {
+	dir := t.TempDir()
+	for path, data := range map[string]string{
+		"web.yaml":               `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "web", "namespace": "team-a", "labels": {"app": "web"}}}`,
+		"db.yaml":                `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "db", "namespace": "team-b", "labels": {"app": "db"}}, "data": "invalid"}`,
+		"secret.yaml":            `{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "secret"}, "data": "invalid"}`,
+		"chart/values.yaml":      `replicas: 3`,
+		"kustomization.yaml":     `resources: []`,
+		utils.IgnoreFile:         "kustomization.yaml\n",
+		"chart/templates/x.json": `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "x"}}`,
+	} {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	}
+
+	for _, tt := range []struct {
+		name  string
+		flags map[string]string
+		// Category of each file validated
+		want    map[string]cmd.Category
+		wantErr bool
+	}{{
+		name:  "exclude",
+		flags: map[string]string{"exclude": "**/values.yaml"},
+		want: map[string]cmd.Category{
+			"chart/templates/x.json": cmd.CategoryValid,
+			"db.yaml":                cmd.CategorySchemaViolation,
+			"secret.yaml":            cmd.CategorySchemaViolation,
+			"web.yaml":               cmd.CategoryValid,
+		},
+		wantErr: true,
+	}, {
+		name:  "include",
+		flags: map[string]string{"include": "*.yaml", "namespace": "team-a"},
+		want: map[string]cmd.Category{
+			"db.yaml":     cmd.CategorySkipped,
+			"secret.yaml": cmd.CategorySchemaViolation,
+			"web.yaml":    cmd.CategoryValid,
+		},
+		wantErr: true,
+	}, {
+		name:  "selector",
+		flags: map[string]string{"include": "*.yaml", "selector": "app=web"},
+		want: map[string]cmd.Category{
+			"db.yaml":     cmd.CategorySkipped,
+			"secret.yaml": cmd.CategorySkipped,
+			"web.yaml":    cmd.CategoryValid,
+		},
+	}, {
+		name:  "kinds",
+		flags: map[string]string{"include": "*.yaml", "kinds": "Secret"},
+		want: map[string]cmd.Category{
+			"db.yaml":     cmd.CategorySkipped,
+			"secret.yaml": cmd.CategorySchemaViolation,
+			"web.yaml":    cmd.CategorySkipped,
+		},
+		wantErr: true,
+	}, {
+		name:  "skip kinds",
+		flags: map[string]string{"include": "*.yaml", "skip-kinds": "Secret,ConfigMap.example.com", "namespace": "team-a"},
+		want: map[string]cmd.Category{
+			"db.yaml":     cmd.CategorySkipped,
+			"secret.yaml": cmd.CategorySkipped,
+			"web.yaml":    cmd.CategoryValid,
+		},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			rootCmd := cmd.NewRootCommand()
+			rootCmd.SetArgs([]string{dir})
+			rootCmd.SetOut(&out)
+			rootCmd.SetErr(io.Discard)
+			require.NoError(t, rootCmd.Flags().Set("output", "ndjson"))
+			require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
+			for flag, value := range tt.flags {
+				require.NoError(t, rootCmd.Flags().Set(flag, value))
+			}
+			err := rootCmd.Execute()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			got := map[string]cmd.Category{}
+			decoder := json.NewDecoder(&out)
+			for decoder.More() {
+				var res cmd.Result
+				require.NoError(t, decoder.Decode(&res))
+				rel, err := filepath.Rel(dir, res.File)
+				require.NoError(t, err)
+				got[filepath.ToSlash(rel)] = res.Category
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	for flag, value := range map[string]string{"selector": "app in (", "kinds": "apps/v1", "exclude": "["} {
+		rootCmd := cmd.NewRootCommand()
+		rootCmd.SetArgs([]string{dir})
+		rootCmd.SetOut(io.Discard)
+		rootCmd.SetErr(io.Discard)
+		require.NoError(t, rootCmd.Flags().Set(flag, value))
+		require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
+		assert.IsType(t, cmd.ArgumentError{}, rootCmd.Execute(), flag)
+	}
+}
+
+func TestDocumentPolicies(t *testing.T) {
+	dir := t.TempDir()
+	for path, data := range map[string]string{
+		"web.yaml":    `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "web"}}`,
+		"widget.yaml": `{"apiVersion": "example.com/v1", "kind": "Widget", "metadata": {"name": "widget"}}`,
+		"values.yaml": `replicas: 3`,
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(data), 0o644))
+	}
+	widget, values := filepath.Join(dir, "widget.yaml"), filepath.Join(dir, "values.yaml")
+
+	run := func(t *testing.T, output string, flags map[string]string) (string, error) {
+		var out bytes.Buffer
+		rootCmd := cmd.NewRootCommand()
+		rootCmd.SetArgs([]string{dir})
+		rootCmd.SetOut(&out)
+		rootCmd.SetErr(io.Discard)
+		require.NoError(t, rootCmd.Flags().Set("output", output))
+		require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
+		for flag, value := range flags {
+			require.NoError(t, rootCmd.Flags().Set(flag, value))
+		}
+		err := rootCmd.Execute()
+		return out.String(), err
+	}
+	statuses := func(t *testing.T, flags map[string]string) (map[string][]metav1.Status, error) {
+		out, err := run(t, "json", flags)
+		res := map[string][]metav1.Status{}
+		require.NoError(t, json.Unmarshal([]byte(out), &res))
+		return res, err
+	}
+
+	// Failures are told apart from schema violations and internal errors
+	res, err := statuses(t, nil)
+	assert.IsType(t, cmd.ValidationError{}, err)
+	assert.Equal(t, metav1.StatusFailure, res[widget][0].Status)
+	assert.Equal(t, cmd.StatusReasonMissingSchema, res[widget][0].Reason)
+	assert.EqualValues(t, 404, res[widget][0].Code)
+	assert.Equal(t, metav1.StatusFailure, res[values][0].Status)
+	assert.Equal(t, cmd.StatusReasonNotKubernetesObject, res[values][0].Reason)
+	assert.EqualValues(t, 400, res[values][0].Code)
+
+	res, err = statuses(t, map[string]string{"missing-schemas": "warn", "non-k8s-documents": "skip"})
+	assert.NoError(t, err)
+	assert.Equal(t, metav1.StatusSuccess, res[widget][0].Status)
+	assert.Equal(t, cmd.StatusReasonMissingSchema, res[widget][0].Reason)
+	require.NotNil(t, res[widget][0].Details)
+	require.Len(t, res[widget][0].Details.Causes, 1)
+	assert.Equal(t, cmd.CauseTypeMissingSchema, res[widget][0].Details.Causes[0].Type)
+	assert.Equal(t, metav1.StatusSuccess, res[values][0].Status)
+	assert.Equal(t, cmd.StatusReasonNotKubernetesObject, res[values][0].Reason)
+
+	res, err = statuses(t, map[string]string{"missing-schemas": "skip"})
+	assert.IsType(t, cmd.ValidationError{}, err)
+	assert.Equal(t, metav1.StatusSuccess, res[widget][0].Status)
+	assert.Nil(t, res[widget][0].Details)
+	assert.Equal(t, metav1.StatusFailure, res[values][0].Status)
+
+	out, err := run(t, "human", map[string]string{"missing-schemas": "warn"})
+	assert.IsType(t, cmd.ValidationError{}, err)
+	assert.Contains(t, out, "MISSING SCHEMA")
+	assert.Contains(t, out, "NOT A KUBERNETES OBJECT")
+	assert.Contains(t, out, "3 documents: 1 valid, 0 invalid, 0 skipped, 1 missing schemas, 1 not Kubernetes objects")
+
+	for flag, value := range map[string]string{"missing-schemas": "ignore", "non-k8s-documents": "warn"} {
+		_, err := run(t, "json", map[string]string{flag: value})
+		assert.IsType(t, cmd.ArgumentError{}, err, flag)
+	}
+
+	// Mistakes in the kind or group version of known objects are not skipped
+	for name, data := range map[string]string{
+		"typo.yaml":    `{"apiVersion": "v1", "kind": "ConfigMapp", "metadata": {"name": "typo"}}`,
+		"ingress.yaml": `{"apiVersion": "extensions/v1", "kind": "Ingress", "metadata": {"name": "ingress"}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+
+			var out bytes.Buffer
+			rootCmd := cmd.NewRootCommand()
+			rootCmd.SetArgs([]string{path, "--missing-schemas", "skip", "--output", "json", "--cache-dir", ""})
+			rootCmd.SetOut(&out)
+			rootCmd.SetErr(io.Discard)
+			assert.IsType(t, cmd.ValidationError{}, rootCmd.Execute())
+
+			res := map[string][]metav1.Status{}
+			require.NoError(t, json.Unmarshal(out.Bytes(), &res))
+			require.Len(t, res[path], 1)
+			assert.Equal(t, metav1.StatusFailure, res[path][0].Status)
+			assert.Equal(t, cmd.StatusReasonMissingSchema, res[path][0].Reason)
+		})
+	}
+}
+
+func TestFieldSchemas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deployment.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "web"}, "spec": {"replicas": "three", "selector": {}, "template": {}}}`), 0o644))
+
+	run := func(output string) (string, string) {
+		var out, errOut bytes.Buffer
+		rootCmd := cmd.NewRootCommand()
+		rootCmd.SetArgs([]string{path})
+		rootCmd.SetOut(&out)
+		rootCmd.SetErr(&errOut)
+		require.NoError(t, rootCmd.Flags().Set("output", output))
+		require.NoError(t, rootCmd.Flags().Set("cache-dir", ""))
+		assert.IsType(t, cmd.ValidationError{}, rootCmd.Execute())
+		return out.String(), errOut.String()
+	}
+
+	_, errOut := run("human")
+	assert.Contains(t, errOut, "spec.replicas: integer (int32) - Number of desired pods.")
+
+	out, _ := run("json")
+	var res map[string][]struct {
+		FieldSchemas map[string]validator.FieldSchema `json:"fieldSchemas"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &res))
+	require.Len(t, res[path], 1)
+	require.Contains(t, res[path][0].FieldSchemas, "spec.replicas")
+	assert.Equal(t, "integer", res[path][0].FieldSchemas["spec.replicas"].Type)
+	assert.Equal(t, "int32", res[path][0].FieldSchemas["spec.replicas"].Format)
+}
+
+func TestNativeValidationRequiresNativeModule(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "configmap.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata: {name: config}\n"), 0o644))
+
+	rootCmd := cmd.NewRootCommand()
+	rootCmd.SetArgs([]string{path, "--native-validation", "--cache-dir", ""})
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	err := rootCmd.Execute()
+	assert.IsType(t, cmd.ArgumentError{}, err)
+	assert.ErrorContains(t, err, "native module")
+}
@@ -0,0 +1,277 @@
+package deprecation
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/version"
+	apimachineryversion "k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+	"sigs.k8s.io/kubectl-validate/pkg/utils"
+)
+
+// Lifecycle describes when a built-in API version was deprecated and removed,
+// and what should be used instead.
+type Lifecycle struct {
+	GroupVersionKind schema.GroupVersionKind
+
+	// Release in which the API was deprecated. Nil if unknown.
+	Deprecated *version.Version
+
+	// Release in which the API is no longer served. Nil if unknown.
+	Removed *version.Version
+
+	// GVK to migrate to. Empty if unknown.
+	Replacement schema.GroupVersionKind
+}
+
+// Implemented by k8s.io/api types through zz_generated.prerelease-lifecycle.go
+type deprecatedAPI interface {
+	APILifecycleDeprecated() (major, minor int)
+}
+
+type removedAPI interface {
+	APILifecycleRemoved() (major, minor int)
+}
+
+type replacedAPI interface {
+	APILifecycleReplacement() schema.GroupVersionKind
+}
+
+// DeprecatedIn returns true if the API is deprecated as of the given release
+func (l Lifecycle) DeprecatedIn(v *version.Version) bool {
+	return l.Deprecated != nil && v != nil && v.AtLeast(l.Deprecated)
+}
+
+// RemovedIn returns true if the API is no longer served as of the given release
+func (l Lifecycle) RemovedIn(v *version.Version) bool {
+	return l.Removed != nil && v != nil && v.AtLeast(l.Removed)
+}
+
+func (l Lifecycle) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s", l.GroupVersionKind.GroupVersion(), l.GroupVersionKind.Kind)
+	if l.Deprecated != nil {
+		fmt.Fprintf(&sb, " is deprecated in v%s", l.Deprecated)
+		if l.Removed != nil {
+			fmt.Fprintf(&sb, " and removed in v%s", l.Removed)
+		}
+	} else if l.Removed != nil {
+		fmt.Fprintf(&sb, " is removed in v%s", l.Removed)
+	}
+	if !l.Replacement.Empty() {
+		fmt.Fprintf(&sb, ", use %s %s instead", l.Replacement.GroupVersion(), l.Replacement.Kind)
+	}
+	return sb.String()
+}
+
+// ForGVK looks up the lifecycle of a built-in GVK. Returns false if the GVK is
+// not known to be deprecated or removed.
+//
+// Upstream prerelease-lifecycle data is consulted first. GVKs which do not
+// have it are looked up in the embedded builtin schemas: a kind which was
+// served in its groupversion by an earlier version but not the latest one is
+// considered removed, even if the groupversion itself is still served.
+func ForGVK(gvk schema.GroupVersionKind) (Lifecycle, bool) {
+	if res, ok := fromScheme(gvk); ok {
+		return res, true
+	}
+	return fromBuiltins(gvk)
+}
+
+func fromScheme(gvk schema.GroupVersionKind) (Lifecycle, bool) {
+	obj, err := scheme.Scheme.New(gvk)
+	if err != nil {
+		return Lifecycle{}, false
+	}
+
+	res := Lifecycle{GroupVersionKind: gvk}
+	if d, ok := obj.(deprecatedAPI); ok {
+		if major, minor := d.APILifecycleDeprecated(); major != 0 || minor != 0 {
+			res.Deprecated = version.MajorMinor(uint(major), uint(minor))
+		}
+	}
+	if r, ok := obj.(removedAPI); ok {
+		if major, minor := r.APILifecycleRemoved(); major != 0 || minor != 0 {
+			res.Removed = version.MajorMinor(uint(major), uint(minor))
+		}
+	}
+	if r, ok := obj.(replacedAPI); ok {
+		res.Replacement = r.APILifecycleReplacement()
+	}
+
+	if res.Deprecated == nil && res.Removed == nil {
+		return Lifecycle{}, false
+	}
+	return res, true
+}
+
+func fromBuiltins(gvk schema.GroupVersionKind) (Lifecycle, bool) {
+	index := loadBuiltinsIndex()
+	if len(index.versions) == 0 {
+		return Lifecycle{}, false
+	}
+
+	gvPath := utils.GroupVersionPath(gvk.GroupVersion())
+	var lastServed *version.Version
+	for i := len(index.versions) - 1; i >= 0; i-- {
+		v := index.versions[i]
+		if index.served[v.String()].Has(gvPath) && servedKinds(v, gvk.GroupVersion()).Has(gvk) {
+			lastServed = v
+			break
+		}
+	}
+
+	// Either never served by a version we know of, or still being served
+	latest := index.versions[len(index.versions)-1]
+	if lastServed == nil || lastServed == latest {
+		return Lifecycle{}, false
+	}
+
+	return Lifecycle{
+		GroupVersionKind: gvk,
+		Removed:          lastServed.AddMinor(1),
+		Replacement:      index.replacements[gvk.GroupKind()],
+	}, true
+}
+
+type builtinsIndex struct {
+	// Sorted ascending
+	versions []*version.Version
+
+	// Groupversion paths served by each version
+	served map[string]sets.Set[string]
+
+	// Most mature GVK serving each kind in the latest version
+	replacements map[schema.GroupKind]schema.GroupVersionKind
+}
+
+var loadBuiltinsIndex = sync.OnceValue(func() builtinsIndex {
+	res := builtinsIndex{served: map[string]sets.Set[string]{}}
+	for _, v := range openapiclient.HardcodedBuiltinVersions {
+		parsed, err := version.ParseMajorMinor(v)
+		if err != nil {
+			continue
+		}
+		paths, err := openapiclient.NewHardcodedBuiltins(v).Paths()
+		if err != nil {
+			continue
+		}
+		res.versions = append(res.versions, parsed)
+		res.served[parsed.String()] = sets.KeySet(paths)
+	}
+	sort.Slice(res.versions, func(i, j int) bool {
+		return res.versions[i].LessThan(res.versions[j])
+	})
+	if len(res.versions) > 0 {
+		res.replacements = loadReplacements(res.versions[len(res.versions)-1])
+	}
+	return res
+})
+
+// Parsed lazily since most lookups only need the kinds of the latest version
+var servedKindsCache sync.Map
+
+// servedKinds returns the kinds served in the groupversion by the given
+// version, according to the x-kubernetes-group-version-kind extensions of its
+// embedded builtin schemas.
+func servedKinds(v *version.Version, gv schema.GroupVersion) sets.Set[schema.GroupVersionKind] {
+	gvPath := utils.GroupVersionPath(gv)
+	key := v.String() + "/" + gvPath
+	if cached, ok := servedKindsCache.Load(key); ok {
+		return cached.(sets.Set[schema.GroupVersionKind])
+	}
+
+	res := sets.New[schema.GroupVersionKind]()
+	for _, gvk := range loadGVKs(v, gvPath) {
+		if gvk.GroupVersion() == gv {
+			res.Insert(gvk)
+		}
+	}
+	servedKindsCache.Store(key, res)
+	return res
+}
+
+func loadGVKs(v *version.Version, gvPath string) []schema.GroupVersionKind {
+	paths, err := openapiclient.NewHardcodedBuiltins(v.String()).Paths()
+	if err != nil || paths[gvPath] == nil {
+		return nil
+	}
+	data, err := paths[gvPath].Schema("application/json")
+	if err != nil {
+		return nil
+	}
+	var parsed spec3.OpenAPI
+	if err := json.Unmarshal(data, &parsed); err != nil || parsed.Components == nil {
+		return nil
+	}
+	var res []schema.GroupVersionKind
+	for _, def := range parsed.Components.Schemas {
+		res = append(res, utils.ExtractExtensionGVKs(def.Extensions)...)
+	}
+	return res
+}
+
+// Indexes the latest embedded builtins by the most mature version of each
+// group which serves a kind.
+func loadReplacements(latest *version.Version) map[schema.GroupKind]schema.GroupVersionKind {
+	res := map[schema.GroupKind]schema.GroupVersionKind{}
+	paths, err := openapiclient.NewHardcodedBuiltins(latest.String()).Paths()
+	if err != nil {
+		return res
+	}
+
+	candidates := make([]string, 0, len(paths))
+	for k := range paths {
+		candidates = append(candidates, k)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return apimachineryversion.CompareKubeAwareVersionStrings(path.Base(candidates[i]), path.Base(candidates[j])) > 0
+	})
+
+	for _, k := range candidates {
+		gv, err := utils.ParseGroupVersionPath(k)
+		if err != nil {
+			continue
+		}
+		data, err := paths[k].Schema("application/json")
+		if err != nil {
+			continue
+		}
+		var parsed spec3.OpenAPI
+		if err := json.Unmarshal(data, &parsed); err != nil || parsed.Components == nil {
+			continue
+		}
+		for _, def := range parsed.Components.Schemas {
+			for _, candidate := range utils.ExtractExtensionGVKs(def.Extensions) {
+				if candidate.Group != gv.Group {
+					continue
+				}
+				if _, ok := res[candidate.GroupKind()]; !ok {
+					res[candidate.GroupKind()] = candidate
+				}
+			}
+		}
+	}
+	return res
+}
+
+// Served reports whether the groupversion is served by the given release,
+// according to the embedded builtin schemas. known is false if there are no
+// embedded schemas for the release.
+func Served(gv schema.GroupVersion, v *version.Version) (served bool, known bool) {
+	index := loadBuiltinsIndex()
+	paths, ok := index.served[version.MajorMinor(v.Major(), v.Minor()).String()]
+	if !ok {
+		return false, false
+	}
+	return paths.Has(utils.GroupVersionPath(gv)), true
+}
@@ -0,0 +1,84 @@
+package deprecation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+func TestForGVK(t *testing.T) {
+	tests := []struct {
+		name            string
+		gvk             schema.GroupVersionKind
+		want            bool
+		wantDeprecated  string
+		wantRemoved     string
+		wantReplacement schema.GroupVersionKind
+	}{{
+		name:            "prerelease lifecycle",
+		gvk:             schema.GroupVersionKind{Group: "policy", Version: "v1beta1", Kind: "PodDisruptionBudget"},
+		want:            true,
+		wantDeprecated:  "1.21",
+		wantRemoved:     "1.25",
+		wantReplacement: schema.GroupVersionKind{Group: "policy", Version: "v1", Kind: "PodDisruptionBudget"},
+	}, {
+		name:            "removed before embedded builtins",
+		gvk:             schema.GroupVersionKind{Group: "extensions", Version: "v1beta1", Kind: "Ingress"},
+		want:            true,
+		wantDeprecated:  "1.14",
+		wantRemoved:     "1.22",
+		wantReplacement: schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"},
+	}, {
+		name:            "embedded builtins",
+		gvk:             schema.GroupVersionKind{Group: "node.k8s.io", Version: "v1alpha1", Kind: "RuntimeClass"},
+		want:            true,
+		wantRemoved:     "1.24",
+		wantReplacement: schema.GroupVersionKind{Group: "node.k8s.io", Version: "v1", Kind: "RuntimeClass"},
+	}, {
+		name:        "groupversion still served without the kind",
+		gvk:         schema.GroupVersionKind{Group: "resource.k8s.io", Version: "v1alpha3", Kind: "PodSchedulingContext"},
+		want:        true,
+		wantRemoved: "1.32",
+	}, {
+		name: "kind never served by the groupversion",
+		gvk:  schema.GroupVersionKind{Group: "resource.k8s.io", Version: "v1alpha3", Kind: "Deployment"},
+	}, {
+		name: "GA",
+		gvk:  schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+	}, {
+		name: "CRD",
+		gvk:  schema.GroupVersionKind{Group: "stable.example.com", Version: "v1", Kind: "CELBasic"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ForGVK(tt.gvk)
+			require.Equal(t, tt.want, ok)
+			if !tt.want {
+				return
+			}
+			if len(tt.wantDeprecated) > 0 {
+				require.NotNil(t, got.Deprecated)
+				require.Equal(t, tt.wantDeprecated, got.Deprecated.String())
+			} else {
+				require.Nil(t, got.Deprecated)
+			}
+			require.NotNil(t, got.Removed)
+			require.Equal(t, tt.wantRemoved, got.Removed.String())
+			require.Equal(t, tt.wantReplacement, got.Replacement)
+		})
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	l, ok := ForGVK(schema.GroupVersionKind{Group: "policy", Version: "v1beta1", Kind: "PodDisruptionBudget"})
+	require.True(t, ok)
+
+	require.False(t, l.DeprecatedIn(version.MustParseGeneric("1.20")))
+	require.True(t, l.DeprecatedIn(version.MustParseGeneric("1.21")))
+	require.False(t, l.RemovedIn(version.MustParseGeneric("1.24.3")))
+	require.True(t, l.RemovedIn(version.MustParseGeneric("1.25.0")))
+	require.False(t, l.RemovedIn(nil))
+	require.Equal(t, "policy/v1beta1 PodDisruptionBudget is deprecated in v1.21 and removed in v1.25, use policy/v1 PodDisruptionBudget instead", l.String())
+}
@@ -0,0 +1,394 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	flowcontrolv1beta3 "k8s.io/api/flowcontrol/v1beta3"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Conversions of the unstructured content of objects between API versions,
+// ported from the conversion and defaulting functions of k8s.io/kubernetes.
+// Besides renaming and restructuring fields, they set the defaults of the
+// original version which differ from the defaults of the target version, so
+// the converted object behaves the same.
+
+// mapping returns the mapping at the path of fields in obj, or nil
+func mapping(obj map[string]any, fields ...string) map[string]any {
+	value, _, _ := unstructured.NestedFieldNoCopy(obj, fields...)
+	m, _ := value.(map[string]any)
+	return m
+}
+
+// mappings returns the mappings in the list at the path of fields in obj
+func mappings(obj map[string]any, fields ...string) []map[string]any {
+	value, _, _ := unstructured.NestedFieldNoCopy(obj, fields...)
+	list, _ := value.([]any)
+	var res []map[string]any
+	for _, item := range list {
+		if m, ok := item.(map[string]any); ok {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// setDefault sets the field at the path of fields in obj to value, unless
+// it is set already
+func setDefault(obj map[string]any, value any, fields ...string) {
+	if _, found, _ := unstructured.NestedFieldNoCopy(obj, fields...); !found {
+		_ = unstructured.SetNestedField(obj, value, fields...)
+	}
+}
+
+// rename moves the field from to the field to of obj
+func rename(obj map[string]any, from, to string) {
+	if value, ok := obj[from]; ok {
+		delete(obj, from)
+		obj[to] = value
+	}
+}
+
+// defaultSelector sets the defaults of the workloads of extensions/v1beta1
+// and apps/v1beta1, which select the labels of their pod template and take
+// them as their own labels when they have none
+func defaultSelector(obj map[string]any) {
+	labels := mapping(obj, "spec", "template", "metadata", "labels")
+	if labels == nil {
+		return
+	}
+	setDefault(obj, map[string]any{"matchLabels": labels}, "spec", "selector")
+	if len(mapping(obj, "metadata", "labels")) == 0 {
+		_ = unstructured.SetNestedField(obj, labels, "metadata", "labels")
+	}
+}
+
+// extensions/v1beta1 -> apps/v1
+func convertExtensionsDaemonSet(obj map[string]any) error {
+	defaultSelector(obj)
+	setDefault(obj, "OnDelete", "spec", "updateStrategy", "type")
+	return nil
+}
+
+// extensions/v1beta1 -> apps/v1
+func convertExtensionsDeployment(obj map[string]any) error {
+	defaultSelector(obj)
+	strategy, _, _ := unstructured.NestedString(obj, "spec", "strategy", "type")
+	if strategy == "" || strategy == "RollingUpdate" || mapping(obj, "spec", "strategy", "rollingUpdate") != nil {
+		setDefault(obj, int64(1), "spec", "strategy", "rollingUpdate", "maxUnavailable")
+		setDefault(obj, int64(1), "spec", "strategy", "rollingUpdate", "maxSurge")
+	}
+	setDefault(obj, int64(math.MaxInt32), "spec", "progressDeadlineSeconds")
+	setDefault(obj, int64(math.MaxInt32), "spec", "revisionHistoryLimit")
+	return nil
+}
+
+// extensions/v1beta1 -> apps/v1
+func convertExtensionsReplicaSet(obj map[string]any) error {
+	defaultSelector(obj)
+	return nil
+}
+
+// apps/v1beta1 -> apps/v1
+func convertAppsV1Beta1Deployment(obj map[string]any) error {
+	defaultSelector(obj)
+	setDefault(obj, int64(2), "spec", "revisionHistoryLimit")
+	return nil
+}
+
+// apps/v1beta1 -> apps/v1
+func convertAppsV1Beta1StatefulSet(obj map[string]any) error {
+	defaultSelector(obj)
+	setDefault(obj, "OnDelete", "spec", "updateStrategy", "type")
+	return nil
+}
+
+// admissionregistration.k8s.io/v1beta1 -> admissionregistration.k8s.io/v1
+func convertWebhooks(obj map[string]any) error {
+	for _, webhook := range mappings(obj, "webhooks") {
+		setDefault(webhook, "Ignore", "failurePolicy")
+		setDefault(webhook, "Exact", "matchPolicy")
+		setDefault(webhook, "Unknown", "sideEffects")
+		setDefault(webhook, int64(30), "timeoutSeconds")
+		setDefault(webhook, []any{"v1beta1"}, "admissionReviewVersions")
+	}
+	return nil
+}
+
+// Annotations autoscaling/v2beta1 and autoscaling/v2beta2 keep the fields of
+// autoscaling/v2 they lack in
+const (
+	hpaBehaviorAnnotation           = "autoscaling.alpha.kubernetes.io/behavior"
+	hpaScaleDownToleranceAnnotation = "autoscaling.alpha.kubernetes.io/scale-down-tolerance"
+	hpaScaleUpToleranceAnnotation   = "autoscaling.alpha.kubernetes.io/scale-up-tolerance"
+)
+
+// Annotations dropped by the conversion of HorizontalPodAutoscalers to
+// autoscaling/v2
+var hpaRoundTripAnnotations = []string{
+	"autoscaling.alpha.kubernetes.io/metrics",
+	"autoscaling.alpha.kubernetes.io/current-metrics",
+	"autoscaling.alpha.kubernetes.io/conditions",
+	hpaBehaviorAnnotation,
+	hpaScaleDownToleranceAnnotation,
+	hpaScaleUpToleranceAnnotation,
+}
+
+func dropHPAAnnotations(obj map[string]any) {
+	annotations := mapping(obj, "metadata", "annotations")
+	for _, annotation := range hpaRoundTripAnnotations {
+		delete(annotations, annotation)
+	}
+}
+
+// metricTarget returns the target of a metric in autoscaling/v2, from its
+// value, average value and average utilization in autoscaling/v2beta1. The
+// type is the first of types whose value is set, or the last one.
+func metricTarget(source map[string]any, types map[string]string, order ...string) map[string]any {
+	target := map[string]any{}
+	for _, t := range order {
+		if value, ok := source[types[t]]; ok {
+			if _, typed := target["type"]; !typed {
+				target["type"] = t
+			}
+			target[targetFields[t]] = value
+		}
+	}
+	if _, typed := target["type"]; !typed {
+		target["type"] = order[len(order)-1]
+	}
+	return target
+}
+
+// Fields of the MetricTarget of autoscaling/v2 holding each type of target
+var targetFields = map[string]string{
+	"Utilization":  "averageUtilization",
+	"Value":        "value",
+	"AverageValue": "averageValue",
+}
+
+// autoscaling/v2beta1 -> autoscaling/v2
+func convertHPAV2Beta1(obj map[string]any) error {
+	for _, metric := range mappings(obj, "spec", "metrics") {
+		for _, sourceType := range []string{"resource", "containerResource"} {
+			if source := mapping(metric, sourceType); source != nil {
+				target := metricTarget(source, map[string]string{"Utilization": "targetAverageUtilization", "AverageValue": "targetAverageValue"}, "Utilization", "AverageValue")
+				delete(source, "targetAverageUtilization")
+				delete(source, "targetAverageValue")
+				source["target"] = target
+			}
+		}
+		if source := mapping(metric, "pods"); source != nil {
+			target := metricTarget(source, map[string]string{"AverageValue": "targetAverageValue"}, "AverageValue")
+			metric["pods"] = map[string]any{
+				"metric": metricIdentifier(source["metricName"], source["selector"]),
+				"target": target,
+			}
+		}
+		if source := mapping(metric, "object"); source != nil {
+			target := metricTarget(source, map[string]string{"AverageValue": "averageValue", "Value": "targetValue"}, "AverageValue", "Value")
+			converted := map[string]any{
+				"metric": metricIdentifier(source["metricName"], source["selector"]),
+				"target": target,
+			}
+			if describedObject, ok := source["target"]; ok {
+				converted["describedObject"] = describedObject
+			}
+			metric["object"] = converted
+		}
+		if source := mapping(metric, "external"); source != nil {
+			target := metricTarget(source, map[string]string{"Value": "targetValue", "AverageValue": "targetAverageValue"}, "Value", "AverageValue")
+			metric["external"] = map[string]any{
+				"metric": metricIdentifier(source["metricName"], source["metricSelector"]),
+				"target": target,
+			}
+		}
+	}
+
+	if behavior, ok := mapping(obj, "metadata", "annotations")[hpaBehaviorAnnotation].(string); ok {
+		var spec autoscalingv2.HorizontalPodAutoscalerBehavior
+		if err := json.Unmarshal([]byte(behavior), &spec); err == nil && spec != (autoscalingv2.HorizontalPodAutoscalerBehavior{}) {
+			content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&spec)
+			if err != nil {
+				return err
+			}
+			setDefault(obj, content, "spec", "behavior")
+		}
+	}
+	dropHPAAnnotations(obj)
+	return nil
+}
+
+func metricIdentifier(name, selector any) map[string]any {
+	res := map[string]any{}
+	if name != nil {
+		res["name"] = name
+	}
+	if selector != nil {
+		res["selector"] = selector
+	}
+	return res
+}
+
+// autoscaling/v2beta2 -> autoscaling/v2
+func convertHPAV2Beta2(obj map[string]any) error {
+	annotations := mapping(obj, "metadata", "annotations")
+	for annotation, rules := range map[string]string{
+		hpaScaleDownToleranceAnnotation: "scaleDown",
+		hpaScaleUpToleranceAnnotation:   "scaleUp",
+	} {
+		tolerance, ok := annotations[annotation].(string)
+		if !ok {
+			continue
+		}
+		if _, err := resource.ParseQuantity(tolerance); err != nil {
+			return fmt.Errorf("failed to parse annotation %q: %w", annotation, err)
+		}
+		_ = unstructured.SetNestedField(obj, tolerance, "spec", "behavior", rules, "tolerance")
+	}
+	dropHPAAnnotations(obj)
+	return nil
+}
+
+// certificates.k8s.io/v1beta1 -> certificates.k8s.io/v1
+func convertCSR(obj map[string]any) error {
+	setDefault(obj, []any{"digital signature", "key encipherment"}, "spec", "usages")
+	return nil
+}
+
+// discovery.k8s.io/v1beta1 -> discovery.k8s.io/v1
+func convertEndpointSlice(obj map[string]any) error {
+	for _, endpoint := range mappings(obj, "endpoints") {
+		topology, ok := endpoint["topology"].(map[string]any)
+		if !ok {
+			continue
+		}
+		delete(endpoint, "topology")
+		if zone, ok := topology[corev1.LabelTopologyZone]; ok {
+			endpoint["zone"] = zone
+			delete(topology, corev1.LabelTopologyZone)
+		}
+		// The hostname is kept if it differs from the name of the node
+		if hostname, ok := topology[corev1.LabelHostname]; ok && hostname == endpoint["nodeName"] {
+			delete(topology, corev1.LabelHostname)
+		}
+		if len(topology) > 0 {
+			endpoint["deprecatedTopology"] = topology
+		}
+	}
+	return nil
+}
+
+// extensions/v1beta1, networking.k8s.io/v1beta1 -> networking.k8s.io/v1
+func convertIngress(obj map[string]any) error {
+	spec := mapping(obj, "spec")
+	if spec == nil {
+		return nil
+	}
+	if backend, ok := spec["backend"].(map[string]any); ok {
+		delete(spec, "backend")
+		spec["defaultBackend"] = ingressBackend(backend)
+	}
+	for _, rule := range mappings(spec, "rules") {
+		for _, path := range mappings(rule, "http", "paths") {
+			if backend, ok := path["backend"].(map[string]any); ok {
+				path["backend"] = ingressBackend(backend)
+			}
+			setDefault(path, "ImplementationSpecific", "pathType")
+		}
+	}
+	return nil
+}
+
+func ingressBackend(backend map[string]any) map[string]any {
+	res := map[string]any{}
+	if resource, ok := backend["resource"]; ok {
+		res["resource"] = resource
+	}
+	name, hasName := backend["serviceName"]
+	port, hasPort := backend["servicePort"]
+	if hasName || hasPort {
+		service := map[string]any{}
+		if hasName {
+			service["name"] = name
+		}
+		switch port := port.(type) {
+		case string:
+			service["port"] = map[string]any{"name": port}
+		case int64:
+			service["port"] = map[string]any{"number": port}
+		}
+		res["service"] = service
+	}
+	return res
+}
+
+// flowcontrol.apiserver.k8s.io/v1beta2 -> flowcontrol.apiserver.k8s.io/v1beta3
+func convertPriorityLevelV1Beta2(obj map[string]any) error {
+	rename(mapping(obj, "spec", "limited"), "assuredConcurrencyShares", "nominalConcurrencyShares")
+	return nil
+}
+
+// flowcontrol.apiserver.k8s.io/v1beta3 -> flowcontrol.apiserver.k8s.io/v1
+func convertPriorityLevelV1Beta3(obj map[string]any) error {
+	annotations := mapping(obj, "metadata", "annotations")
+	if _, ok := annotations[flowcontrolv1beta3.PriorityLevelPreserveZeroConcurrencySharesKey]; ok {
+		// v1 keeps a zero number of shares without the annotation
+		delete(annotations, flowcontrolv1beta3.PriorityLevelPreserveZeroConcurrencySharesKey)
+		if limited := mapping(obj, "spec", "limited"); limited != nil {
+			setDefault(limited, int64(0), "nominalConcurrencyShares")
+		}
+	}
+	return nil
+}
+
+// node.k8s.io/v1alpha1 -> node.k8s.io/v1
+func convertRuntimeClassV1Alpha1(obj map[string]any) error {
+	spec := mapping(obj, "spec")
+	delete(obj, "spec")
+	for from, to := range map[string]string{"runtimeHandler": "handler", "overhead": "overhead", "scheduling": "scheduling"} {
+		if value, ok := spec[from]; ok {
+			obj[to] = value
+		}
+	}
+	return nil
+}
+
+// Label of the selectors policy/v1 uses for the selectors of policy/v1beta1
+// which it cannot express
+const pdbV1Beta1Label = "pdb.kubernetes.io/deprecated-v1beta1-empty-selector-match"
+
+// policy/v1beta1 -> policy/v1
+func convertPDB(obj map[string]any) error {
+	selector := mapping(obj, "spec", "selector")
+	switch {
+	case selector == nil:
+	case len(selector) == 0:
+		// An empty selector selects no pods in policy/v1beta1
+		selector["matchExpressions"] = []any{map[string]any{"key": pdbV1Beta1Label, "operator": "Exists"}}
+	case reflect.DeepEqual(selector, map[string]any{"matchExpressions": []any{map[string]any{"key": pdbV1Beta1Label, "operator": "DoesNotExist"}}}):
+		// The selector policy/v1beta1 uses for all pods
+		delete(selector, "matchExpressions")
+	default:
+		expressions, _ := selector["matchExpressions"].([]any)
+		var kept []any
+		for _, expression := range expressions {
+			if e, ok := expression.(map[string]any); !ok || e["key"] != pdbV1Beta1Label {
+				kept = append(kept, expression)
+			}
+		}
+		if len(kept) > 0 {
+			selector["matchExpressions"] = kept
+		} else {
+			delete(selector, "matchExpressions")
+		}
+	}
+	return nil
+}
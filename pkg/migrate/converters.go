@@ -0,0 +1,232 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	yaml "go.yaml.in/yaml/v3"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kjson "k8s.io/apimachinery/pkg/runtime/serializer/json"
+	clientscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// decoder reads objects into their versioned type, reporting the fields the
+// type does not know
+var decoder = kjson.NewSerializerWithOptions(kjson.DefaultMetaFactory, clientscheme.Scheme, clientscheme.Scheme, kjson.SerializerOptions{Yaml: true, Strict: true})
+
+// conversion is the state of a single object being converted
+type conversion struct {
+	// Root mapping node of the object
+	obj *yaml.Node
+
+	// Reasons the conversion is not lossless
+	problems []string
+}
+
+func (c *conversion) lossy(format string, args ...any) {
+	c.problems = append(c.problems, fmt.Sprintf(format, args...))
+}
+
+func (c *conversion) spec() *yaml.Node {
+	return get(c.obj, "spec")
+}
+
+// convert converts the document holding c.obj with each converter of steps
+// in turn, and merges the result into c.obj. Fields the type of the last
+// version does not have are dropped.
+func (c *conversion) convert(document []byte, steps []converter) error {
+	obj, _, err := decoder.Decode(document, nil, nil)
+	if strictErr, ok := runtime.AsStrictDecodingError(err); ok {
+		for _, err := range strictErr.Errors() {
+			c.lossy("%v is dropped", err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	for _, step := range steps {
+		if step.convert == nil {
+			continue
+		}
+		if err := step.convert(content); err != nil {
+			return err
+		}
+	}
+	to := steps[len(steps)-1].to
+	content["apiVersion"], content["kind"] = to.GroupVersion().String(), to.Kind
+
+	// Encoding the typed object keeps the order of its fields
+	typed, err := clientscheme.Scheme.New(to)
+	if err != nil {
+		return err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, typed); err != nil {
+		return err
+	}
+	data, err := json.Marshal(typed)
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	*c.obj = *merge(c.obj, node.Content[0], reflect.TypeOf(typed))
+	return nil
+}
+
+type converter struct {
+	to schema.GroupVersionKind
+
+	// Converts the unstructured content of the object to the version of to.
+	// Nil if the versions only differ in name.
+	convert func(obj map[string]any) error
+
+	// Reports what the conversion loses or changes in meaning. Nil if the
+	// object converts losslessly.
+	check func(c *conversion)
+}
+
+func gvk(group, version, kind string) schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: group, Version: version, Kind: kind}
+}
+
+// Converters are keyed by the GVK they convert from, to the GVK preferred
+// once it is deprecated.
+var converters = map[schema.GroupVersionKind]converter{
+	gvk("admissionregistration.k8s.io", "v1beta1", "MutatingWebhookConfiguration"):   {to: gvk("admissionregistration.k8s.io", "v1", "MutatingWebhookConfiguration"), convert: convertWebhooks, check: checkWebhooks},
+	gvk("admissionregistration.k8s.io", "v1beta1", "ValidatingWebhookConfiguration"): {to: gvk("admissionregistration.k8s.io", "v1", "ValidatingWebhookConfiguration"), convert: convertWebhooks, check: checkWebhooks},
+
+	gvk("apps", "v1beta1", "ControllerRevision"): {to: gvk("apps", "v1", "ControllerRevision")},
+	gvk("apps", "v1beta1", "Deployment"):         {to: gvk("apps", "v1", "Deployment"), convert: convertAppsV1Beta1Deployment, check: checkBetaWorkload},
+	gvk("apps", "v1beta1", "StatefulSet"):        {to: gvk("apps", "v1", "StatefulSet"), convert: convertAppsV1Beta1StatefulSet, check: checkBetaWorkload},
+	gvk("apps", "v1beta2", "ControllerRevision"): {to: gvk("apps", "v1", "ControllerRevision")},
+	gvk("apps", "v1beta2", "DaemonSet"):          {to: gvk("apps", "v1", "DaemonSet")},
+	gvk("apps", "v1beta2", "Deployment"):         {to: gvk("apps", "v1", "Deployment")},
+	gvk("apps", "v1beta2", "ReplicaSet"):         {to: gvk("apps", "v1", "ReplicaSet")},
+	gvk("apps", "v1beta2", "StatefulSet"):        {to: gvk("apps", "v1", "StatefulSet")},
+	gvk("extensions", "v1beta1", "DaemonSet"):    {to: gvk("apps", "v1", "DaemonSet"), convert: convertExtensionsDaemonSet, check: checkBetaWorkload},
+	gvk("extensions", "v1beta1", "Deployment"):   {to: gvk("apps", "v1", "Deployment"), convert: convertExtensionsDeployment, check: checkBetaWorkload},
+	gvk("extensions", "v1beta1", "ReplicaSet"):   {to: gvk("apps", "v1", "ReplicaSet"), convert: convertExtensionsReplicaSet, check: checkBetaWorkload},
+
+	gvk("autoscaling", "v2beta1", "HorizontalPodAutoscaler"): {to: gvk("autoscaling", "v2", "HorizontalPodAutoscaler"), convert: convertHPAV2Beta1, check: checkHPAV2Beta1},
+	gvk("autoscaling", "v2beta2", "HorizontalPodAutoscaler"): {to: gvk("autoscaling", "v2", "HorizontalPodAutoscaler"), convert: convertHPAV2Beta2},
+
+	gvk("batch", "v1beta1", "CronJob"): {to: gvk("batch", "v1", "CronJob")},
+
+	gvk("certificates.k8s.io", "v1beta1", "CertificateSigningRequest"): {to: gvk("certificates.k8s.io", "v1", "CertificateSigningRequest"), convert: convertCSR, check: checkCSR},
+
+	gvk("coordination.k8s.io", "v1beta1", "Lease"): {to: gvk("coordination.k8s.io", "v1", "Lease")},
+
+	gvk("discovery.k8s.io", "v1beta1", "EndpointSlice"): {to: gvk("discovery.k8s.io", "v1", "EndpointSlice"), convert: convertEndpointSlice},
+
+	gvk("events.k8s.io", "v1beta1", "Event"): {to: gvk("events.k8s.io", "v1", "Event")},
+
+	gvk("extensions", "v1beta1", "Ingress"):             {to: gvk("networking.k8s.io", "v1", "Ingress"), convert: convertIngress},
+	gvk("extensions", "v1beta1", "NetworkPolicy"):       {to: gvk("networking.k8s.io", "v1", "NetworkPolicy")},
+	gvk("networking.k8s.io", "v1beta1", "Ingress"):      {to: gvk("networking.k8s.io", "v1", "Ingress"), convert: convertIngress},
+	gvk("networking.k8s.io", "v1beta1", "IngressClass"): {to: gvk("networking.k8s.io", "v1", "IngressClass")},
+
+	gvk("flowcontrol.apiserver.k8s.io", "v1beta1", "FlowSchema"):                 {to: gvk("flowcontrol.apiserver.k8s.io", "v1beta2", "FlowSchema")},
+	gvk("flowcontrol.apiserver.k8s.io", "v1beta1", "PriorityLevelConfiguration"): {to: gvk("flowcontrol.apiserver.k8s.io", "v1beta2", "PriorityLevelConfiguration")},
+	gvk("flowcontrol.apiserver.k8s.io", "v1beta2", "FlowSchema"):                 {to: gvk("flowcontrol.apiserver.k8s.io", "v1beta3", "FlowSchema")},
+	gvk("flowcontrol.apiserver.k8s.io", "v1beta2", "PriorityLevelConfiguration"): {to: gvk("flowcontrol.apiserver.k8s.io", "v1beta3", "PriorityLevelConfiguration"), convert: convertPriorityLevelV1Beta2},
+	gvk("flowcontrol.apiserver.k8s.io", "v1beta3", "FlowSchema"):                 {to: gvk("flowcontrol.apiserver.k8s.io", "v1", "FlowSchema")},
+	gvk("flowcontrol.apiserver.k8s.io", "v1beta3", "PriorityLevelConfiguration"): {to: gvk("flowcontrol.apiserver.k8s.io", "v1", "PriorityLevelConfiguration"), convert: convertPriorityLevelV1Beta3},
+
+	gvk("node.k8s.io", "v1alpha1", "RuntimeClass"): {to: gvk("node.k8s.io", "v1", "RuntimeClass"), convert: convertRuntimeClassV1Alpha1, check: checkRuntimeClassV1Alpha1},
+	gvk("node.k8s.io", "v1beta1", "RuntimeClass"):  {to: gvk("node.k8s.io", "v1", "RuntimeClass")},
+
+	gvk("policy", "v1beta1", "PodDisruptionBudget"): {to: gvk("policy", "v1", "PodDisruptionBudget"), convert: convertPDB, check: checkPDB},
+
+	gvk("rbac.authorization.k8s.io", "v1beta1", "ClusterRole"):        {to: gvk("rbac.authorization.k8s.io", "v1", "ClusterRole")},
+	gvk("rbac.authorization.k8s.io", "v1beta1", "ClusterRoleBinding"): {to: gvk("rbac.authorization.k8s.io", "v1", "ClusterRoleBinding")},
+	gvk("rbac.authorization.k8s.io", "v1beta1", "Role"):               {to: gvk("rbac.authorization.k8s.io", "v1", "Role")},
+	gvk("rbac.authorization.k8s.io", "v1beta1", "RoleBinding"):        {to: gvk("rbac.authorization.k8s.io", "v1", "RoleBinding")},
+
+	gvk("scheduling.k8s.io", "v1beta1", "PriorityClass"): {to: gvk("scheduling.k8s.io", "v1", "PriorityClass")},
+
+	gvk("storage.k8s.io", "v1alpha1", "CSIStorageCapacity"):    {to: gvk("storage.k8s.io", "v1beta1", "CSIStorageCapacity")},
+	gvk("storage.k8s.io", "v1alpha1", "VolumeAttachment"):      {to: gvk("storage.k8s.io", "v1", "VolumeAttachment")},
+	gvk("storage.k8s.io", "v1alpha1", "VolumeAttributesClass"): {to: gvk("storage.k8s.io", "v1", "VolumeAttributesClass")},
+	gvk("storage.k8s.io", "v1beta1", "CSIDriver"):              {to: gvk("storage.k8s.io", "v1", "CSIDriver")},
+	gvk("storage.k8s.io", "v1beta1", "CSINode"):                {to: gvk("storage.k8s.io", "v1", "CSINode")},
+	gvk("storage.k8s.io", "v1beta1", "CSIStorageCapacity"):     {to: gvk("storage.k8s.io", "v1", "CSIStorageCapacity")},
+	gvk("storage.k8s.io", "v1beta1", "StorageClass"):           {to: gvk("storage.k8s.io", "v1", "StorageClass")},
+	gvk("storage.k8s.io", "v1beta1", "VolumeAttachment"):       {to: gvk("storage.k8s.io", "v1", "VolumeAttachment")},
+	gvk("storage.k8s.io", "v1beta1", "VolumeAttributesClass"):  {to: gvk("storage.k8s.io", "v1", "VolumeAttributesClass")},
+}
+
+// extensions/v1beta1, apps/v1beta1 -> apps/v1
+func checkBetaWorkload(c *conversion) {
+	if has(c.spec(), "rollbackTo") {
+		c.lossy("spec.rollbackTo is not supported by apps/v1 and was dropped")
+	}
+	if has(c.spec(), "templateGeneration") {
+		c.lossy("spec.templateGeneration is not supported by apps/v1 and was dropped")
+	}
+}
+
+// Fields holding the source of each type of metric
+var metricSources = map[string]string{
+	"Resource":          "resource",
+	"ContainerResource": "containerResource",
+	"Pods":              "pods",
+	"Object":            "object",
+	"External":          "external",
+}
+
+// autoscaling/v2beta1 -> autoscaling/v2
+func checkHPAV2Beta1(c *conversion) {
+	for i, metric := range items(get(c.spec(), "metrics")) {
+		metricType := get(metric, "type")
+		if metricType == nil {
+			c.lossy("spec.metrics[%d].type is not set, the metric cannot be converted", i)
+			continue
+		}
+		if source, ok := metricSources[metricType.Value]; ok && !has(metric, source) {
+			c.lossy("spec.metrics[%d].%s is not set, the %s metric cannot be converted", i, source, metricType.Value)
+		}
+	}
+}
+
+// admissionregistration.k8s.io/v1beta1 -> admissionregistration.k8s.io/v1
+func checkWebhooks(c *conversion) {
+	for i, webhook := range items(get(c.obj, "webhooks")) {
+		sideEffects := get(webhook, "sideEffects")
+		if sideEffects == nil || (sideEffects.Value != "None" && sideEffects.Value != "NoneOnDryRun") {
+			value := "Unknown"
+			if sideEffects != nil {
+				value = sideEffects.Value
+			}
+			c.lossy("webhooks[%d].sideEffects: %q is not supported by v1, must be None or NoneOnDryRun", i, value)
+		}
+	}
+}
+
+// certificates.k8s.io/v1beta1 -> certificates.k8s.io/v1
+func checkCSR(c *conversion) {
+	if signer := get(c.spec(), "signerName"); signer == nil || signer.Value == "kubernetes.io/legacy-unknown" {
+		c.lossy("spec.signerName must be set to a signer other than kubernetes.io/legacy-unknown")
+	}
+}
+
+// node.k8s.io/v1alpha1 -> node.k8s.io/v1
+func checkRuntimeClassV1Alpha1(c *conversion) {
+	if !has(c.spec(), "runtimeHandler") {
+		c.lossy("spec.runtimeHandler is not set, handler is required by node.k8s.io/v1")
+	}
+}
+
+// policy/v1beta1 -> policy/v1
+func checkPDB(c *conversion) {
+	if isEmptyMapping(get(c.spec(), "selector")) {
+		c.lossy("spec.selector: an empty selector selects no pods in policy/v1beta1 but all pods in the namespace in policy/v1, it was converted to a selector which keeps selecting no pods")
+	}
+}
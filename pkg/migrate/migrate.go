@@ -0,0 +1,297 @@
+// Package migrate rewrites manifests using deprecated or removed API versions
+// to the API versions served by a given Kubernetes release.
+//
+// Objects are decoded into their k8s.io/api type and converted by functions
+// ported from the conversion and defaulting functions of k8s.io/kubernetes.
+// The converted object is merged into the YAML node tree of the original
+// document, which keeps its comments and field order.
+package migrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	yaml "go.yaml.in/yaml/v3"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/kubectl-validate/pkg/deprecation"
+	"sigs.k8s.io/kubectl-validate/pkg/utils"
+)
+
+// Result describes the migration of a single object
+type Result struct {
+	// Index of the document within its file
+	Index int
+
+	// Name of the object, prefixed by its namespace if it has one
+	Name string
+
+	From schema.GroupVersionKind
+	To   schema.GroupVersionKind
+
+	// The rewritten document. Nil if the object was not converted.
+	Document []byte
+
+	// Reasons the object could not be converted, or was not converted
+	// losslessly
+	Problems []string
+}
+
+// Converted returns true if the object was rewritten to another GVK
+func (r Result) Converted() bool {
+	return r.Document != nil
+}
+
+// Failed returns true if the object still uses an API removed in the target
+// release.
+func (r Result) Failed(target *version.Version) bool {
+	lifecycle, ok := deprecation.ForGVK(r.To)
+	return ok && lifecycle.RemovedIn(target)
+}
+
+// File migrates every document in a YAML or JSON file to the API versions
+// served by target. It returns the rewritten file along with a result for
+// each object using a deprecated or removed API. Documents which do not need
+// migration are kept as-is.
+func File(data []byte, isJSON bool, target *version.Version) ([]byte, []Result, error) {
+	if isJSON {
+		res, err := Document(data, target)
+		if err != nil || res == nil || !res.Converted() {
+			return data, resultList(res), err
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal(res.Document, &node); err != nil {
+			return nil, nil, err
+		}
+		var out bytes.Buffer
+		if err := encodeJSON(&out, &node, ""); err != nil {
+			return nil, nil, err
+		}
+		out.WriteByte('\n')
+		return out.Bytes(), resultList(res), nil
+	}
+
+	documents, err := utils.SplitYamlDocuments(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var out bytes.Buffer
+	var results []Result
+	for i, document := range documents {
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		if utils.IsEmptyYamlDocument(document) {
+			out.Write(document)
+			continue
+		}
+		res, err := Document(document, target)
+		if err != nil {
+			return nil, nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		if res == nil {
+			out.Write(document)
+			continue
+		}
+		res.Index = i
+		results = append(results, *res)
+		if res.Converted() {
+			out.Write(res.Document)
+		} else {
+			out.Write(document)
+		}
+	}
+	return out.Bytes(), results, nil
+}
+
+func resultList(res *Result) []Result {
+	if res == nil {
+		return nil
+	}
+	return []Result{*res}
+}
+
+// Document migrates a single YAML or JSON document to the API versions
+// served by target. Returns nil if the object does not use a deprecated or
+// removed API version.
+func Document(document []byte, target *version.Version) (*Result, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(document, &root); err != nil {
+		return nil, err
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		// Not an object, reported during validation
+		return nil, nil
+	}
+	c := &conversion{obj: root.Content[0]}
+
+	from, ok := objectGVK(c.obj)
+	if !ok {
+		return nil, nil
+	}
+	lifecycle, ok := deprecation.ForGVK(from)
+	if !ok || !(lifecycle.DeprecatedIn(target) || lifecycle.RemovedIn(target)) {
+		return nil, nil
+	}
+
+	res := &Result{Name: objectName(c.obj), From: from, To: from}
+	to := from
+	var steps []converter
+	for ok && (lifecycle.DeprecatedIn(target) || lifecycle.RemovedIn(target)) {
+		conv, found := converters[to]
+		if !found {
+			c.lossy("no automatic conversion is available: %s", lifecycle)
+			break
+		}
+		if served, known := deprecation.Served(conv.to.GroupVersion(), target); known && !served {
+			c.lossy("%s %s is not served in v%d.%d: %s", conv.to.GroupVersion(), conv.to.Kind, target.Major(), target.Minor(), lifecycle)
+			break
+		}
+		if conv.check != nil {
+			conv.check(c)
+		}
+		steps = append(steps, conv)
+		to = conv.to
+		lifecycle, ok = deprecation.ForGVK(to)
+	}
+	if len(steps) > 0 {
+		if err := c.convert(document, steps); err != nil {
+			c.lossy("cannot be converted to %s %s: %v", to.GroupVersion(), to.Kind, err)
+		} else {
+			res.To = to
+		}
+	}
+	res.Problems = c.problems
+
+	if res.To == res.From {
+		return res, nil
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if compactSequences(&root) {
+		encoder.CompactSeqIndent()
+	}
+	if err := encoder.Encode(&root); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	res.Document = out.Bytes()
+	return res, nil
+}
+
+// compactSequences returns true unless the document indents block sequences
+// relative to their parent key, so the rewritten document keeps its style.
+func compactSequences(n *yaml.Node) bool {
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			// Nodes added by the conversion have no position
+			if value := n.Content[i+1]; value.Kind == yaml.SequenceNode && value.Style&yaml.FlowStyle == 0 && len(value.Content) > 0 && value.Line > 0 {
+				return value.Column == n.Content[i].Column
+			}
+		}
+	}
+	for _, child := range n.Content {
+		if child.Kind == yaml.MappingNode || child.Kind == yaml.SequenceNode || child.Kind == yaml.DocumentNode {
+			if !compactSequences(child) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func objectGVK(obj *yaml.Node) (schema.GroupVersionKind, bool) {
+	apiVersion, kind := get(obj, "apiVersion"), get(obj, "kind")
+	if apiVersion == nil || kind == nil {
+		return schema.GroupVersionKind{}, false
+	}
+	gv, err := schema.ParseGroupVersion(apiVersion.Value)
+	if err != nil {
+		return schema.GroupVersionKind{}, false
+	}
+	return gv.WithKind(kind.Value), true
+}
+
+func objectName(obj *yaml.Node) string {
+	metadata := get(obj, "metadata")
+	var name string
+	if n := get(metadata, "name"); n != nil {
+		name = n.Value
+	} else if n := get(metadata, "generateName"); n != nil {
+		name = n.Value
+	}
+	if namespace := get(metadata, "namespace"); namespace != nil && len(namespace.Value) > 0 {
+		return namespace.Value + "/" + name
+	}
+	return name
+}
+
+// encodeJSON writes the node as indented JSON, keeping the order of fields
+func encodeJSON(out *bytes.Buffer, n *yaml.Node, indent string) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		return encodeJSON(out, n.Content[0], indent)
+	case yaml.AliasNode:
+		return encodeJSON(out, n.Alias, indent)
+	case yaml.MappingNode, yaml.SequenceNode:
+		open, close, step := "{", "}", 2
+		if n.Kind == yaml.SequenceNode {
+			open, close, step = "[", "]", 1
+		}
+		if len(n.Content) == 0 {
+			out.WriteString(open + close)
+			return nil
+		}
+		inner := indent + "  "
+		out.WriteString(open)
+		for i := 0; i < len(n.Content); i += step {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			out.WriteString("\n" + inner)
+			value := n.Content[i]
+			if n.Kind == yaml.MappingNode {
+				key, _ := json.Marshal(n.Content[i].Value)
+				out.Write(key)
+				out.WriteString(": ")
+				value = n.Content[i+1]
+			}
+			if err := encodeJSON(out, value, inner); err != nil {
+				return err
+			}
+		}
+		out.WriteString("\n" + indent + close)
+		return nil
+	default:
+		var value any
+		if err := n.Decode(&value); err != nil {
+			return err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		out.Write(data)
+		return nil
+	}
+}
+
+// String summarizes the result for display
+func (r Result) String() string {
+	var sb strings.Builder
+	if len(r.Name) > 0 {
+		fmt.Fprintf(&sb, "%s ", r.Name)
+	}
+	fmt.Fprintf(&sb, "%s %s", r.From.GroupVersion(), r.From.Kind)
+	if r.To != r.From {
+		fmt.Fprintf(&sb, " -> %s %s", r.To.GroupVersion(), r.To.Kind)
+	}
+	return sb.String()
+}
@@ -0,0 +1,450 @@
+package migrate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+	clientscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+func TestFile(t *testing.T) {
+	tests := []struct {
+		name         string
+		target       string
+		json         bool
+		input        string
+		want         string
+		wantTo       []schema.GroupVersionKind
+		wantProblems []string
+	}{{
+		name:   "ingress",
+		target: "1.22",
+		input: `# frontend ingress
+apiVersion: extensions/v1beta1
+kind: Ingress
+metadata:
+  name: web # public
+spec:
+  backend:
+    serviceName: default
+    servicePort: 80
+  rules:
+  - host: example.com
+    http:
+      paths:
+      # api traffic
+      - path: /api
+        backend:
+          serviceName: api
+          servicePort: http
+`,
+		want: `# frontend ingress
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web # public
+spec:
+  defaultBackend:
+    service:
+      name: default
+      port:
+        number: 80
+  rules:
+  - host: example.com
+    http:
+      paths:
+      # api traffic
+      - path: /api
+        backend:
+          service:
+            name: api
+            port:
+              name: http
+        pathType: ImplementationSpecific
+`,
+		wantTo: []schema.GroupVersionKind{{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"}},
+	}, {
+		name:   "hpa v2beta1",
+		target: "1.26",
+		input: `apiVersion: autoscaling/v2beta1
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+spec:
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: web
+  maxReplicas: 3
+  metrics:
+    - type: Resource
+      resource:
+        name: cpu
+        targetAverageUtilization: 50
+    - type: Pods
+      pods:
+        metricName: requests
+        targetAverageValue: 10
+    - type: Object
+      object:
+        target:
+          kind: Service
+          name: web
+        metricName: latency
+        targetValue: 100m
+`,
+		want: `apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+spec:
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: web
+  maxReplicas: 3
+  metrics:
+    - type: Resource
+      resource:
+        name: cpu
+        target:
+          type: Utilization
+          averageUtilization: 50
+    - type: Pods
+      pods:
+        metric:
+          name: requests
+        target:
+          type: AverageValue
+          averageValue: "10"
+    - type: Object
+      object:
+        target:
+          type: Value
+          value: 100m
+        describedObject:
+          kind: Service
+          name: web
+        metric:
+          name: latency
+`,
+		wantTo: []schema.GroupVersionKind{{Group: "autoscaling", Version: "v2", Kind: "HorizontalPodAutoscaler"}},
+	}, {
+		name:   "hpa v2beta1 metrics without type or source",
+		target: "1.30",
+		input: `apiVersion: autoscaling/v2beta1
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+spec:
+  maxReplicas: 3
+  metrics:
+  - type: Pods
+  - type: Object
+  - type: External
+  - resource:
+      name: cpu
+      targetAverageUtilization: 50
+`,
+		want: `apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+spec:
+  maxReplicas: 3
+  metrics:
+  - type: Pods
+  - type: Object
+  - type: External
+  - resource:
+      name: cpu
+      target:
+        type: Utilization
+        averageUtilization: 50
+`,
+		wantTo: []schema.GroupVersionKind{{Group: "autoscaling", Version: "v2", Kind: "HorizontalPodAutoscaler"}},
+		wantProblems: []string{
+			"spec.metrics[0].pods is not set, the Pods metric cannot be converted",
+			"spec.metrics[1].object is not set, the Object metric cannot be converted",
+			"spec.metrics[2].external is not set, the External metric cannot be converted",
+			"spec.metrics[3].type is not set, the metric cannot be converted",
+		},
+	}, {
+		name:   "runtimeclass v1alpha1 without handler",
+		target: "1.30",
+		input: `apiVersion: node.k8s.io/v1alpha1
+kind: RuntimeClass
+metadata:
+  name: gvisor
+spec: {}
+`,
+		want: `apiVersion: node.k8s.io/v1
+kind: RuntimeClass
+metadata:
+  name: gvisor
+`,
+		wantTo:       []schema.GroupVersionKind{{Group: "node.k8s.io", Version: "v1", Kind: "RuntimeClass"}},
+		wantProblems: []string{"spec.runtimeHandler is not set, handler is required by node.k8s.io/v1"},
+	}, {
+		name:   "changed defaults are kept",
+		target: "1.30",
+		input: `apiVersion: extensions/v1beta1
+kind: DaemonSet
+metadata:
+  name: agent
+spec:
+  templateGeneration: 2
+  template:
+    metadata:
+      labels: {app: agent}
+    spec:
+      hostNetwork: false
+      containers:
+      - name: agent
+        image: agent:1.0
+        resources:
+          limits: {cpu: 0.5, memory: 1Gi}
+        unknown: true
+`,
+		want: `apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent
+  labels:
+    app: agent
+spec:
+  selector:
+    matchLabels:
+      app: agent
+  updateStrategy:
+    type: OnDelete
+  template:
+    metadata:
+      labels: {app: agent}
+    spec:
+      hostNetwork: false
+      containers:
+      - name: agent
+        image: agent:1.0
+        resources:
+          limits: {cpu: 0.5, memory: 1Gi}
+`,
+		wantTo: []schema.GroupVersionKind{{Group: "apps", Version: "v1", Kind: "DaemonSet"}},
+		wantProblems: []string{
+			"spec.templateGeneration is not supported by apps/v1 and was dropped",
+			`unknown field "spec.template.spec.containers[0].unknown" is dropped`,
+		},
+	}, {
+		name:   "changed deployment defaults are kept",
+		target: "1.30",
+		input: `apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    metadata:
+      labels: {app: web}
+    spec:
+      containers:
+      - name: web
+        image: nginx
+`,
+		want: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  template:
+    metadata:
+      labels: {app: web}
+    spec:
+      containers:
+      - name: web
+        image: nginx
+  selector:
+    matchLabels:
+      app: web
+  strategy:
+    rollingUpdate:
+      maxUnavailable: 1
+      maxSurge: 1
+  revisionHistoryLimit: 2147483647
+  progressDeadlineSeconds: 2147483647
+`,
+		wantTo: []schema.GroupVersionKind{{Group: "apps", Version: "v1", Kind: "Deployment"}},
+	}, {
+		name:   "endpoint topology",
+		target: "1.25",
+		input: `apiVersion: discovery.k8s.io/v1beta1
+kind: EndpointSlice
+metadata:
+  name: web
+addressType: IPv4
+endpoints:
+- addresses: [10.0.0.1]
+  nodeName: node-a
+  topology:
+    kubernetes.io/hostname: node-a
+    topology.kubernetes.io/zone: us-east-1a
+    topology.kubernetes.io/region: us-east-1
+`,
+		want: `apiVersion: discovery.k8s.io/v1
+kind: EndpointSlice
+metadata:
+  name: web
+addressType: IPv4
+endpoints:
+- addresses: [10.0.0.1]
+  nodeName: node-a
+  deprecatedTopology:
+    topology.kubernetes.io/region: us-east-1
+  zone: us-east-1a
+`,
+		wantTo: []schema.GroupVersionKind{{Group: "discovery.k8s.io", Version: "v1", Kind: "EndpointSlice"}},
+	}, {
+		name:   "unchanged documents are kept",
+		target: "1.25",
+		input: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+    key:   value
+---
+apiVersion: policy/v1beta1
+kind: PodDisruptionBudget
+metadata: {name: pdb, namespace: default}
+spec:
+  minAvailable: 1
+  selector: {}
+`,
+		want: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+    key:   value
+---
+apiVersion: policy/v1
+kind: PodDisruptionBudget
+metadata: {name: pdb, namespace: default}
+spec:
+  minAvailable: 1
+  selector: {matchExpressions: [{key: pdb.kubernetes.io/deprecated-v1beta1-empty-selector-match, operator: Exists}]}
+`,
+		wantTo:       []schema.GroupVersionKind{{Group: "policy", Version: "v1", Kind: "PodDisruptionBudget"}},
+		wantProblems: []string{"spec.selector: an empty selector selects no pods in policy/v1beta1 but all pods in the namespace in policy/v1, it was converted to a selector which keeps selecting no pods"},
+	}, {
+		name:   "deprecated but not removed",
+		target: "1.24",
+		input: `apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: job
+`,
+		want: `apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: job
+`,
+		wantTo: []schema.GroupVersionKind{{Group: "batch", Version: "v1", Kind: "CronJob"}},
+	}, {
+		name:   "not yet deprecated",
+		target: "1.20",
+		input: `apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: job
+`,
+		want: `apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: job
+`,
+	}, {
+		name:   "chained conversions",
+		target: "1.29",
+		input: `apiVersion: flowcontrol.apiserver.k8s.io/v1beta2
+kind: PriorityLevelConfiguration
+metadata:
+  name: workload
+spec:
+  type: Limited
+  limited:
+    assuredConcurrencyShares: 10
+`,
+		want: `apiVersion: flowcontrol.apiserver.k8s.io/v1
+kind: PriorityLevelConfiguration
+metadata:
+  name: workload
+spec:
+  type: Limited
+  limited:
+    nominalConcurrencyShares: 10
+`,
+		wantTo: []schema.GroupVersionKind{{Group: "flowcontrol.apiserver.k8s.io", Version: "v1", Kind: "PriorityLevelConfiguration"}},
+	}, {
+		name:   "no conversion",
+		target: "1.33",
+		input: `apiVersion: v1
+kind: Endpoints
+metadata:
+  name: svc
+`,
+		want: `apiVersion: v1
+kind: Endpoints
+metadata:
+  name: svc
+`,
+		wantTo:       []schema.GroupVersionKind{{Version: "v1", Kind: "Endpoints"}},
+		wantProblems: []string{"no automatic conversion is available: v1 Endpoints is deprecated in v1.33, use discovery.k8s.io/v1 EndpointSlice instead"},
+	}, {
+		name:   "json",
+		target: "1.25",
+		json:   true,
+		input:  `{"apiVersion": "batch/v1beta1", "kind": "CronJob", "metadata": {"name": "job"}, "spec": {"schedule": "* * * * *", "suspend": true, "jobTemplate": {}}}`,
+		want: `{
+  "apiVersion": "batch/v1",
+  "kind": "CronJob",
+  "metadata": {
+    "name": "job"
+  },
+  "spec": {
+    "schedule": "* * * * *",
+    "suspend": true,
+    "jobTemplate": {}
+  }
+}
+`,
+		wantTo: []schema.GroupVersionKind{{Group: "batch", Version: "v1", Kind: "CronJob"}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, results, err := File([]byte(tt.input), tt.json, version.MustParseGeneric(tt.target))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+
+			var gotTo []schema.GroupVersionKind
+			var gotProblems []string
+			for _, res := range results {
+				gotTo = append(gotTo, res.To)
+				gotProblems = append(gotProblems, res.Problems...)
+			}
+			assert.Equal(t, tt.wantTo, gotTo)
+			assert.Equal(t, tt.wantProblems, gotProblems)
+		})
+	}
+}
+
+func TestConverters(t *testing.T) {
+	for from, conv := range converters {
+		assert.True(t, clientscheme.Scheme.Recognizes(from), "%s is not known to the scheme", from)
+		assert.True(t, clientscheme.Scheme.Recognizes(conv.to), "%s is not known to the scheme", conv.to)
+	}
+}
@@ -0,0 +1,228 @@
+package migrate
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "go.yaml.in/yaml/v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Helpers for reading YAML mapping nodes, and for merging a converted object
+// into the node tree of the original document. Reusing the nodes of the
+// original document preserves comments, the order of fields and the style of
+// values.
+
+func get(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func getPath(n *yaml.Node, keys ...string) *yaml.Node {
+	for _, k := range keys {
+		n = get(n, k)
+	}
+	return n
+}
+
+func has(n *yaml.Node, key string) bool {
+	return get(n, key) != nil
+}
+
+func items(n *yaml.Node) []*yaml.Node {
+	if n == nil || n.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return n.Content
+}
+
+func isEmptyMapping(n *yaml.Node) bool {
+	return n != nil && n.Kind == yaml.MappingNode && len(n.Content) == 0
+}
+
+// merge returns the node tree of the converted value conv of type t, reusing
+// the nodes of orig wherever the value did not change. Fields keep the order
+// they have in orig. Fields only found in conv take the place of the first
+// field of orig which was dropped, as they usually replace it, or else are
+// appended. Fields of orig set to an empty value are kept if t has them,
+// even though encoding conv omits them.
+func merge(orig, conv *yaml.Node, t reflect.Type) *yaml.Node {
+	if orig == nil {
+		return fresh(conv)
+	}
+	switch {
+	case orig.Kind == yaml.MappingNode && conv.Kind == yaml.MappingNode:
+		var added []*yaml.Node
+		for i := 0; i+1 < len(conv.Content); i += 2 {
+			if has(orig, conv.Content[i].Value) {
+				continue
+			}
+			if value := fresh(conv.Content[i+1]); value != nil {
+				added = append(added, fresh(conv.Content[i]), value)
+			}
+		}
+
+		res := *orig
+		res.Content = nil
+		for i := 0; i+1 < len(orig.Content); i += 2 {
+			key, value := orig.Content[i], orig.Content[i+1]
+			if converted := get(conv, key.Value); converted != nil {
+				res.Content = append(res.Content, key, merge(value, converted, fieldType(t, key.Value)))
+			} else if isEmpty(value) && fieldType(t, key.Value) != nil {
+				res.Content = append(res.Content, key, value)
+			} else if len(added) > 0 {
+				added[0].HeadComment = key.HeadComment
+				res.Content = append(res.Content, added...)
+				added = nil
+			}
+		}
+		res.Content = append(res.Content, added...)
+		return &res
+	case orig.Kind == yaml.SequenceNode && conv.Kind == yaml.SequenceNode:
+		res := *orig
+		res.Content = make([]*yaml.Node, len(conv.Content))
+		for i, item := range conv.Content {
+			if i < len(orig.Content) {
+				res.Content[i] = merge(orig.Content[i], item, itemType(t))
+			} else if res.Content[i] = fresh(item); res.Content[i] == nil {
+				res.Content[i] = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+			}
+		}
+		return &res
+	case orig.Kind == yaml.ScalarNode && conv.Kind == yaml.ScalarNode:
+		if sameScalar(orig, conv) {
+			return orig
+		}
+		res := *orig
+		res.Value, res.Tag = conv.Value, conv.Tag
+		if res.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+			res.Style = 0
+		}
+		return &res
+	}
+	if res := fresh(conv); res != nil {
+		return res
+	}
+	return conv
+}
+
+// fresh returns a copy of the converted node n in block style, without the
+// empty values left by encoding unset fields. Nil is returned if nothing is
+// left.
+func fresh(n *yaml.Node) *yaml.Node {
+	res := *n
+	res.Style, res.Line, res.Column = 0, 0, 0
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if isEmpty(n) {
+			return nil
+		}
+	case yaml.MappingNode:
+		res.Content = nil
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if value := fresh(n.Content[i+1]); value != nil {
+				res.Content = append(res.Content, fresh(n.Content[i]), value)
+			}
+		}
+		if len(res.Content) == 0 {
+			return nil
+		}
+	case yaml.SequenceNode:
+		res.Content = make([]*yaml.Node, len(n.Content))
+		for i, item := range n.Content {
+			if res.Content[i] = fresh(item); res.Content[i] == nil {
+				res.Content[i] = item
+			}
+		}
+	}
+	return &res
+}
+
+// isEmpty returns true if n holds the zero value of its type, such as the
+// empty string or false
+func isEmpty(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		switch n.Tag {
+		case "!!null":
+			return true
+		case "!!str":
+			return n.Value == ""
+		case "!!int", "!!float":
+			f, err := strconv.ParseFloat(n.Value, 64)
+			return err == nil && f == 0
+		case "!!bool":
+			return n.Value == "false"
+		}
+	case yaml.MappingNode, yaml.SequenceNode:
+		return len(n.Content) == 0
+	}
+	return false
+}
+
+// fieldType returns the type of the value of key in a mapping encoding t:
+// the type of the field encoded as key if t is a struct, or of the values of
+// t if it is a map. Nil is returned if t has no such field.
+func fieldType(t reflect.Type, key string) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem()
+	case reflect.Struct:
+		for i := range t.NumField() {
+			field := t.Field(i)
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if field.Anonymous && (name == "" || options == "inline") {
+				if res := fieldType(field.Type, key); res != nil {
+					return res
+				}
+			} else if name == key {
+				return field.Type
+			}
+		}
+	}
+	return nil
+}
+
+// itemType returns the type of the items of the slice type t, or nil
+func itemType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Slice {
+		return nil
+	}
+	return t.Elem()
+}
+
+// sameScalar returns true if the scalars hold the same value. Quantities and
+// durations are compared by value, as conversion writes them in their
+// canonical form.
+func sameScalar(a, b *yaml.Node) bool {
+	if a.Value == b.Value {
+		return true
+	}
+	if qa, err := resource.ParseQuantity(a.Value); err == nil {
+		qb, err := resource.ParseQuantity(b.Value)
+		return err == nil && qa.Cmp(qb) == 0
+	}
+	if da, err := time.ParseDuration(a.Value); err == nil {
+		db, err := time.ParseDuration(b.Value)
+		return err == nil && da == db
+	}
+	return false
+}
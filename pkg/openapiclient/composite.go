@@ -1,6 +1,7 @@
 package openapiclient
 
 import (
+	"context"
 	"errors"
 
 	"k8s.io/client-go/openapi"
@@ -17,10 +18,14 @@ func NewComposite(clients ...openapi.Client) openapi.Client {
 }
 
 func (c compositeClient) Paths() (map[string]openapi.GroupVersion, error) {
+	return c.PathsContext(context.Background())
+}
+
+func (c compositeClient) PathsContext(ctx context.Context) (map[string]openapi.GroupVersion, error) {
 	merged := map[string][]openapi.GroupVersion{}
 	var allErrors []error
 	for _, client := range c.clients {
-		paths, err := client.Paths()
+		paths, err := Paths(ctx, client)
 		if err != nil {
 			allErrors = append(allErrors, err)
 			continue
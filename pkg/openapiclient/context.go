@@ -0,0 +1,61 @@
+package openapiclient
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/client-go/openapi"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
+)
+
+// ContextClient is implemented by clients whose paths can be listed with a
+// context, cancelling any request made once it is done
+type ContextClient interface {
+	openapi.Client
+	PathsContext(ctx context.Context) (map[string]openapi.GroupVersion, error)
+}
+
+// Paths lists the paths of client, cancelling any request made once ctx is
+// done. Clients which do not implement ContextClient are only checked for
+// cancellation before being called.
+func Paths(ctx context.Context, client openapi.Client) (map[string]openapi.GroupVersion, error) {
+	if c, ok := client.(ContextClient); ok {
+		return c.PathsContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return client.Paths()
+}
+
+type timeoutClient struct {
+	delegate openapi.Client
+	timeout  time.Duration
+}
+
+// NewTimeout creates a client which gives up on any request of delegate, be
+// it listing its paths or fetching the document of a group version, after
+// the given duration. A zero duration means no timeout.
+func NewTimeout(timeout time.Duration, delegate openapi.Client) openapi.Client {
+	if timeout <= 0 {
+		return delegate
+	}
+	return timeoutClient{delegate: delegate, timeout: timeout}
+}
+
+func (t timeoutClient) Paths() (map[string]openapi.GroupVersion, error) {
+	return t.PathsContext(context.Background())
+}
+
+func (t timeoutClient) PathsContext(ctx context.Context) (map[string]openapi.GroupVersion, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	res, err := Paths(ctx, t.delegate)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range res {
+		res[k] = groupversion.NewForTimeout(v, t.timeout)
+	}
+	return res, nil
+}
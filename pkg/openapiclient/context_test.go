@@ -0,0 +1,106 @@
+package openapiclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
+)
+
+// hangingServer serves the given documents by path, and never responds to
+// requests for any other path until the request is cancelled
+func hangingServer(t *testing.T, documents map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if document, ok := documents[r.URL.Path]; ok {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(document))
+			return
+		}
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// assertCancelled checks that fn gives up soon after ctx is done
+func assertCancelled(t *testing.T, fn func(ctx context.Context) error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := fn(ctx)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "expected deadline exceeded, got %v", err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestGitHubBuiltinsCancel(t *testing.T) {
+	server := hangingServer(t, nil)
+	client := githubBuiltins{version: "1.27", apiURL: server.URL}
+	assertCancelled(t, func(ctx context.Context) error {
+		_, err := client.PathsContext(ctx)
+		return err
+	})
+}
+
+func TestHttpGroupVersionCancel(t *testing.T) {
+	server := hangingServer(t, nil)
+	gv := groupversion.NewForSource(groupversion.NewForHttp(server.URL+"/apis/apps/v1.json"), SourceGitHub)
+	assertCancelled(t, func(ctx context.Context) error {
+		_, err := groupversion.Schema(ctx, gv, "application/json")
+		return err
+	})
+}
+
+func TestKubeConfigCancel(t *testing.T) {
+	server := hangingServer(t, map[string]string{
+		"/openapi/v3": `{"paths": {"apis/apps/v1": {"serverRelativeURL": "/openapi/v3/apis/apps/v1?hash=ABC"}}}`,
+	})
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+	client := &kubeConfig{restClient: clientset.Discovery().RESTClient()}
+
+	paths, err := client.PathsContext(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, paths, "apis/apps/v1")
+	assert.Equal(t, SourceCluster, groupversion.SchemaSource(paths["apis/apps/v1"], ""))
+	assertCancelled(t, func(ctx context.Context) error {
+		_, err := groupversion.Schema(ctx, paths["apis/apps/v1"], "application/json")
+		return err
+	})
+
+	clientset, err = kubernetes.NewForConfig(&rest.Config{Host: hangingServer(t, nil).URL})
+	require.NoError(t, err)
+	hanging := &kubeConfig{restClient: clientset.Discovery().RESTClient()}
+	assertCancelled(t, func(ctx context.Context) error {
+		_, err := hanging.PathsContext(ctx)
+		return err
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	server := hangingServer(t, nil)
+	client := NewTimeout(50*time.Millisecond, NewComposite(
+		NewOverlay(nil, githubBuiltins{version: "1.27", apiURL: server.URL}),
+	))
+	start := time.Now()
+	_, err := client.Paths()
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "expected deadline exceeded, got %v", err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// The timeout applies to each request, not all of them
+	gv := groupversion.NewForTimeout(groupversion.NewForHttp(server.URL), 50*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		_, err := gv.Schema("application/json")
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "expected deadline exceeded, got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package openapiclient
+
+import (
+	"fmt"
+
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver"
+	"k8s.io/client-go/openapi"
+)
+
+// client which provides openapi for CRD objects held in memory
+type crdsClient struct {
+	crds []*apiextensionsv1.CustomResourceDefinition
+}
+
+// NewCRDs creates a client serving the schemas of the given CRDs, as
+// NewLocalCRDFiles does for CRDs read from files
+func NewCRDs(crds ...*apiextensionsv1.CustomResourceDefinition) openapi.Client {
+	return &crdsClient{crds: crds}
+}
+
+func (k *crdsClient) Paths() (map[string]openapi.GroupVersion, error) {
+	if len(k.crds) == 0 {
+		return nil, nil
+	}
+	crds := make([]*apiextensions.CustomResourceDefinition, 0, len(k.crds))
+	for _, crd := range k.crds {
+		var internal apiextensions.CustomResourceDefinition
+		if err := apiserver.Scheme.Convert(crd, &internal, nil); err != nil {
+			return nil, fmt.Errorf("failed to convert CRD %s: %w", crd.Name, err)
+		}
+		crds = append(crds, &internal)
+	}
+	return crdPaths(crds, SourceCRDs)
+}
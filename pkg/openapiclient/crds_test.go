@@ -0,0 +1,57 @@
+package openapiclient_test
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
+	"sigs.k8s.io/kubectl-validate/pkg/utils"
+	"sigs.k8s.io/yaml"
+)
+
+// Shows that CRD objects are served the same documents as the files they
+// are read from
+func TestNewCRDs(t *testing.T) {
+	files := fstest.MapFS{}
+	var crds []*apiextensionsv1.CustomResourceDefinition
+	for _, file := range []string{"cel_basic.yaml", "jobset.yaml"} {
+		data, err := os.ReadFile("../../testcases/crds/" + file)
+		require.NoError(t, err)
+		files[file] = &fstest.MapFile{Data: data}
+
+		documents, err := utils.SplitYamlDocuments(data)
+		require.NoError(t, err)
+		for _, document := range documents {
+			if utils.IsEmptyYamlDocument(document) {
+				continue
+			}
+			var crd apiextensionsv1.CustomResourceDefinition
+			require.NoError(t, yaml.Unmarshal(document, &crd))
+			crds = append(crds, &crd)
+		}
+	}
+
+	want, err := openapiclient.NewLocalCRDFiles(files).Paths()
+	require.NoError(t, err)
+	got, err := openapiclient.NewCRDs(crds...).Paths()
+	require.NoError(t, err)
+	require.Len(t, got, len(want))
+	for path, gv := range want {
+		require.Contains(t, got, path)
+		wantDocument, err := gv.Schema("application/json")
+		require.NoError(t, err)
+		gotDocument, err := got[path].Schema("application/json")
+		require.NoError(t, err)
+		assert.JSONEq(t, string(wantDocument), string(gotDocument), path)
+		assert.Equal(t, openapiclient.SourceCRDs, groupversion.SchemaSource(got[path], ""))
+	}
+
+	paths, err := openapiclient.NewCRDs().Paths()
+	assert.NoError(t, err)
+	assert.Empty(t, paths)
+}
@@ -0,0 +1,159 @@
+package openapiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"k8s.io/client-go/openapi"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
+)
+
+// Schema providers are programs run by NewExec to serve schemas. Each request
+// runs the program, passing an ExecRequest on its stdin. The program writes
+// an ExecResponse to its stdout and exits with a zero status, or exits with a
+// non-zero status and explains why on its stderr.
+const (
+	// API version of the requests and responses exchanged with providers
+	ExecAPIVersion   = "kubectl-validate.sigs.k8s.io/v1alpha1"
+	ExecRequestKind  = "SchemaRequest"
+	ExecResponseKind = "SchemaResponse"
+)
+
+// ExecRequest is passed to schema providers on their stdin
+type ExecRequest struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// Path of the group version whose OpenAPI v3 document is requested, such
+	// as apis/example.com/v1 or api/v1. Empty to request the list of group
+	// versions the provider serves.
+	GroupVersion string `json:"groupVersion,omitempty"`
+}
+
+// ExecResponse is written by schema providers on their stdout
+type ExecResponse struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// Paths of the group versions served, in answer to a request without a
+	// group version
+	GroupVersions []string `json:"groupVersions,omitempty"`
+	// OpenAPI v3 document of the requested group version
+	Document json.RawMessage `json:"document,omitempty"`
+}
+
+// ExecProvider configures a schema provider, as client-go configures
+// credential plugins
+type ExecProvider struct {
+	// Name of the provider, which the schemas it serves are labelled with
+	Name string `json:"name"`
+	// Command to run, looked up in PATH if not a path
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	// Environment variables set for the command on top of those of the
+	// current process
+	Env []ExecEnvVar `json:"env,omitempty"`
+}
+
+// ExecEnvVar is an environment variable set for a schema provider
+type ExecEnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// client which provides openapi served by a schema provider program
+type execClient struct {
+	provider ExecProvider
+}
+
+// NewExec creates a client serving the schemas of the given provider. See
+// ExecRequest for the protocol providers implement.
+func NewExec(provider ExecProvider) openapi.Client {
+	return &execClient{provider: provider}
+}
+
+// Source returns the source the schemas of the provider are labelled with
+func (p ExecProvider) Source() string {
+	return "exec:" + p.Name
+}
+
+func (k *execClient) Paths() (map[string]openapi.GroupVersion, error) {
+	return k.PathsContext(context.Background())
+}
+
+func (k *execClient) PathsContext(ctx context.Context) (map[string]openapi.GroupVersion, error) {
+	response, err := k.provider.run(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]openapi.GroupVersion{}
+	for _, gvPath := range response.GroupVersions {
+		res[gvPath] = groupversion.NewForSource(&execGroupVersion{provider: k.provider, path: gvPath}, k.provider.Source())
+	}
+	return res, nil
+}
+
+// run runs the provider for the document of the given group version, or the
+// list of group versions if empty
+func (p ExecProvider) run(ctx context.Context, gvPath string) (*ExecResponse, error) {
+	request, err := json.Marshal(ExecRequest{APIVersion: ExecAPIVersion, Kind: ExecRequestKind, GroupVersion: gvPath})
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, p.Command, p.Args...)
+	cmd.Env = os.Environ()
+	for _, env := range p.Env {
+		cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("schema provider %s failed: %w: %s", p.Name, err, message)
+		}
+		return nil, fmt.Errorf("schema provider %s failed: %w", p.Name, err)
+	}
+
+	var response ExecResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response of schema provider %s: %w", p.Name, err)
+	} else if response.APIVersion != ExecAPIVersion || response.Kind != ExecResponseKind {
+		return nil, fmt.Errorf("schema provider %s responded with %s %s, expected %s %s", p.Name, response.APIVersion, response.Kind, ExecAPIVersion, ExecResponseKind)
+	} else if gvPath != "" && len(response.Document) == 0 {
+		return nil, fmt.Errorf("schema provider %s responded without a document for %s", p.Name, gvPath)
+	}
+	return &response, nil
+}
+
+type execGroupVersion struct {
+	provider ExecProvider
+	path     string
+}
+
+func (gv *execGroupVersion) Schema(contentType string) ([]byte, error) {
+	return gv.SchemaContext(context.Background(), contentType)
+}
+
+func (gv *execGroupVersion) SchemaContext(ctx context.Context, contentType string) ([]byte, error) {
+	if !strings.EqualFold(contentType, "application/json") {
+		return nil, fmt.Errorf("only application/json content type is supported")
+	}
+	response, err := gv.provider.run(ctx, gv.path)
+	if err != nil {
+		return nil, err
+	}
+	return response.Document, nil
+}
+
+func (gv *execGroupVersion) ServerRelativeURL() string {
+	return ""
+}
@@ -0,0 +1,125 @@
+package openapiclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
+	"sigs.k8s.io/kubectl-validate/pkg/validator"
+)
+
+const execHelperEnv = "KUBECTL_VALIDATE_TEST_PROVIDER"
+
+// TestExecHelperProvider is not a test, but the schema provider run by the
+// tests below, serving the schemas of the testcase CRDs
+func TestExecHelperProvider(t *testing.T) {
+	mode := os.Getenv(execHelperEnv)
+	if mode == "" {
+		return
+	}
+	var request openapiclient.ExecRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&request); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	switch mode {
+	case "fail":
+		fmt.Fprintln(os.Stderr, "registry is unavailable")
+		os.Exit(1)
+	case "hang":
+		time.Sleep(time.Minute)
+	case "wrong-kind":
+		fmt.Print(`{"apiVersion": "v1", "kind": "ConfigMap"}`)
+		os.Exit(0)
+	}
+
+	response := openapiclient.ExecResponse{APIVersion: openapiclient.ExecAPIVersion, Kind: openapiclient.ExecResponseKind}
+	paths, err := openapiclient.NewLocalCRDFiles(os.DirFS("../../testcases/crds")).Paths()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if request.GroupVersion == "" {
+		for path := range paths {
+			response.GroupVersions = append(response.GroupVersions, path)
+		}
+	} else if gv, ok := paths[request.GroupVersion]; ok {
+		response.Document, err = gv.Schema("application/json")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "%s not found\n", request.GroupVersion)
+		os.Exit(1)
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(response); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func execHelperProvider(mode string) openapiclient.ExecProvider {
+	return openapiclient.ExecProvider{
+		Name:    "test",
+		Command: os.Args[0],
+		Args:    []string{"-test.run=^TestExecHelperProvider$"},
+		Env:     []openapiclient.ExecEnvVar{{Name: execHelperEnv, Value: mode}},
+	}
+}
+
+func TestExec(t *testing.T) {
+	client := openapiclient.NewExec(execHelperProvider("serve"))
+	paths, err := client.Paths()
+	require.NoError(t, err)
+	require.Contains(t, paths, "apis/stable.example.com/v1")
+
+	gv := paths["apis/stable.example.com/v1"]
+	assert.Equal(t, "exec:test", groupversion.SchemaSource(gv, ""))
+	document, err := gv.Schema("application/json")
+	require.NoError(t, err)
+	assert.Contains(t, string(document), "CELBasic")
+
+	v, err := validator.New(client)
+	require.NoError(t, err)
+	gvk, obj, err := v.Parse([]byte(`{"apiVersion": "stable.example.com/v1", "kind": "CELBasic", "metadata": {"name": "test", "namespace": "default"}, "value": -1}`))
+	require.NoError(t, err)
+	assert.ErrorContains(t, v.Validate(obj), "value")
+	assert.Equal(t, "exec:test", v.SchemaSource(gvk))
+}
+
+func TestExecErrors(t *testing.T) {
+	_, err := openapiclient.NewExec(execHelperProvider("fail")).Paths()
+	assert.ErrorContains(t, err, "schema provider test failed")
+	assert.ErrorContains(t, err, "registry is unavailable")
+
+	_, err = openapiclient.NewExec(execHelperProvider("wrong-kind")).Paths()
+	assert.ErrorContains(t, err, "schema provider test responded with v1 ConfigMap")
+
+	gv, err := openapiclient.NewExec(execHelperProvider("serve")).Paths()
+	require.NoError(t, err)
+	_, err = gv["apis/stable.example.com/v1"].Schema("application/protobuf")
+	assert.Error(t, err)
+
+	_, err = openapiclient.NewExec(openapiclient.ExecProvider{Name: "missing", Command: "./does-not-exist"}).Paths()
+	assert.ErrorContains(t, err, "schema provider missing failed")
+}
+
+func TestExecCancel(t *testing.T) {
+	client := openapiclient.NewExec(execHelperProvider("hang"))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := openapiclient.Paths(ctx, client)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "expected deadline exceeded, got %v", err)
+	assert.Less(t, time.Since(start), 30*time.Second)
+}
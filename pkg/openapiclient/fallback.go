@@ -1,6 +1,7 @@
 package openapiclient
 
 import (
+	"context"
 	"errors"
 	"sync/atomic"
 
@@ -13,13 +14,17 @@ type fallbackClient struct {
 }
 
 func (f *fallbackClient) Paths() (map[string]openapi.GroupVersion, error) {
+	return f.PathsContext(context.Background())
+}
+
+func (f *fallbackClient) PathsContext(ctx context.Context) (map[string]openapi.GroupVersion, error) {
 	if chosen := f.chosenClient.Load(); chosen != nil {
-		return (*chosen).Paths()
+		return Paths(ctx, *chosen)
 	}
 
 	var errs []error
 	for _, c := range f.clients {
-		res, err := c.Paths()
+		res, err := Paths(ctx, c)
 		if err == nil {
 			f.chosenClient.Store(&c)
 			return res, err
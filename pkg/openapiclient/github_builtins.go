@@ -1,12 +1,14 @@
 package openapiclient
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 
+	"k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/client-go/openapi"
 	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
 )
@@ -14,6 +16,7 @@ import (
 // client which sources openapi definitions from GitHub
 type githubBuiltins struct {
 	version string
+	apiURL  string
 }
 
 type ghResponseObject struct {
@@ -24,19 +27,38 @@ type ghResponseObject struct {
 }
 
 func NewGitHubBuiltins(k8sVersion string) openapi.Client {
+	return NewGitHubMirrorBuiltins("https://api.github.com", k8sVersion)
+}
+
+// NewGitHubMirrorBuiltins is like NewGitHubBuiltins, but fetches schemas from
+// the GitHub API served at apiURL, such as that of a GitHub Enterprise server
+// mirroring the kubernetes/kubernetes repository
+func NewGitHubMirrorBuiltins(apiURL string, k8sVersion string) openapi.Client {
 	return githubBuiltins{
 		version: k8sVersion,
+		apiURL:  strings.TrimSuffix(apiURL, "/"),
 	}
 }
 
 func (g githubBuiltins) Paths() (map[string]openapi.GroupVersion, error) {
+	return g.PathsContext(context.Background())
+}
+
+func (g githubBuiltins) PathsContext(ctx context.Context) (map[string]openapi.GroupVersion, error) {
 	if len(g.version) == 0 {
 		return nil, nil
 	}
+	if v, err := version.ParseGeneric(g.version); err == nil && v.LessThan(firstOpenAPIV3Version) {
+		return g.openapiV2Paths(ctx)
+	}
 
 	// xh "https://api.github.com/repos/kubernetes/kubernetes/contents/api/openapi-spec/v3?ref=release-1.27" Accept:"application/vnd.github+json"
 	//TODO: responses use and respect ETAG. use a disk cache
-	ghResponse, err := http.Get(fmt.Sprintf("https://api.github.com/repos/kubernetes/kubernetes/contents/api/openapi-spec/v3?ref=release-%v", g.version))
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/repos/kubernetes/kubernetes/contents/api/openapi-spec/v3?ref=release-%v", g.apiURL, g.version), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	ghResponse, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error retreiving specs from GitHub: %w", err)
 	}
@@ -90,7 +112,39 @@ func (g githubBuiltins) Paths() (map[string]openapi.GroupVersion, error) {
 		if len(group) == 0 {
 			key = "api/" + version
 		}
-		res[key] = groupversion.NewForHttp(f.DownloadURI)
+		res[key] = groupversion.NewForSource(groupversion.NewForHttp(f.DownloadURI), SourceGitHub)
 	}
 	return res, nil
 }
+
+// Releases before 1.23 only publish an OpenAPI v2 document
+var firstOpenAPIV3Version = version.MajorMinor(1, 23)
+
+// openapiV2Paths serves the OpenAPI v2 document of the release converted by
+// ConvertOpenAPIV2
+func (g githubBuiltins) openapiV2Paths(ctx context.Context) (map[string]openapi.GroupVersion, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/repos/kubernetes/kubernetes/contents/api/openapi-spec/swagger.json?ref=release-%v", g.apiURL, g.version), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github.raw+json")
+	ghResponse, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error retreiving specs from GitHub: %w", err)
+	}
+	defer ghResponse.Body.Close() //nolint:errcheck
+	ghBody, err := io.ReadAll(ghResponse.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading specs from GitHub: %w", err)
+	}
+
+	if ghResponse.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download GitHub spec for version '%v': %v", g.version, string(ghBody))
+	}
+
+	documents, err := ConvertOpenAPIV2(ghBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert GitHub spec for version '%v': %w", g.version, err)
+	}
+	return openapiV2Paths(documents, SourceGitHub), nil
+}
@@ -1,8 +1,10 @@
 package groupversion
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 
 	"k8s.io/client-go/openapi"
 	"k8s.io/kube-openapi/pkg/spec3"
@@ -11,13 +13,21 @@ import (
 
 type compositeGroupVersion struct {
 	gvFetchers []openapi.GroupVersion
+
+	// Fetcher which served each definition of the last combined document
+	mu      sync.Mutex
+	servers map[string]openapi.GroupVersion
 }
 
 func (gv *compositeGroupVersion) Schema(contentType string) ([]byte, error) {
+	return gv.SchemaContext(context.Background(), contentType)
+}
+
+func (gv *compositeGroupVersion) SchemaContext(ctx context.Context, contentType string) ([]byte, error) {
 	if len(gv.gvFetchers) == 0 {
 		return nil, fmt.Errorf("no fetches for groupversion")
 	} else if len(gv.gvFetchers) == 1 {
-		return gv.gvFetchers[0].Schema(contentType)
+		return Schema(ctx, gv.gvFetchers[0], contentType)
 	}
 
 	combined := spec3.OpenAPI{
@@ -26,8 +36,9 @@ func (gv *compositeGroupVersion) Schema(contentType string) ([]byte, error) {
 		},
 	}
 
+	servers := map[string]openapi.GroupVersion{}
 	for _, fetcher := range gv.gvFetchers {
-		fetched, err := fetcher.Schema(contentType)
+		fetched, err := Schema(ctx, fetcher, contentType)
 		if err != nil {
 			return nil, err
 		}
@@ -42,16 +53,32 @@ func (gv *compositeGroupVersion) Schema(contentType string) ([]byte, error) {
 		for k, d := range parsed.Components.Schemas {
 			if _, existing := combined.Components.Schemas[k]; !existing {
 				combined.Components.Schemas[k] = d
+				servers[k] = fetcher
 			}
 		}
 	}
 
+	gv.mu.Lock()
+	gv.servers = servers
+	gv.mu.Unlock()
 	return json.Marshal(&combined)
 }
 
+func (gv *compositeGroupVersion) SchemaSource(definition string) string {
+	if len(gv.gvFetchers) == 1 {
+		return SchemaSource(gv.gvFetchers[0], definition)
+	}
+	gv.mu.Lock()
+	defer gv.mu.Unlock()
+	if server, ok := gv.servers[definition]; ok {
+		return SchemaSource(server, definition)
+	}
+	return ""
+}
+
 func (gv *compositeGroupVersion) ServerRelativeURL() string {
 	return ""
 }
 func NewForComposite(gvFetchers ...openapi.GroupVersion) openapi.GroupVersion {
-	return &compositeGroupVersion{gvFetchers}
+	return &compositeGroupVersion{gvFetchers: gvFetchers}
 }
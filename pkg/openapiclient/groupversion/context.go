@@ -0,0 +1,57 @@
+package groupversion
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/client-go/openapi"
+)
+
+// ContextGroupVersion is implemented by group versions whose documents can be
+// fetched with a context, cancelling the fetch once it is done
+type ContextGroupVersion interface {
+	openapi.GroupVersion
+	SchemaContext(ctx context.Context, contentType string) ([]byte, error)
+}
+
+// Schema fetches the document of gv, cancelling the fetch once ctx is done.
+// Group versions which do not implement ContextGroupVersion are only checked
+// for cancellation before their document is fetched.
+func Schema(ctx context.Context, gv openapi.GroupVersion, contentType string) ([]byte, error) {
+	if c, ok := gv.(ContextGroupVersion); ok {
+		return c.SchemaContext(ctx, contentType)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return gv.Schema(contentType)
+}
+
+type timeoutGroupVersion struct {
+	delegate openapi.GroupVersion
+	timeout  time.Duration
+}
+
+func (gv *timeoutGroupVersion) Schema(contentType string) ([]byte, error) {
+	return gv.SchemaContext(context.Background(), contentType)
+}
+
+func (gv *timeoutGroupVersion) SchemaContext(ctx context.Context, contentType string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, gv.timeout)
+	defer cancel()
+	return Schema(ctx, gv.delegate, contentType)
+}
+
+func (gv *timeoutGroupVersion) ServerRelativeURL() string {
+	return gv.delegate.ServerRelativeURL()
+}
+
+func (gv *timeoutGroupVersion) SchemaSource(definition string) string {
+	return SchemaSource(gv.delegate, definition)
+}
+
+// NewForTimeout gives up on fetching the document of delegate after the
+// given duration
+func NewForTimeout(delegate openapi.GroupVersion, timeout time.Duration) openapi.GroupVersion {
+	return &timeoutGroupVersion{delegate, timeout}
+}
@@ -0,0 +1,29 @@
+package groupversion
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/openapi"
+)
+
+type documentGroupVersion struct {
+	document []byte
+}
+
+func (gv *documentGroupVersion) Schema(contentType string) ([]byte, error) {
+	if strings.ToLower(contentType) != runtime.ContentTypeJSON {
+		return nil, fmt.Errorf("only application/json content type is supported")
+	}
+	return gv.document, nil
+}
+
+func (gv *documentGroupVersion) ServerRelativeURL() string {
+	return ""
+}
+
+// NewForDocument serves the given JSON OpenAPI document
+func NewForDocument(document []byte) openapi.GroupVersion {
+	return &documentGroupVersion{document}
+}
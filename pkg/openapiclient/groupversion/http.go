@@ -1,6 +1,7 @@
 package groupversion
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,8 +14,12 @@ type httpGroupVersion struct {
 }
 
 func (gv *httpGroupVersion) Schema(contentType string) ([]byte, error) {
+	return gv.SchemaContext(context.Background(), contentType)
+}
+
+func (gv *httpGroupVersion) SchemaContext(ctx context.Context, contentType string) ([]byte, error) {
 	//TODO: responses use and respect ETAG. use a disk cache
-	req, err := http.NewRequest("GET", gv.uri, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", gv.uri, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
@@ -12,6 +12,8 @@ import (
 
 type OpenApiGroupVersion struct {
 	*spec3.OpenAPI
+	// Source of the schemas of the document, see SchemaSource
+	Source string
 }
 
 func (gv *OpenApiGroupVersion) Schema(contentType string) ([]byte, error) {
@@ -25,6 +27,10 @@ func (gv *OpenApiGroupVersion) ServerRelativeURL() string {
 	return ""
 }
 
+func (gv *OpenApiGroupVersion) SchemaSource(string) string {
+	return gv.Source
+}
+
 func NewForOpenAPI(spec *spec3.OpenAPI) openapi.GroupVersion {
-	return &OpenApiGroupVersion{spec}
+	return &OpenApiGroupVersion{OpenAPI: spec}
 }
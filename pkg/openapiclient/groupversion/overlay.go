@@ -1,6 +1,7 @@
 package groupversion
 
 import (
+	"context"
 	"errors"
 
 	jsonpatch "github.com/evanphx/json-patch"
@@ -18,15 +19,19 @@ type overlayGroupVersion struct {
 }
 
 func (gv *overlayGroupVersion) Schema(contentType string) ([]byte, error) {
+	return gv.SchemaContext(context.Background(), contentType)
+}
+
+func (gv *overlayGroupVersion) SchemaContext(ctx context.Context, contentType string) ([]byte, error) {
 	patch := gv.patchLoader(gv.path)
 	if patch == nil {
-		return gv.delegate.Schema(contentType)
+		return Schema(ctx, gv.delegate, contentType)
 	}
 
 	if contentType != runtime.ContentTypeJSON {
 		return nil, errors.New("unsupported content type")
 	}
-	delegateRes, err := gv.delegate.Schema(contentType)
+	delegateRes, err := Schema(ctx, gv.delegate, contentType)
 	if err != nil {
 		return nil, err
 	}
@@ -42,6 +47,10 @@ func (gv *overlayGroupVersion) ServerRelativeURL() string {
 	return ""
 }
 
+func (gv *overlayGroupVersion) SchemaSource(definition string) string {
+	return SchemaSource(gv.delegate, definition)
+}
+
 func NewForOverlay(delegate openapi.GroupVersion, patchLoader PatchLoaderFn, path string) openapi.GroupVersion {
 	return &overlayGroupVersion{delegate, patchLoader, path}
 }
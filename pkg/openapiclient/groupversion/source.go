@@ -0,0 +1,44 @@
+package groupversion
+
+import (
+	"context"
+
+	"k8s.io/client-go/openapi"
+)
+
+// sourced is implemented by group versions which know where the schemas
+// they serve come from
+type sourced interface {
+	// SchemaSource returns the source of the definition by the given name.
+	// Only valid once Schema was called.
+	SchemaSource(definition string) string
+}
+
+type sourcedGroupVersion struct {
+	openapi.GroupVersion
+	source string
+}
+
+func (gv *sourcedGroupVersion) SchemaContext(ctx context.Context, contentType string) ([]byte, error) {
+	return Schema(ctx, gv.GroupVersion, contentType)
+}
+
+func (gv *sourcedGroupVersion) SchemaSource(string) string {
+	return gv.source
+}
+
+// NewForSource labels every schema served by delegate with the given source,
+// such as "builtin" or "cluster"
+func NewForSource(delegate openapi.GroupVersion, source string) openapi.GroupVersion {
+	return &sourcedGroupVersion{delegate, source}
+}
+
+// SchemaSource returns the source of the definition by the given name within
+// the document of gv, or an empty string if unknown. Only valid once the
+// document was fetched with Schema.
+func SchemaSource(gv openapi.GroupVersion, definition string) string {
+	if s, ok := gv.(sourced); ok {
+		return s.SchemaSource(definition)
+	}
+	return ""
+}
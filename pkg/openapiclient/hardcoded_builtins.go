@@ -58,7 +58,7 @@ func (k hardcodedResolver) Paths() (map[string]openapi.GroupVersion, error) {
 				// chop extension
 				ext := path.Ext(v.Name())
 				version := strings.TrimSuffix(v.Name(), ext)
-				res[fmt.Sprintf("api/%s", version)] = groupversion.NewForFile(&hardcodedBuiltins, path.Join(apiDir, v.Name()))
+				res[fmt.Sprintf("api/%s", version)] = groupversion.NewForSource(groupversion.NewForFile(&hardcodedBuiltins, path.Join(apiDir, v.Name())), SourceBuiltin)
 			}
 
 			apisDir := path.Join("builtins", v.Name(), "apis")
@@ -74,7 +74,7 @@ func (k hardcodedResolver) Paths() (map[string]openapi.GroupVersion, error) {
 					// chop extension
 					ext := path.Ext(v.Name())
 					version := strings.TrimSuffix(v.Name(), ext)
-					res[fmt.Sprintf("apis/%s/%s", g.Name(), version)] = groupversion.NewForFile(&hardcodedBuiltins, path.Join(gDir, v.Name()))
+					res[fmt.Sprintf("apis/%s/%s", g.Name(), version)] = groupversion.NewForSource(groupversion.NewForFile(&hardcodedBuiltins, path.Join(gDir, v.Name())), SourceBuiltin)
 				}
 			}
 
@@ -1,26 +1,40 @@
 package openapiclient
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"strings"
 
+	apimachineryversion "k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/openapi"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/kube-openapi/pkg/handler3"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
 )
 
 // Creates an openapi client that connects directly to cluster
 type kubeConfig struct {
-	client    openapi.Client
-	overrides clientcmd.ConfigOverrides
+	restClient rest.Interface
+	overrides  clientcmd.ConfigOverrides
 }
 
 func NewKubeConfig(overrides clientcmd.ConfigOverrides) openapi.Client {
-	return &kubeConfig{}
+	return &kubeConfig{overrides: overrides}
 }
 
 func (k *kubeConfig) Paths() (map[string]openapi.GroupVersion, error) {
-	if k.client == nil {
+	return k.PathsContext(context.Background())
+}
+
+// client returns the client of the cluster, loading the kubeconfig on first
+// use
+func (k *kubeConfig) client() (rest.Interface, error) {
+	if k.restClient == nil {
 		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
 		kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &k.overrides)
 
@@ -34,13 +48,110 @@ func (k *kubeConfig) Paths() (map[string]openapi.GroupVersion, error) {
 			return nil, fmt.Errorf("failed to create clientset for kubeconfig")
 		}
 
-		k.client = clientset.Discovery().OpenAPIV3()
+		k.restClient = clientset.Discovery().RESTClient()
+	}
+	return k.restClient, nil
+}
+
+func (k *kubeConfig) PathsContext(ctx context.Context) (map[string]openapi.GroupVersion, error) {
+	restClient, err := k.client()
+	if err != nil {
+		return nil, err
 	}
 
-	res, err := k.client.Paths()
+	res, err := clusterPaths(ctx, restClient)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download schemas from kubeconfig cluster: %w", err)
 	}
 
+	for k, v := range res {
+		res[k] = groupversion.NewForSource(v, SourceCluster)
+	}
+	return res, nil
+}
+
+func (k *kubeConfig) ServerVersionContext(ctx context.Context) (*apimachineryversion.Info, error) {
+	restClient, err := k.client()
+	if err != nil {
+		return nil, err
+	}
+	data, err := restClient.Get().AbsPath("/version").Do(ctx).Raw()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the version of the kubeconfig cluster: %w", err)
+	}
+	var info apimachineryversion.Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse the version of the kubeconfig cluster: %w", err)
+	}
+	return &info, nil
+}
+
+// clusterPaths lists the OpenAPI v3 documents served by the cluster, as
+// openapi.NewClient does, with requests bound to ctx
+func clusterPaths(ctx context.Context, restClient rest.Interface) (map[string]openapi.GroupVersion, error) {
+	data, err := restClient.Get().AbsPath("/openapi/v3").Do(ctx).Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	discovery := handler3.OpenAPIV3Discovery{}
+	if err := json.Unmarshal(data, &discovery); err != nil {
+		return nil, err
+	}
+
+	// Paths are relative to the server, while requests are made relative to
+	// the prefix of the client. See
+	// https://github.com/kubernetes/kubernetes/issues/117463
+	rootPrefix := strings.TrimSuffix(restClient.Get().AbsPath("/").URL().Path, "/")
+	res := map[string]openapi.GroupVersion{}
+	for k, v := range discovery.Paths {
+		relativeURL := strings.TrimPrefix(v.ServerRelativeURL, rootPrefix)
+		res[k] = &clusterGroupVersion{
+			restClient:      restClient,
+			relativeURL:     relativeURL,
+			useClientPrefix: strings.HasPrefix(relativeURL, "/openapi/v3"),
+		}
+	}
 	return res, nil
 }
+
+type clusterGroupVersion struct {
+	restClient  rest.Interface
+	relativeURL string
+	// Whether the URL is relative to the prefix of the client rather than
+	// the server
+	useClientPrefix bool
+}
+
+func (gv *clusterGroupVersion) Schema(contentType string) ([]byte, error) {
+	return gv.SchemaContext(context.Background(), contentType)
+}
+
+func (gv *clusterGroupVersion) SchemaContext(ctx context.Context, contentType string) ([]byte, error) {
+	if !gv.useClientPrefix {
+		return gv.restClient.Get().
+			RequestURI(gv.relativeURL).
+			SetHeader("Accept", contentType).
+			Do(ctx).
+			Raw()
+	}
+
+	locator, err := url.Parse(gv.relativeURL)
+	if err != nil {
+		return nil, err
+	}
+	req := gv.restClient.Get().
+		AbsPath(locator.Path).
+		SetHeader("Accept", contentType)
+	// The hash parameter is not kept by AbsPath
+	for k, values := range locator.Query() {
+		for _, v := range values {
+			req.Param(k, v)
+		}
+	}
+	return req.Do(ctx).Raw()
+}
+
+func (gv *clusterGroupVersion) ServerRelativeURL() string {
+	return gv.relativeURL
+}
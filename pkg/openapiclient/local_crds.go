@@ -86,7 +86,7 @@ func (k *localCRDsClient) Paths() (map[string]openapi.GroupVersion, error) {
 	}
 
 	codecs := serializer.NewCodecFactory(apiserver.Scheme).UniversalDecoder()
-	crds := map[schema.GroupVersion]*spec3.OpenAPI{}
+	var crds []*apiextensions.CustomResourceDefinition
 	crdGVK := schema.GroupVersionKind{
 		Group:   "apiextensions.k8s.io",
 		Version: runtime.APIVersionInternal,
@@ -111,6 +111,16 @@ func (k *localCRDsClient) Paths() (map[string]openapi.GroupVersion, error) {
 		if !ok {
 			return nil, fmt.Errorf("crd deserialized into incorrect type: %T", crdObj)
 		}
+		crds = append(crds, crd)
+	}
+	return crdPaths(crds, SourceLocalCRDs)
+}
+
+// crdPaths builds the documents of the group versions served by the CRDs,
+// labelled with the given source
+func crdPaths(crds []*apiextensions.CustomResourceDefinition, source string) (map[string]openapi.GroupVersion, error) {
+	documents := map[schema.GroupVersion]*spec3.OpenAPI{}
+	for _, crd := range crds {
 		for _, v := range crd.Spec.Versions {
 			// Convert schema to spec.Schema
 			jsProps, err := apiextensions.GetSchemaForVersion(crd, v.Name)
@@ -122,75 +132,85 @@ func (k *localCRDsClient) Paths() (map[string]openapi.GroupVersion, error) {
 				return nil, err
 			}
 			sch := ss.ToKubeOpenAPI()
-			gvk := schema.GroupVersionKind{
+			// Add schema extension to propagate the scope
+			sch.AddExtension("x-kubectl-validate-scope", string(crd.Spec.Scope))
+			addKindSchema(documents, schema.GroupVersionKind{
 				Group:   crd.Spec.Group,
 				Version: v.Name,
 				Kind:    crd.Spec.Names.Kind,
-			}
-			gvkObj := map[string]any{
-				"group":   gvk.Group,
-				"version": gvk.Version,
-				"kind":    gvk.Kind,
-			}
-			sch.AddExtension("x-kubernetes-group-version-kind", []any{gvkObj})
-			// Add schema extension to propagate the scope
-			sch.AddExtension("x-kubectl-validate-scope", string(crd.Spec.Scope))
-			key := fmt.Sprintf("%s/%s.%s", gvk.Group, gvk.Version, gvk.Kind)
+			}, sch)
+		}
+	}
+	return documentPaths(documents, source), nil
+}
 
-			if sch.Properties == nil {
-				sch.Properties = map[string]spec.Schema{}
-			}
+// addKindSchema adds the schema of a kind to the document of its group
+// version, with the properties of objects the apiserver serves for it
+func addKindSchema(documents map[schema.GroupVersion]*spec3.OpenAPI, gvk schema.GroupVersionKind, sch *spec.Schema) {
+	gvkObj := map[string]any{
+		"group":   gvk.Group,
+		"version": gvk.Version,
+		"kind":    gvk.Kind,
+	}
+	sch.AddExtension("x-kubernetes-group-version-kind", []any{gvkObj})
+	key := fmt.Sprintf("%s/%s.%s", gvk.Group, gvk.Version, gvk.Kind)
+
+	if sch.Properties == nil {
+		sch.Properties = map[string]spec.Schema{}
+	}
 
-			// Emulate APIServer behavior by injecting ObjectMeta & its Dependencies into CRD
-			sch.Properties["metadata"] = spec.Schema{
-				SchemaProps: spec.SchemaProps{
-					AllOf: []spec.Schema{
-						{
-							SchemaProps: spec.SchemaProps{
-								Ref: spec.MustCreateRef("#/components/schemas/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"),
-							},
-						},
+	// Emulate APIServer behavior by injecting ObjectMeta & its Dependencies into CRD
+	sch.Properties["metadata"] = spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			AllOf: []spec.Schema{
+				{
+					SchemaProps: spec.SchemaProps{
+						Ref: spec.MustCreateRef("#/components/schemas/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"),
 					},
-					Default:     map[string]interface{}{},
-					Description: "Standard object metadata; More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#metadata.",
-				},
-			}
-			sch.Properties["apiVersion"] = spec.Schema{
-				SchemaProps: spec.SchemaProps{
-					Default:     "",
-					Description: "API version of the referent.",
-					Type:        spec.StringOrArray{"string"},
-				},
-			}
-			sch.Properties["kind"] = spec.Schema{
-				SchemaProps: spec.SchemaProps{
-					Default:     "",
-					Description: "Kind of the referent. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
-					Type:        spec.StringOrArray{"string"},
 				},
-			}
+			},
+			Default:     map[string]interface{}{},
+			Description: "Standard object metadata; More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#metadata.",
+		},
+	}
+	sch.Properties["apiVersion"] = spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Default:     "",
+			Description: "API version of the referent.",
+			Type:        spec.StringOrArray{"string"},
+		},
+	}
+	sch.Properties["kind"] = spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Default:     "",
+			Description: "Kind of the referent. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
+			Type:        spec.StringOrArray{"string"},
+		},
+	}
 
-			if existing, exists := crds[gvk.GroupVersion()]; exists {
-				existing.Components.Schemas[key] = sch
-			} else {
-				crds[gvk.GroupVersion()] = &spec3.OpenAPI{
-					Components: &spec3.Components{
-						Schemas: map[string]*spec.Schema{
-							key: sch,
-						},
-					},
-				}
-			}
+	if existing, exists := documents[gvk.GroupVersion()]; exists {
+		existing.Components.Schemas[key] = sch
+	} else {
+		documents[gvk.GroupVersion()] = &spec3.OpenAPI{
+			Components: &spec3.Components{
+				Schemas: map[string]*spec.Schema{
+					key: sch,
+				},
+			},
 		}
 	}
+}
 
+// documentPaths serves the documents built with addKindSchema, labelled with
+// the given source
+func documentPaths(documents map[schema.GroupVersion]*spec3.OpenAPI, source string) map[string]openapi.GroupVersion {
 	res := map[string]openapi.GroupVersion{}
-	for k, v := range crds {
+	for k, v := range documents {
 		// Inject metadata definitions into each group-version document
 		for defName, def := range metadataSchemas {
 			v.Components.Schemas[defName] = def
 		}
-		res[fmt.Sprintf("apis/%s/%s", k.Group, k.Version)] = groupversion.NewForOpenAPI(v)
+		res[utils.GroupVersionPath(k)] = &groupversion.OpenApiGroupVersion{OpenAPI: v, Source: source}
 	}
-	return res, nil
+	return res
 }
@@ -55,7 +55,7 @@ func (k *localSchemasClient) Paths() (map[string]openapi.GroupVersion, error) {
 			}
 			name := strings.TrimSuffix(v.Name(), path.Ext(v.Name()))
 			apisPath := path.Join("apis", f.Name(), name)
-			res[apisPath] = groupversion.NewForFile(k.fs, path.Join(groupPath, v.Name()))
+			res[apisPath] = groupversion.NewForSource(groupversion.NewForFile(k.fs, path.Join(groupPath, v.Name())), SourceLocalSchemas)
 		}
 	}
 	coregroup, err := fs.ReadDir(k.fs, "api")
@@ -70,7 +70,7 @@ func (k *localSchemasClient) Paths() (map[string]openapi.GroupVersion, error) {
 		}
 		name := strings.TrimSuffix(v.Name(), path.Ext(v.Name()))
 		apiPath := path.Join("api", name)
-		res[apiPath] = groupversion.NewForFile(k.fs, path.Join("api", v.Name()))
+		res[apiPath] = groupversion.NewForSource(groupversion.NewForFile(k.fs, path.Join("api", v.Name())), SourceLocalSchemas)
 	}
 	return res, nil
 }
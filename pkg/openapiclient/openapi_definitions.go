@@ -0,0 +1,90 @@
+package openapiclient
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	openapinamer "k8s.io/apiserver/pkg/endpoints/openapi"
+	"k8s.io/client-go/openapi"
+	"k8s.io/kube-openapi/pkg/builder3"
+	"k8s.io/kube-openapi/pkg/common"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"k8s.io/kube-openapi/pkg/util"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
+	"sigs.k8s.io/kubectl-validate/pkg/utils"
+)
+
+// client which provides openapi for definitions generated by openapi-gen
+type openAPIDefinitionsClient struct {
+	getDefinitions common.GetOpenAPIDefinitions
+	schemes        []*runtime.Scheme
+}
+
+// NewOpenAPIDefinitions creates a client serving the definitions generated by
+// openapi-gen, such as the GetOpenAPIDefinitions function of an aggregated
+// API server. The kinds of the definitions are those of their types in the
+// given schemes. Definitions of types in none of them are only served as
+// dependencies of the kinds.
+//
+// Objects are validated as if cluster scoped, as definitions do not tell
+// whether a kind is namespaced.
+func NewOpenAPIDefinitions(getDefinitions common.GetOpenAPIDefinitions, schemes ...*runtime.Scheme) openapi.Client {
+	return &openAPIDefinitionsClient{getDefinitions: getDefinitions, schemes: schemes}
+}
+
+func (k *openAPIDefinitionsClient) Paths() (map[string]openapi.GroupVersion, error) {
+	namer := openapinamer.NewDefinitionNamer(k.schemes...)
+	// Definitions are named after the package of their type, unless their type
+	// names its model after the conventions of the apiserver
+	definitionName := func(name string) (string, spec.Extensions) {
+		if strings.Contains(name, "/") {
+			name = util.ToRESTFriendlyName(name)
+		}
+		return namer.GetDefinitionName(name)
+	}
+	definitions := k.getDefinitions(func(name string) spec.Ref {
+		defName, _ := definitionName(name)
+		return spec.MustCreateRef("#/components/schemas/" + common.EscapeJsonPointer(defName))
+	})
+
+	kinds := map[schema.GroupVersion][]string{}
+	for name := range definitions {
+		_, extensions := definitionName(name)
+		gvks, _ := extensions["x-kubernetes-group-version-kind"].([]interface{})
+		for _, gvk := range gvks {
+			gvk, ok := gvk.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			group, _ := gvk["group"].(string)
+			version, _ := gvk["version"].(string)
+			gv := schema.GroupVersion{Group: group, Version: version}
+			kinds[gv] = append(kinds[gv], name)
+		}
+	}
+
+	res := map[string]openapi.GroupVersion{}
+	for gv, names := range kinds {
+		sort.Strings(names)
+		// Only the kinds of the group version and their dependencies
+		schemas, err := builder3.BuildOpenAPIDefinitionsForResources(&common.OpenAPIV3Config{
+			Definitions:       definitions,
+			GetDefinitionName: definitionName,
+		}, names...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build the definitions of %s: %w", gv, err)
+		}
+		res[utils.GroupVersionPath(gv)] = &groupversion.OpenApiGroupVersion{
+			OpenAPI: &spec3.OpenAPI{
+				Version:    "3.0.0",
+				Components: &spec3.Components{Schemas: schemas},
+			},
+			Source: SourceOpenAPIDefinitions,
+		}
+	}
+	return res, nil
+}
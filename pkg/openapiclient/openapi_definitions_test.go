@@ -0,0 +1,57 @@
+package openapiclient_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver"
+	generatedopenapi "k8s.io/apiextensions-apiserver/pkg/generated/openapi"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+	"sigs.k8s.io/kubectl-validate/pkg/validator"
+)
+
+func TestNewOpenAPIDefinitions(t *testing.T) {
+	client := openapiclient.NewOpenAPIDefinitions(generatedopenapi.GetOpenAPIDefinitions, apiserver.Scheme)
+	paths, err := client.Paths()
+	require.NoError(t, err)
+	require.Contains(t, paths, "apis/apiextensions.k8s.io/v1")
+	require.Contains(t, paths, "apis/apiextensions.k8s.io/v1beta1")
+
+	v, err := validator.New(client)
+	require.NoError(t, err)
+	crd, err := os.ReadFile("../../testcases/crds/cel_basic.yaml")
+	require.NoError(t, err)
+	for _, tt := range []struct {
+		name     string
+		document string
+		wantErr  string
+	}{{
+		name:     "valid",
+		document: string(crd),
+	}, {
+		name:     "invalid",
+		document: "apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: widgets.example.com\nspec:\n  group: [example.com]\n",
+		wantErr:  "spec.group",
+	}, {
+		name:     "unknown field",
+		document: "apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: widgets.example.com\nspec:\n  groups: example.com\n",
+		wantErr:  "spec.groups: Invalid value: value provided for unknown field",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, obj, err := v.Parse([]byte(tt.document))
+			if err == nil {
+				err = v.Validate(obj)
+			}
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+	gvk := schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+	assert.Equal(t, openapiclient.SourceOpenAPIDefinitions, v.SchemaSource(gvk))
+}
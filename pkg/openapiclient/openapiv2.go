@@ -0,0 +1,193 @@
+package openapiclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/openapi"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
+	"sigs.k8s.io/kubectl-validate/pkg/utils"
+)
+
+// openapiV2Document is the part of an OpenAPI v2 document its conversion
+// needs
+type openapiV2Document struct {
+	Swagger     string                                `json:"swagger"`
+	Info        json.RawMessage                       `json:"info,omitempty"`
+	Paths       map[string]map[string]json.RawMessage `json:"paths"`
+	Definitions map[string]*spec.Schema               `json:"definitions"`
+}
+
+// openapiV3Document is a converted OpenAPI v3 document
+type openapiV3Document struct {
+	OpenAPI    string                                       `json:"openapi"`
+	Info       json.RawMessage                              `json:"info,omitempty"`
+	Paths      map[string]map[string]openapiV3KindOperation `json:"paths,omitempty"`
+	Components struct {
+		Schemas map[string]json.RawMessage `json:"schemas"`
+	} `json:"components"`
+}
+
+// openapiV3KindOperation is an operation of a converted document, with only
+// the extensions telling which kind it is for and what it does to it
+type openapiV3KindOperation struct {
+	Action string      `json:"x-kubernetes-action,omitempty"`
+	GVK    interface{} `json:"x-kubernetes-group-version-kind"`
+}
+
+// Methods of the operations kept by the conversion
+var openapiV2Methods = []string{"get", "put", "post", "delete", "patch"}
+
+const (
+	openapiV2ReferencePrefix = "#/definitions/"
+	openapiV3ReferencePrefix = "#/components/schemas/"
+)
+
+// ConvertOpenAPIV2 splits an OpenAPI v2 document, such as the swagger.json
+// of a Kubernetes release or cluster, into OpenAPI v3 documents keyed by the
+// path of their group version, as served by the /openapi/v3 endpoint.
+//
+// The kinds of each group version are found by their
+// x-kubernetes-group-version-kind extension. Its document holds their
+// definitions and those they refer to, and the operations on them with only
+// their extensions, which is what validation uses.
+func ConvertOpenAPIV2(document []byte) (map[string][]byte, error) {
+	var v2 openapiV2Document
+	if err := json.Unmarshal(document, &v2); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI v2 document: %w", err)
+	} else if v2.Swagger != "2.0" {
+		return nil, fmt.Errorf("expected an OpenAPI v2 document, got swagger version %q", v2.Swagger)
+	}
+
+	// References are the same in v3, where definitions are moved to
+	// components
+	definitions := map[string]json.RawMessage{}
+	references := map[string][]string{}
+	for name, def := range v2.Definitions {
+		if def == nil {
+			def = &spec.Schema{}
+			v2.Definitions[name] = def
+		}
+		refs := sets.New[string]()
+		utils.VisitSchema(name, def, utils.PreorderVisitor(func(_ utils.VisitingContext, sch *spec.Schema) (*spec.Schema, bool) {
+			if ref, ok := strings.CutPrefix(sch.Ref.String(), openapiV2ReferencePrefix); ok {
+				refs.Insert(ref)
+				sch.Ref = spec.MustCreateRef(openapiV3ReferencePrefix + ref)
+			}
+			return sch, true
+		}))
+		references[name] = sets.List(refs)
+		data, err := json.Marshal(def)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert definition %s: %w", name, err)
+		}
+		definitions[name] = data
+	}
+
+	documents := map[schema.GroupVersion]*openapiV3Document{}
+	documentFor := func(gv schema.GroupVersion) *openapiV3Document {
+		if existing, ok := documents[gv]; ok {
+			return existing
+		}
+		res := &openapiV3Document{OpenAPI: "3.0.0", Info: v2.Info, Paths: map[string]map[string]openapiV3KindOperation{}}
+		res.Components.Schemas = map[string]json.RawMessage{}
+		documents[gv] = res
+		return res
+	}
+
+	for name, def := range v2.Definitions {
+		for _, gvk := range utils.ExtractExtensionGVKs(def.Extensions) {
+			documentFor(gvk.GroupVersion()).Components.Schemas[name] = nil
+		}
+	}
+	for path, operations := range v2.Paths {
+		for _, method := range openapiV2Methods {
+			raw, ok := operations[method]
+			if !ok {
+				continue
+			}
+			var operation openapiV3KindOperation
+			if err := json.Unmarshal(raw, &operation); err != nil {
+				return nil, fmt.Errorf("failed to parse operation %s %s: %w", method, path, err)
+			}
+			extensions := utils.KindExtensions{GroupVersionKind: operation.GVK}
+			for _, gvk := range extensions.GVKs() {
+				v3 := documentFor(gvk.GroupVersion())
+				if v3.Paths[path] == nil {
+					v3.Paths[path] = map[string]openapiV3KindOperation{}
+				}
+				v3.Paths[path][method] = operation
+			}
+		}
+	}
+
+	res := map[string][]byte{}
+	for gv, v3 := range documents {
+		// Add the definitions of the kinds and those they refer to
+		queue := sets.List(sets.KeySet(v3.Components.Schemas))
+		for len(queue) > 0 {
+			name := queue[0]
+			queue = queue[1:]
+			if v3.Components.Schemas[name] != nil {
+				continue
+			}
+			def, ok := definitions[name]
+			if !ok {
+				return nil, fmt.Errorf("definition %s not found", name)
+			}
+			v3.Components.Schemas[name] = def
+			for _, ref := range references[name] {
+				if _, ok := v3.Components.Schemas[ref]; !ok {
+					v3.Components.Schemas[ref] = nil
+					queue = append(queue, ref)
+				}
+			}
+		}
+		data, err := json.Marshal(v3)
+		if err != nil {
+			return nil, err
+		}
+		res[utils.GroupVersionPath(gv)] = data
+	}
+	return res, nil
+}
+
+// client which provides openapi converted from an OpenAPI v2 document on disk
+type localOpenAPIV2Client struct {
+	fs   fs.FS
+	path string
+}
+
+// NewLocalOpenAPIV2File creates a client serving the group versions of the
+// OpenAPI v2 document at the given path, such as a swagger.json. See
+// ConvertOpenAPIV2.
+func NewLocalOpenAPIV2File(fs fs.FS, path string) openapi.Client {
+	return &localOpenAPIV2Client{fs: fs, path: path}
+}
+
+func (k *localOpenAPIV2Client) Paths() (map[string]openapi.GroupVersion, error) {
+	data, err := fs.ReadFile(k.fs, k.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", k.path, err)
+	}
+	documents, err := ConvertOpenAPIV2(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s: %w", k.path, err)
+	}
+	return openapiV2Paths(documents, SourceLocalSchemas), nil
+}
+
+// openapiV2Paths serves documents converted by ConvertOpenAPIV2, labelled with
+// the given source
+func openapiV2Paths(documents map[string][]byte, source string) map[string]openapi.GroupVersion {
+	res := map[string]openapi.GroupVersion{}
+	for gvPath, document := range documents {
+		res[gvPath] = groupversion.NewForSource(groupversion.NewForDocument(document), source)
+	}
+	return res
+}
@@ -0,0 +1,33 @@
+package openapiclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
+)
+
+func TestGitHubBuiltinsOpenAPIV2(t *testing.T) {
+	server := hangingServer(t, map[string]string{
+		"/repos/kubernetes/kubernetes/contents/api/openapi-spec/swagger.json": `{
+			"swagger": "2.0",
+			"paths": {},
+			"definitions": {
+				"io.k8s.api.core.v1.ConfigMap": {
+					"type": "object",
+					"properties": {"data": {"type": "object", "additionalProperties": {"type": "string"}}},
+					"x-kubernetes-group-version-kind": [{"group": "", "version": "v1", "kind": "ConfigMap"}]
+				}
+			}
+		}`,
+	})
+	// Releases before 1.23 only publish an OpenAPI v2 document
+	paths, err := githubBuiltins{version: "1.21", apiURL: server.URL}.Paths()
+	require.NoError(t, err)
+	require.Contains(t, paths, "api/v1")
+	assert.Equal(t, SourceGitHub, groupversion.SchemaSource(paths["api/v1"], ""))
+	document, err := paths["api/v1"].Schema("application/json")
+	require.NoError(t, err)
+	assert.Contains(t, string(document), `"components":{"schemas":{"io.k8s.api.core.v1.ConfigMap"`)
+}
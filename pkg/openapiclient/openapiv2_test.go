@@ -0,0 +1,181 @@
+package openapiclient_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
+	"sigs.k8s.io/kubectl-validate/pkg/utils"
+	"sigs.k8s.io/kubectl-validate/pkg/validator"
+)
+
+// swaggerFromBuiltins merges the builtin OpenAPI v3 documents of the given
+// group versions into an OpenAPI v2 document, as published by releases
+// before 1.23
+func swaggerFromBuiltins(t *testing.T, version string, gvPaths ...string) []byte {
+	t.Helper()
+	swagger := map[string]any{
+		"swagger":     "2.0",
+		"info":        map[string]any{"title": "Kubernetes", "version": "v" + version},
+		"paths":       map[string]json.RawMessage{},
+		"definitions": map[string]json.RawMessage{},
+	}
+	for _, gvPath := range gvPaths {
+		data, err := os.ReadFile(filepath.Join("builtins", version, gvPath+".json"))
+		require.NoError(t, err)
+		data = bytes.ReplaceAll(data, []byte(`"#/components/schemas/`), []byte(`"#/definitions/`))
+		var document struct {
+			Paths      map[string]json.RawMessage `json:"paths"`
+			Components struct {
+				Schemas map[string]json.RawMessage `json:"schemas"`
+			} `json:"components"`
+		}
+		require.NoError(t, json.Unmarshal(data, &document))
+		for k, v := range document.Paths {
+			swagger["paths"].(map[string]json.RawMessage)[k] = v
+		}
+		for k, v := range document.Components.Schemas {
+			swagger["definitions"].(map[string]json.RawMessage)[k] = v
+		}
+	}
+	res, err := json.Marshal(swagger)
+	require.NoError(t, err)
+	return res
+}
+
+func TestConvertOpenAPIV2(t *testing.T) {
+	var swagger map[string]any
+	require.NoError(t, json.Unmarshal(swaggerFromBuiltins(t, "1.23", "api/v1", "apis/apps/v1"), &swagger))
+	// Builtin documents are stripped of the extensions of their operations
+	swagger["paths"].(map[string]any)["/apis/apps/v1/namespaces/{namespace}/deployments"] = map[string]any{
+		"parameters": []any{map[string]any{"name": "namespace", "in": "path"}},
+		"post": map[string]any{
+			"operationId":                     "createAppsV1NamespacedDeployment",
+			"x-kubernetes-action":             "post",
+			"x-kubernetes-group-version-kind": map[string]any{"group": "apps", "version": "v1", "kind": "Deployment"},
+		},
+	}
+	data, err := json.Marshal(swagger)
+	require.NoError(t, err)
+	documents, err := openapiclient.ConvertOpenAPIV2(data)
+	require.NoError(t, err)
+	assert.Contains(t, documents, "api/v1")
+	assert.Contains(t, documents, "apis/apps/v1")
+
+	var apps struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(documents["apis/apps/v1"], &apps))
+	assert.Equal(t, "3.0.0", apps.OpenAPI)
+	// Only the kinds of the group version and what they refer to
+	assert.Contains(t, apps.Components.Schemas, "io.k8s.api.apps.v1.Deployment")
+	assert.Contains(t, apps.Components.Schemas, "io.k8s.api.core.v1.PodTemplateSpec")
+	assert.NotContains(t, apps.Components.Schemas, "io.k8s.api.core.v1.ConfigMap")
+	assert.JSONEq(t, `{"x-kubernetes-action": "post", "x-kubernetes-group-version-kind": {"group": "apps", "version": "v1", "kind": "Deployment"}}`,
+		string(apps.Paths["/apis/apps/v1/namespaces/{namespace}/deployments"]["post"]))
+	assert.NotContains(t, string(documents["apis/apps/v1"]), "#/definitions/")
+
+	_, err = openapiclient.ConvertOpenAPIV2([]byte(`{"openapi": "3.0.0"}`))
+	assert.ErrorContains(t, err, "expected an OpenAPI v2 document")
+}
+
+func TestConvertOpenAPIV2References(t *testing.T) {
+	documents, err := openapiclient.ConvertOpenAPIV2([]byte(`{
+  "swagger": "2.0",
+  "paths": {},
+  "definitions": {
+    "com.example.v1.Widget": {
+      "type": "object",
+      "description": "Parts are listed as in #/definitions/com.example.v1.Part, see \"$ref\": \"#/definitions/com.example.v1.Unused\"",
+      "x-kubernetes-group-version-kind": [{"group": "example.com", "version": "v1", "kind": "Widget"}],
+      "properties": {
+        "spec": {"allOf": [{"$ref": "#/definitions/com.example.v1.Spec"}], "description": "spec of the widget"},
+        "parts": {"type": "array", "items": {"$ref": "#/definitions/com.example.v1.Part"}},
+        "labels": {"type": "object", "additionalProperties": {"$ref": "#/definitions/com.example.v1.Label"}}
+      }
+    },
+    "com.example.v1.Spec": {"type": "object", "properties": {"size": {"type": "integer"}}},
+    "com.example.v1.Part": {"type": "object", "properties": {"name": {"type": "string"}}},
+    "com.example.v1.Label": {"type": "string"},
+    "com.example.v1.Unused": {"type": "string"}
+  }
+}`))
+	require.NoError(t, err)
+	require.Contains(t, documents, "apis/example.com/v1")
+
+	var document struct {
+		Components struct {
+			Schemas map[string]map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(documents["apis/example.com/v1"], &document))
+	schemas := document.Components.Schemas
+	// Definitions referred to through allOf, items and additionalProperties
+	// are kept, not those merely mentioned by descriptions
+	assert.ElementsMatch(t, []string{"com.example.v1.Widget", "com.example.v1.Spec", "com.example.v1.Part", "com.example.v1.Label"}, keys(schemas))
+
+	widget := schemas["com.example.v1.Widget"]
+	properties := widget["properties"].(map[string]any)
+	assert.Equal(t, "#/components/schemas/com.example.v1.Spec", properties["spec"].(map[string]any)["allOf"].([]any)[0].(map[string]any)["$ref"])
+	assert.Equal(t, "#/components/schemas/com.example.v1.Part", properties["parts"].(map[string]any)["items"].(map[string]any)["$ref"])
+	assert.Equal(t, "#/components/schemas/com.example.v1.Label", properties["labels"].(map[string]any)["additionalProperties"].(map[string]any)["$ref"])
+	// Descriptions are left as they are
+	assert.Equal(t, `Parts are listed as in #/definitions/com.example.v1.Part, see "$ref": "#/definitions/com.example.v1.Unused"`, widget["description"])
+}
+
+// Shows that documents converted from OpenAPI v2 validate as the OpenAPI v3
+// documents they come from
+func TestLocalOpenAPIV2File(t *testing.T) {
+	files := fstest.MapFS{"swagger.json": {Data: swaggerFromBuiltins(t, "1.23", "api/v1", "apis/apps/v1")}}
+	client := openapiclient.NewLocalOpenAPIV2File(files, "swagger.json")
+	paths, err := client.Paths()
+	require.NoError(t, err)
+	assert.Equal(t, openapiclient.SourceLocalSchemas, groupversion.SchemaSource(paths["api/v1"], ""))
+
+	converted, err := validator.New(client)
+	require.NoError(t, err)
+	builtin, err := validator.New(openapiclient.NewHardcodedBuiltins("1.23"))
+	require.NoError(t, err)
+
+	for _, manifest := range []string{
+		"configmap.yaml",
+		"deployment_with_strategy.yaml",
+		"error_invalid_protocol_type.yaml",
+		"error_missing_image.yaml",
+		"error_quantity.yaml",
+		"x_list_map_nonduplicate_key.yaml",
+	} {
+		t.Run(manifest, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("../../testcases/manifests", manifest))
+			require.NoError(t, err)
+			documents, err := utils.SplitYamlDocuments(data)
+			require.NoError(t, err)
+			for _, document := range documents {
+				if utils.IsEmptyYamlDocument(document) {
+					continue
+				}
+				assert.Equal(t, fmt.Sprint(validate(builtin, document)), fmt.Sprint(validate(converted, document)))
+			}
+		})
+	}
+}
+
+func validate(v *validator.Validator, document []byte) error {
+	_, obj, err := v.Parse(document)
+	if err != nil {
+		return err
+	}
+	return v.Validate(obj)
+}
@@ -1,6 +1,7 @@
 package openapiclient
 
 import (
+	"context"
 	"embed"
 	"io/fs"
 	"path"
@@ -49,7 +50,11 @@ func NewOverlay(patchLoader groupversion.PatchLoaderFn, delegate openapi.Client)
 }
 
 func (o overlayClient) Paths() (map[string]openapi.GroupVersion, error) {
-	delegateRes, err := o.delegate.Paths()
+	return o.PathsContext(context.Background())
+}
+
+func (o overlayClient) PathsContext(ctx context.Context) (map[string]openapi.GroupVersion, error) {
+	delegateRes, err := Paths(ctx, o.delegate)
 	if err != nil {
 		return nil, err
 	}
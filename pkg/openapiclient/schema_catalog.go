@@ -0,0 +1,151 @@
+package openapiclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/openapi"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"sigs.k8s.io/kubectl-validate/pkg/utils"
+)
+
+// DefaultSchemaCatalogTemplate is the layout of the schema catalogs published
+// for kubeconform, such as the CRDs catalog of datree
+const DefaultSchemaCatalogTemplate = "{{.Group}}/{{.ResourceKind}}_{{.ResourceAPIVersion}}.json"
+
+// Placeholders of schema catalog templates, with the patterns of the parts of
+// file paths they stand for. Versions are made of lower case letters and
+// digits, so the -strict variants of kubeconform catalogs are not matched.
+var schemaCatalogPlaceholders = map[string]string{
+	"Group":              `(?P<Group>[^/]*)`,
+	"ResourceKind":       `(?P<ResourceKind>[^/_]+)`,
+	"ResourceAPIVersion": `(?P<ResourceAPIVersion>[a-z0-9]+)`,
+}
+
+var schemaCatalogPlaceholder = regexp.MustCompile(`{{\s*\.(\w+)\s*}}`)
+
+// client which provides openapi converted from a catalog of JSON schemas
+type schemaCatalogClient struct {
+	fs       fs.FS
+	template string
+}
+
+// NewSchemaCatalog creates a client serving a catalog of JSON schemas, one
+// per file, laid out as kubeconform and kubeval expect. The path of each file
+// follows the template, where {{.Group}}, {{.ResourceKind}} and
+// {{.ResourceAPIVersion}} stand for the group, the lower case kind and the
+// version of the schema. An empty template defaults to
+// DefaultSchemaCatalogTemplate.
+//
+// Since file names do not tell the case of kinds, the kind of a schema is
+// read from its x-kubernetes-group-version-kind extension or the enum of its
+// kind property if it has any. Otherwise it is served for its lower case kind,
+// which the validator matches regardless of case. Schemas must not refer to
+// other files, and objects are validated as if cluster scoped.
+func NewSchemaCatalog(fs fs.FS, template string) openapi.Client {
+	if template == "" {
+		template = DefaultSchemaCatalogTemplate
+	}
+	return &schemaCatalogClient{fs: fs, template: template}
+}
+
+func (k *schemaCatalogClient) Paths() (map[string]openapi.GroupVersion, error) {
+	if k.fs == nil {
+		return nil, nil
+	}
+	pattern, glob, err := k.pattern()
+	if err != nil {
+		return nil, err
+	}
+	files, err := fs.Glob(k.fs, glob)
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s: %w", glob, err)
+	}
+
+	documents := map[schema.GroupVersion]*spec3.OpenAPI{}
+	for _, file := range files {
+		match := pattern.FindStringSubmatch(file)
+		if match == nil {
+			continue
+		}
+		gvk := schema.GroupVersionKind{
+			Version: match[pattern.SubexpIndex("ResourceAPIVersion")],
+			Kind:    strings.ToLower(match[pattern.SubexpIndex("ResourceKind")]),
+		}
+		// Templates without a group are catalogs of the core group
+		if i := pattern.SubexpIndex("Group"); i >= 0 {
+			gvk.Group = match[i]
+		}
+		data, err := fs.ReadFile(k.fs, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		sch := &spec.Schema{}
+		if err := json.Unmarshal(data, sch); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		gvk.Kind = catalogKind(sch, gvk)
+		addKindSchema(documents, gvk, sch)
+	}
+	return documentPaths(documents, SourceSchemaCatalog), nil
+}
+
+// pattern returns the regular expression matching the paths of the template,
+// and the glob listing them
+func (k *schemaCatalogClient) pattern() (*regexp.Regexp, string, error) {
+	var expr, glob strings.Builder
+	expr.WriteString("^")
+	seen := map[string]bool{}
+	rest := k.template
+	for {
+		loc := schemaCatalogPlaceholder.FindStringSubmatchIndex(rest)
+		if loc == nil {
+			break
+		}
+		name := rest[loc[2]:loc[3]]
+		placeholder, ok := schemaCatalogPlaceholders[name]
+		if !ok {
+			return nil, "", fmt.Errorf("unsupported placeholder %s in schema catalog template %s", rest[loc[0]:loc[1]], k.template)
+		}
+		if seen[name] {
+			return nil, "", fmt.Errorf("placeholder %s is used more than once in schema catalog template %s", name, k.template)
+		}
+		seen[name] = true
+		expr.WriteString(regexp.QuoteMeta(rest[:loc[0]]))
+		glob.WriteString(rest[:loc[0]])
+		expr.WriteString(placeholder)
+		glob.WriteString("*")
+		rest = rest[loc[1]:]
+	}
+	expr.WriteString(regexp.QuoteMeta(rest))
+	glob.WriteString(rest)
+	expr.WriteString("$")
+	for _, name := range []string{"ResourceKind", "ResourceAPIVersion"} {
+		if !seen[name] {
+			return nil, "", fmt.Errorf("schema catalog template %s must contain {{.%s}}", k.template, name)
+		}
+	}
+	pattern, err := regexp.Compile(expr.String())
+	return pattern, glob.String(), err
+}
+
+// catalogKind recovers the case of the kind of a catalog schema, which file
+// names are in lower case
+func catalogKind(sch *spec.Schema, gvk schema.GroupVersionKind) string {
+	for _, declared := range utils.ExtractExtensionGVKs(sch.Extensions) {
+		if declared.GroupVersion() == gvk.GroupVersion() && strings.EqualFold(declared.Kind, gvk.Kind) {
+			return declared.Kind
+		}
+	}
+	for _, value := range sch.Properties["kind"].Enum {
+		if kind, ok := value.(string); ok && strings.EqualFold(kind, gvk.Kind) {
+			return kind
+		}
+	}
+	return gvk.Kind
+}
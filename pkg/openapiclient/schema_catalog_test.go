@@ -0,0 +1,90 @@
+package openapiclient_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
+	"sigs.k8s.io/kubectl-validate/pkg/validator"
+)
+
+const celBasicJSONSchema = `{
+  "type": "object",
+  "properties": {
+    "apiVersion": {"type": "string"},
+    "kind": {"type": "string"},
+    "metadata": {"type": "object"},
+    "value": {
+      "type": "integer",
+      "x-kubernetes-validations": [{"message": "Must be positive non-zero", "rule": "self > 0"}]
+    }
+  }
+}`
+
+func TestSchemaCatalog(t *testing.T) {
+	catalog := fstest.MapFS{
+		"stable.example.com/celbasic_v1.json":        {Data: []byte(celBasicJSONSchema)},
+		"stable.example.com/celbasic_v1-strict.json": {Data: []byte(`not json`)},
+		"stable.example.com/widget_v1beta1.json": {Data: []byte(`{
+			"type": "object",
+			"properties": {"kind": {"type": "string", "enum": ["Widget"]}, "size": {"type": "integer"}}
+		}`)},
+		"README.md": {Data: []byte(`not a schema`)},
+	}
+	paths, err := openapiclient.NewSchemaCatalog(catalog, "").Paths()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"apis/stable.example.com/v1", "apis/stable.example.com/v1beta1"}, keys(paths))
+	assert.Equal(t, openapiclient.SourceSchemaCatalog, groupversion.SchemaSource(paths["apis/stable.example.com/v1"], ""))
+
+	v, err := validator.New(openapiclient.NewSchemaCatalog(catalog, ""))
+	require.NoError(t, err)
+
+	// Kinds served in lower case are matched regardless of case
+	gvk, obj, err := v.Parse([]byte(`{"apiVersion": "stable.example.com/v1", "kind": "CELBasic", "metadata": {"name": "test"}, "value": 1}`))
+	require.NoError(t, err)
+	assert.NoError(t, v.Validate(obj))
+	assert.Equal(t, openapiclient.SourceSchemaCatalog, v.SchemaSource(gvk))
+	obj.Object["value"] = int64(-1)
+	assert.ErrorContains(t, v.Validate(obj), "Must be positive non-zero")
+	_, _, err = v.Parse([]byte(`{"apiVersion": "stable.example.com/v1", "kind": "CELBasic", "metadata": {"name": "test"}, "values": 1}`))
+	assert.ErrorContains(t, err, "values: Invalid value: value provided for unknown field")
+
+	// The case of kinds is recovered from their schema
+	_, _, err = v.Parse([]byte(`{"apiVersion": "stable.example.com/v1beta1", "kind": "Widget", "metadata": {"name": "test"}, "size": 1}`))
+	assert.NoError(t, err)
+	_, _, err = v.Parse([]byte(`{"apiVersion": "stable.example.com/v1beta1", "kind": "widget", "metadata": {"name": "test"}, "size": 1}`))
+	var notFound *validator.SchemaNotFoundError
+	assert.ErrorAs(t, err, &notFound)
+	assert.Equal(t, schema.GroupVersionKind{Group: "stable.example.com", Version: "v1beta1", Kind: "widget"}, notFound.GroupVersionKind)
+}
+
+func TestSchemaCatalogTemplate(t *testing.T) {
+	catalog := fstest.MapFS{
+		"v1.29.0-standalone/configmap-v1.json":       {Data: []byte(`{"type": "object", "properties": {"data": {"type": "object", "additionalProperties": {"type": "string"}}}}`)},
+		"v1.29.0-standalone/stable.example.com.json": {Data: []byte(`{}`)},
+	}
+	paths, err := openapiclient.NewSchemaCatalog(catalog, "v1.29.0-standalone/{{ .ResourceKind }}-{{ .ResourceAPIVersion }}.json").Paths()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api/v1"}, keys(paths))
+
+	for template, wantErr := range map[string]string{
+		"{{.Group}}/{{.ResourceKind}}.json":                              "must contain {{.ResourceAPIVersion}}",
+		"{{.Group}}/{{.ResourceKind}}_{{.ResourceAPIVersion}}{{.Group}}": "placeholder Group is used more than once",
+		"{{.NormalizedKubernetesVersion}}/{{.ResourceKind}}.json":        "unsupported placeholder {{.NormalizedKubernetesVersion}}",
+	} {
+		_, err := openapiclient.NewSchemaCatalog(catalog, template).Paths()
+		assert.ErrorContains(t, err, wantErr, template)
+	}
+}
+
+func keys[V any](m map[string]V) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	return res
+}
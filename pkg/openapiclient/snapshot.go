@@ -0,0 +1,175 @@
+package openapiclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apimachineryversion "k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/openapi"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
+	"sigs.k8s.io/kubectl-validate/pkg/utils"
+)
+
+const (
+	// Name of the manifest of a snapshot, next to its documents
+	SnapshotManifestFile = "snapshot.json"
+	// API version and kind of the manifests of snapshots
+	SnapshotAPIVersion = "kubectl-validate.sigs.k8s.io/v1alpha1"
+	SnapshotKind       = "SchemaSnapshot"
+)
+
+// VersionedClient is implemented by clients which know the version of the
+// server they get schemas from, such as NewKubeConfig
+type VersionedClient interface {
+	openapi.Client
+	ServerVersionContext(ctx context.Context) (*apimachineryversion.Info, error)
+}
+
+// ServerVersion returns the version of the server client gets schemas from,
+// or nil if client does not implement VersionedClient
+func ServerVersion(ctx context.Context, client openapi.Client) (*apimachineryversion.Info, error) {
+	if c, ok := client.(VersionedClient); ok {
+		return c.ServerVersionContext(ctx)
+	}
+	return nil, nil
+}
+
+// SnapshotManifest records where the documents of a snapshot come from
+type SnapshotManifest struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// Time the snapshot was taken at
+	Created metav1.Time `json:"created"`
+	// Version of the server the documents come from, if known
+	ServerVersion *apimachineryversion.Info `json:"serverVersion,omitempty"`
+	// Documents of the snapshot, by the path of their group version
+	GroupVersions map[string]SnapshotGroupVersion `json:"groupVersions"`
+}
+
+// SnapshotGroupVersion is a document of a snapshot
+type SnapshotGroupVersion struct {
+	// Path of the document, relative to the manifest
+	File string `json:"file"`
+	// Hex encoded SHA-256 hash of the document
+	SHA256 string `json:"sha256"`
+}
+
+// WriteSnapshot writes the OpenAPI documents of the group versions client
+// serves to dir, in the layout NewLocalSchemaFiles reads, along with a
+// manifest in SnapshotManifestFile. Paths of client which are not group
+// versions, such as the apis path listing groups, hold no kinds and are left
+// out. The documents of any previous snapshot in dir are replaced once every
+// document has been fetched, and are left untouched if fetching one fails.
+func WriteSnapshot(ctx context.Context, client openapi.Client, dir string) (*SnapshotManifest, error) {
+	paths, err := Paths(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	serverVersion, err := ServerVersion(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	gvPaths := make([]string, 0, len(paths))
+	for gvPath := range paths {
+		if _, err := utils.ParseGroupVersionPath(gvPath); err == nil {
+			gvPaths = append(gvPaths, gvPath)
+		}
+	}
+	sort.Strings(gvPaths)
+
+	manifest := &SnapshotManifest{
+		APIVersion:    SnapshotAPIVersion,
+		Kind:          SnapshotKind,
+		Created:       metav1.NewTime(time.Now().UTC().Truncate(time.Second)),
+		ServerVersion: serverVersion,
+		GroupVersions: map[string]SnapshotGroupVersion{},
+	}
+
+	// Documents are written to a temporary directory next to dir, so they
+	// can be renamed into place
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(filepath.Clean(dir)), "."+filepath.Base(filepath.Clean(dir))+"-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging) //nolint:errcheck
+	for _, gvPath := range gvPaths {
+		document, err := groupversion.Schema(ctx, paths[gvPath], "application/json")
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", gvPath, err)
+		}
+		file := gvPath + ".json"
+		path := filepath.Join(staging, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, document, 0o644); err != nil {
+			return nil, err
+		}
+		hash := sha256.Sum256(document)
+		manifest.GroupVersions[gvPath] = SnapshotGroupVersion{File: file, SHA256: hex.EncodeToString(hash[:])}
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(staging, SnapshotManifestFile), append(data, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+
+	if err := removeSnapshot(dir); err != nil {
+		return nil, err
+	}
+	for _, gvPath := range gvPaths {
+		file := filepath.FromSlash(manifest.GroupVersions[gvPath].File)
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(filepath.Join(staging, file), filepath.Join(dir, file)); err != nil {
+			return nil, err
+		}
+	}
+	// The manifest goes last, a snapshot being complete once it is in place
+	if err := os.Rename(filepath.Join(staging, SnapshotManifestFile), filepath.Join(dir, SnapshotManifestFile)); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// removeSnapshot removes the documents of the snapshot in dir, if any, so
+// group versions no longer served are not left behind
+func removeSnapshot(dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, SnapshotManifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var previous SnapshotManifest
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return fmt.Errorf("failed to parse the manifest of the previous snapshot: %w", err)
+	}
+	for _, gv := range previous.GroupVersions {
+		file := filepath.FromSlash(gv.File)
+		if !filepath.IsLocal(file) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, file)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
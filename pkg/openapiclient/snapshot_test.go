@@ -0,0 +1,94 @@
+package openapiclient_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/openapi"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
+)
+
+// Shows that snapshots are served by NewLocalSchemaFiles as the client they
+// are taken from
+func TestWriteSnapshot(t *testing.T) {
+	client := openapiclient.NewComposite(
+		openapiclient.NewHardcodedBuiltins("1.30"),
+		openapiclient.NewLocalCRDFiles(os.DirFS("../../testcases/crds")),
+	)
+	dir := t.TempDir()
+	// Documents of previous snapshots are replaced
+	_, err := openapiclient.WriteSnapshot(context.Background(), stubClient{"apis/stale.example.com/v1": groupversion.NewForDocument([]byte(`{}`))}, dir)
+	require.NoError(t, err)
+
+	manifest, err := openapiclient.WriteSnapshot(context.Background(), client, dir)
+	require.NoError(t, err)
+	assert.Equal(t, openapiclient.SnapshotKind, manifest.Kind)
+	assert.Nil(t, manifest.ServerVersion)
+	assert.NoFileExists(t, filepath.Join(dir, "apis/stale.example.com/v1.json"))
+
+	want, err := client.Paths()
+	require.NoError(t, err)
+	got, err := openapiclient.NewLocalSchemaFiles(os.DirFS(dir)).Paths()
+	require.NoError(t, err)
+	require.ElementsMatch(t, keys(want), keys(got))
+	require.Equal(t, len(want), len(manifest.GroupVersions))
+	for gvPath, gv := range want {
+		wantDocument, err := gv.Schema("application/json")
+		require.NoError(t, err)
+		gotDocument, err := got[gvPath].Schema("application/json")
+		require.NoError(t, err)
+		assert.Equal(t, wantDocument, gotDocument, gvPath)
+
+		hash := sha256.Sum256(gotDocument)
+		assert.Equal(t, hex.EncodeToString(hash[:]), manifest.GroupVersions[gvPath].SHA256, gvPath)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, openapiclient.SnapshotManifestFile))
+	require.NoError(t, err)
+	var written openapiclient.SnapshotManifest
+	require.NoError(t, json.Unmarshal(data, &written))
+	assert.Equal(t, manifest.GroupVersions, written.GroupVersions)
+
+	// A snapshot failing to fetch a document leaves the previous one intact
+	_, err = openapiclient.WriteSnapshot(context.Background(), stubClient{
+		"api/v1":                     groupversion.NewForDocument([]byte(`{}`)),
+		"apis/broken.example.com/v1": failingGroupVersion{},
+	}, dir)
+	require.Error(t, err)
+	after, err := os.ReadFile(filepath.Join(dir, openapiclient.SnapshotManifestFile))
+	require.NoError(t, err)
+	assert.Equal(t, data, after)
+	got, err = openapiclient.NewLocalSchemaFiles(os.DirFS(dir)).Paths()
+	require.NoError(t, err)
+	require.ElementsMatch(t, keys(want), keys(got))
+	entries, err := os.ReadDir(filepath.Dir(dir))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary directories are removed")
+}
+
+// failingGroupVersion fails to serve its document
+type failingGroupVersion struct{}
+
+func (failingGroupVersion) Schema(string) ([]byte, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (failingGroupVersion) ServerRelativeURL() string {
+	return ""
+}
+
+// stubClient serves the given group versions
+type stubClient map[string]openapi.GroupVersion
+
+func (c stubClient) Paths() (map[string]openapi.GroupVersion, error) {
+	return c, nil
+}
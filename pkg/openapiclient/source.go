@@ -0,0 +1,22 @@
+package openapiclient
+
+// Sources the schemas served by the clients of this package are labelled
+// with. See groupversion.SchemaSource.
+const (
+	// Schemas of native types built into the binary
+	SourceBuiltin = "builtin"
+	// Schemas of native types downloaded from the Kubernetes repository
+	SourceGitHub = "github"
+	// Schemas served by the cluster of the current kubeconfig context
+	SourceCluster = "cluster"
+	// Schemas read from a directory of OpenAPI documents
+	SourceLocalSchemas = "local-schemas"
+	// Schemas of CRDs read from a directory of manifests
+	SourceLocalCRDs = "local-crds"
+	// Schemas read from a catalog of JSON schemas, passed to NewSchemaCatalog
+	SourceSchemaCatalog = "schema-catalog"
+	// Schemas of CRD objects passed to NewCRDs
+	SourceCRDs = "crds"
+	// Schemas generated by openapi-gen, passed to NewOpenAPIDefinitions
+	SourceOpenAPIDefinitions = "openapi-definitions"
+)
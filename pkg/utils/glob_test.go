@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		file    string
+		want    bool
+		wantErr bool
+	}{{
+		name:    "exact",
+		pattern: "clusters/edge/app.yaml",
+		file:    "clusters/edge/app.yaml",
+		want:    true,
+	}, {
+		name:    "star within a segment",
+		pattern: "clusters/*/app.yaml",
+		file:    "clusters/edge/app.yaml",
+		want:    true,
+	}, {
+		name:    "star does not cross segments",
+		pattern: "clusters/*.yaml",
+		file:    "clusters/edge/app.yaml",
+		want:    false,
+	}, {
+		name:    "double star",
+		pattern: "clusters/prod-eu/**",
+		file:    "clusters/prod-eu/apps/web/deployment.yaml",
+		want:    true,
+	}, {
+		name:    "double star matches no segment",
+		pattern: "clusters/**/app.yaml",
+		file:    "clusters/app.yaml",
+		want:    true,
+	}, {
+		name:    "double star in the middle",
+		pattern: "**/crds/*.yaml",
+		file:    "clusters/edge/crds/widget.yaml",
+		want:    true,
+	}, {
+		name:    "other directory",
+		pattern: "clusters/prod-eu/**",
+		file:    "clusters/edge/app.yaml",
+		want:    false,
+	}, {
+		name:    "malformed",
+		pattern: "clusters/[",
+		file:    "clusters/edge",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MatchGlob(tt.pattern, tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MatchGlob() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MatchGlob() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// IgnoreFile is the name of the files listing the paths FindFilesFiltered
+// leaves out of the directory they are in, with the syntax of .gitignore
+const IgnoreFile = ".kubectl-validate-ignore"
+
+// FileFilter decides which of the files found in directories are kept
+type FileFilter struct {
+	// Globs of the paths of the files to keep, relative to the directory
+	// searched, such as "clusters/**". All files are kept if empty.
+	Include []string
+	// Globs of the paths of the files or directories to leave out, such as
+	// "**/values.yaml"
+	Exclude []string
+}
+
+// Validate returns an error if a glob of the filter is malformed
+func (f FileFilter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := MatchGlob(pattern, ""); err != nil {
+			return errors.New("invalid glob " + pattern + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// FindFilesFiltered is like FindFiles, leaving out the files found in
+// directories which the filter or the IgnoreFile of their directory or of its
+// parents up to the one searched exclude. Files passed as arguments are kept.
+func FindFilesFiltered(filter FileFilter, args ...string) ([]string, error) {
+	var files []string
+	for _, fileOrDir := range args {
+		info, err := os.Stat(fileOrDir)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, fileOrDir)
+			continue
+		}
+		sub, err := findFilteredFilesInDir(filter, fileOrDir, "", nil)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, sub...)
+	}
+	return files, nil
+}
+
+// findFilteredFilesInDir returns the files kept in the directory at rel
+// within root, given the ignore files of its parents
+func findFilteredFilesInDir(filter FileFilter, root, rel string, ignores []ignoreFile) ([]string, error) {
+	dir := path.Join(root, rel)
+	ignore, err := readIgnoreFile(path.Join(dir, IgnoreFile), rel)
+	if err != nil {
+		return nil, err
+	} else if ignore != nil {
+		ignores = append(ignores, *ignore)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		entryRel := path.Join(rel, entry.Name())
+		if isIgnored(ignores, entryRel, entry.IsDir()) || matchesAny(filter.Exclude, entryRel) {
+			continue
+		}
+		if entry.IsDir() {
+			sub, err := findFilteredFilesInDir(filter, root, entryRel, ignores)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, sub...)
+		} else if IsYamlOrJson(entry.Name()) && (len(filter.Include) == 0 || matchesAny(filter.Include, entryRel)) {
+			files = append(files, path.Join(root, entryRel))
+		}
+	}
+	return files, nil
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		// Patterns are checked by FileFilter.Validate
+		if ok, _ := MatchGlob(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreFile is the parsed IgnoreFile of the directory at dir
+type ignoreFile struct {
+	dir   string
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	pattern string
+	// Whether the rule includes again what earlier rules ignore
+	negate bool
+	// Whether the rule only matches directories
+	dirOnly bool
+}
+
+// readIgnoreFile parses the ignore file at file of the directory at dir, nil
+// if there is none
+func readIgnoreFile(file, dir string) (*ignoreFile, error) {
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	res := &ignoreFile{dir: dir}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate, line = true, line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			// Escapes a leading # or !
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly, line = true, strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			// Relative to the directory of the file
+			line = strings.TrimPrefix(line, "/")
+		} else {
+			line = "**/" + line
+		}
+		if _, err := MatchGlob(line, ""); err != nil {
+			return nil, errors.New("invalid pattern in " + file + ": " + err.Error())
+		}
+		rule.pattern = line
+		res.rules = append(res.rules, rule)
+	}
+	return res, scanner.Err()
+}
+
+// isIgnored returns true if the last rule of the ignore files matching the
+// path at rel ignores it
+func isIgnored(ignores []ignoreFile, rel string, isDir bool) bool {
+	res := false
+	for _, ignore := range ignores {
+		name := rel
+		if ignore.dir != "" {
+			name = strings.TrimPrefix(rel, ignore.dir+"/")
+		}
+		for _, rule := range ignore.rules {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if ok, _ := MatchGlob(rule.pattern, name); ok {
+				res = !rule.negate
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindFilesFiltered(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{
+		"app/deployment.yaml",
+		"app/kustomization.yaml",
+		"app/tests/fixture.yaml",
+		"chart/values.yaml",
+		"chart/templates/service.yaml",
+		"clusters/edge/app.yaml",
+		"clusters/prod/app.yaml",
+		"clusters/prod/keep.yaml",
+		".github/workflows/ci.yml",
+		"notes.txt",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeIgnore := func(rel, content string) {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(rel), IgnoreFile), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Patterns without a slash match at any depth, others are relative to
+	// the directory of the ignore file
+	writeIgnore(".", "# workflows\n.github/\nkustomization.yaml\ntests/\n")
+	writeIgnore("clusters", "prod/*\n!prod/keep.yaml\n")
+
+	tests := []struct {
+		name   string
+		filter FileFilter
+		want   []string
+	}{{
+		name: "ignore files",
+		want: []string{
+			"app/deployment.yaml",
+			"chart/templates/service.yaml",
+			"chart/values.yaml",
+			"clusters/edge/app.yaml",
+			"clusters/prod/keep.yaml",
+		},
+	}, {
+		name:   "exclude",
+		filter: FileFilter{Exclude: []string{"**/values.yaml", "clusters"}},
+		want: []string{
+			"app/deployment.yaml",
+			"chart/templates/service.yaml",
+		},
+	}, {
+		name:   "include",
+		filter: FileFilter{Include: []string{"clusters/**", "app/*.yaml"}},
+		want: []string{
+			"app/deployment.yaml",
+			"clusters/edge/app.yaml",
+			"clusters/prod/keep.yaml",
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := FindFilesFiltered(tt.filter, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range got {
+				got[i] = strings.TrimPrefix(got[i], filepath.ToSlash(dir)+"/")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindFilesFiltered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Files passed as arguments are kept
+	file := filepath.Join(dir, "app", "kustomization.yaml")
+	got, err := FindFilesFiltered(FileFilter{Exclude: []string{"**"}}, file)
+	if err != nil || !reflect.DeepEqual(got, []string{file}) {
+		t.Errorf("FindFilesFiltered() = %v, %v, want %v", got, err, []string{file})
+	}
+	if err := (FileFilter{Include: []string{"["}}).Validate(); err == nil {
+		t.Error("Validate() of a malformed glob succeeded")
+	}
+}
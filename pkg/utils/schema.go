@@ -1,6 +1,9 @@
 package utils
 
 import (
+	"fmt"
+	"strings"
+
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/kube-openapi/pkg/spec3"
 )
@@ -58,6 +61,20 @@ func ExtractExtensionGVKs(extensions map[string]interface{}) []schema.GroupVersi
 	return result
 }
 
+// KindExtensions are the extensions of definitions and operations which tell
+// about the kinds they are for, read without parsing the rest of them
+type KindExtensions struct {
+	GroupVersionKind interface{} `json:"x-kubernetes-group-version-kind"`
+	// Scope of the kind, set on the schemas of CRDs read from files whose
+	// documents have no paths telling it
+	Scope *string `json:"x-kubectl-validate-scope"`
+}
+
+// GVKs returns the kinds of the x-kubernetes-group-version-kind extension
+func (e *KindExtensions) GVKs() []schema.GroupVersionKind {
+	return ExtractExtensionGVKs(map[string]interface{}{"x-kubernetes-group-version-kind": e.GroupVersionKind})
+}
+
 func ExtractPathGVKs(path *spec3.Path) []schema.GroupVersionKind {
 	var result []schema.GroupVersionKind
 	if path.Get != nil {
@@ -74,3 +91,22 @@ func ExtractPathGVKs(path *spec3.Path) []schema.GroupVersionKind {
 	}
 	return result
 }
+
+// GroupVersionPath returns the path of the groupversion's OpenAPI document
+// relative to /openapi/v3. i.e. "api/v1" or "apis/apps/v1"
+func GroupVersionPath(gv schema.GroupVersion) string {
+	if len(gv.Group) == 0 {
+		return "api/" + gv.Version
+	}
+	return "apis/" + gv.Group + "/" + gv.Version
+}
+
+// ParseGroupVersionPath is the inverse of GroupVersionPath
+func ParseGroupVersionPath(gvPath string) (schema.GroupVersion, error) {
+	if rest, ok := strings.CutPrefix(gvPath, "apis/"); ok {
+		return schema.ParseGroupVersion(rest)
+	} else if rest, ok := strings.CutPrefix(gvPath, "api/"); ok && !strings.Contains(rest, "/") {
+		return schema.GroupVersion{Version: rest}, nil
+	}
+	return schema.GroupVersion{}, fmt.Errorf("unknown path %s", gvPath)
+}
@@ -13,19 +13,41 @@ type Document = []byte
 
 func SplitYamlDocuments(fileBytes Document) ([]Document, error) {
 	var documents [][]byte
-	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewBuffer(fileBytes)))
+	reader := NewYamlDocumentReader(bytes.NewBuffer(fileBytes))
 	for {
 		document, err := reader.Read()
-		if err == io.EOF || len(document) == 0 {
+		if err == io.EOF {
 			break
 		} else if err != nil {
 			return nil, err
 		}
-		documents = append(documents, []byte(document))
+		documents = append(documents, document)
 	}
 	return documents, nil
 }
 
+// YamlDocumentReader reads the documents of a YAML stream one at a time, so
+// only the current document is held in memory
+type YamlDocumentReader struct {
+	reader *utilyaml.YAMLReader
+}
+
+func NewYamlDocumentReader(r io.Reader) *YamlDocumentReader {
+	return &YamlDocumentReader{reader: utilyaml.NewYAMLReader(bufio.NewReader(r))}
+}
+
+// Read returns the next document of the stream, or io.EOF once there are
+// none left
+func (r *YamlDocumentReader) Read() (Document, error) {
+	document, err := r.reader.Read()
+	if err == io.EOF || len(document) == 0 {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
 // IsEmptyYamlDocument checks if a yaml document is empty (contains only comments)
 //
 // Returns true for comment-only single documents, and strings with multiple documents
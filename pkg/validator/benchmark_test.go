@@ -0,0 +1,201 @@
+package validator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+	"sigs.k8s.io/kubectl-validate/pkg/utils"
+)
+
+var benchmarkDocuments = map[string]string{
+	"api/v1": `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  key: value
+`,
+	"apps/v1": `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: nginx
+        ports:
+        - containerPort: 80
+`,
+}
+
+// BenchmarkFirstObject measures the latency of validating the first object of
+// a group version, which includes loading its schemas
+func BenchmarkFirstObject(b *testing.B) {
+	for _, gv := range []string{"api/v1", "apps/v1"} {
+		document := []byte(benchmarkDocuments[gv])
+		b.Run(gv, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"))
+				if err != nil {
+					b.Fatal(err)
+				}
+				_, parsed, err := validator.Parse(document)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := validator.Validate(parsed); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkFirstObjectCached measures the same with the schemas loaded from a
+// warm schema cache
+func BenchmarkFirstObjectCached(b *testing.B) {
+	dir := b.TempDir()
+	for _, gv := range []string{"api/v1", "apps/v1"} {
+		document := []byte(benchmarkDocuments[gv])
+		b.Run(gv, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"), WithSchemaCache(dir))
+				if err != nil {
+					b.Fatal(err)
+				}
+				_, parsed, err := validator.Parse(document)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := validator.Validate(parsed); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkObject measures the cost of validating each further object of a
+// kind, once its schemas are loaded
+func BenchmarkObject(b *testing.B) {
+	for _, gv := range []string{"api/v1", "apps/v1"} {
+		document := []byte(benchmarkDocuments[gv])
+		b.Run(gv, func(b *testing.B) {
+			validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, _, err := validator.Parse(document); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, parsed, err := validator.Parse(document)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := validator.Validate(parsed); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkObjectParallel measures the same with objects validated
+// concurrently by a shared validator
+func BenchmarkObjectParallel(b *testing.B) {
+	for _, gv := range []string{"api/v1", "apps/v1"} {
+		document := []byte(benchmarkDocuments[gv])
+		b.Run(gv, func(b *testing.B) {
+			validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, _, err := validator.Parse(document); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_, parsed, err := validator.Parse(document)
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					if err := validator.Validate(parsed); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkCustomResourceDefinitions measures the cost of validating objects
+// of a recursive kind, CustomResourceDefinitions of various shapes, once its
+// schemas are loaded
+func BenchmarkCustomResourceDefinitions(b *testing.B) {
+	files, err := filepath.Glob("../../testcases/crds/*.yaml")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var documents []utils.Document
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			b.Fatal(err)
+		}
+		split, err := utils.SplitYamlDocuments(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, document := range split {
+			if bytes.Contains(document, []byte("kind: CustomResourceDefinition")) {
+				documents = append(documents, document)
+			}
+		}
+	}
+	if len(documents) == 0 {
+		b.Fatal("no CustomResourceDefinitions found")
+	}
+
+	validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, _, err := validator.Parse(documents[0]); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, document := range documents {
+			_, parsed, err := validator.Parse(document)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := validator.Validate(parsed); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
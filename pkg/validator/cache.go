@@ -0,0 +1,243 @@
+package validator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+func init() {
+	// Types of the values of defaults, enums and extensions
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// schemaCache persists the schemas of kinds, resolved and converted to
+// structural schemas, so later runs skip straight to validating.
+//
+// Entries are keyed by the hash of the OpenAPI document of the group version
+// they come from, which changes with the Kubernetes version and any patches
+// applied by the schema sources. Entries are stored per cacheKey, the version
+// of the code deriving them from documents and of the schema patches applied.
+// A changed document misses the cache and is recomputed.
+//
+// Entries not used for cacheMaxAge are pruned, such as those of documents
+// which changed or of other versions of the code.
+type schemaCache struct {
+	dir string
+}
+
+const (
+	cacheMaxAge = 30 * 24 * time.Hour
+	// Pruning walks the whole cache, so it is done at most this often. Used
+	// entries are marked as such at most this often too.
+	cachePruneInterval = 24 * time.Hour
+)
+
+// cachedKind is what is cached of a kind
+type cachedKind struct {
+	Name       string
+	Namespaced bool
+	Schema     *spec.Schema
+	Structural *structuralschema.Structural
+}
+
+func newSchemaCache(dir string, k8sVersion int) *schemaCache {
+	root := filepath.Join(dir, "schemas")
+	pruneSchemaCache(root, time.Now())
+	return &schemaCache{dir: filepath.Join(root, cacheKey(k8sVersion))}
+}
+
+// cacheFormatVersion stands for the version of the code deriving cached
+// entries from documents, such as the schema patches and the resolution of
+// references, when the binary does not tell it. Bump it whenever that code
+// changes what it derives.
+const cacheFormatVersion = 2
+
+// cacheKey identifies how cached entries are derived from documents: by the
+// version of this module, the schema patches applied to the given minor
+// version of Kubernetes and the versions of the modules the code depends on
+func cacheKey(k8sVersion int) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "patches:%s\n", schemaPatchesHash(k8sVersion))
+	info, _ := debug.ReadBuildInfo()
+	fmt.Fprintf(h, "module:%s\n", moduleVersion(info))
+	if info != nil {
+		for _, dep := range info.Deps {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			fmt.Fprintf(h, "%s:%s\n", dep.Path, dep.Version)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// modulePath is the path of this module, which programs building it as a
+// dependency list among theirs
+const modulePath = "sigs.k8s.io/kubectl-validate"
+
+// moduleVersion returns the version of this module the binary is built with:
+// its module version, or the VCS revision of binaries built from a checkout
+// of it, or cacheFormatVersion when neither is known such as in tests. info
+// is nil for binaries without build information.
+func moduleVersion(info *debug.BuildInfo) string {
+	if info == nil {
+		return fmt.Sprintf("format:%d", cacheFormatVersion)
+	}
+	module := &info.Main
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			module = dep
+		}
+	}
+	// Modules replaced by a directory, such as by the native module, are
+	// built from the same checkout
+	fromCheckout := module == &info.Main
+	if module.Replace != nil {
+		module = module.Replace
+		fromCheckout = module.Version == ""
+	}
+	if module.Version != "" && module.Version != "(devel)" {
+		return module.Version
+	}
+	settings := map[string]string{}
+	for _, setting := range info.Settings {
+		settings[setting.Key] = setting.Value
+	}
+	if revision := settings["vcs.revision"]; fromCheckout && revision != "" {
+		if settings["vcs.modified"] != "true" {
+			return revision
+		}
+		// Uncommitted changes are only told apart by cacheFormatVersion
+		return fmt.Sprintf("%s+format:%d", revision, cacheFormatVersion)
+	}
+	return fmt.Sprintf("format:%d", cacheFormatVersion)
+}
+
+// pruneSchemaCache removes the files under root not used within cacheMaxAge,
+// along with the directories left empty. Nothing is done if root was pruned
+// within cachePruneInterval. Failures are ignored, the cache being pruned
+// again next time.
+func pruneSchemaCache(root string, now time.Time) {
+	marker := filepath.Join(root, ".pruned")
+	if info, err := os.Stat(marker); err == nil && now.Sub(info.ModTime()) < cachePruneInterval {
+		return
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return
+	}
+	if err := os.WriteFile(marker, nil, 0o644); err != nil {
+		return
+	}
+	_ = os.Chtimes(marker, now, now)
+
+	var dirs []string
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root || path == marker {
+			return nil
+		}
+		if d.IsDir() {
+			dirs = append(dirs, path)
+		} else if info, err := d.Info(); err == nil && now.Sub(info.ModTime()) > cacheMaxAge {
+			_ = os.Remove(path)
+		}
+		return nil
+	})
+	// Directories are walked before their contents, and only removed once
+	// empty
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i])
+	}
+}
+
+// documentHash returns the key of the entries derived from the document
+func documentHash(document []byte) string {
+	sum := sha256.Sum256(document)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *schemaCache) path(documentHash string, gvk schema.GroupVersionKind) string {
+	group := gvk.Group
+	if len(group) == 0 {
+		group = "core"
+	}
+	return filepath.Join(c.dir, documentHash[:2], documentHash[2:], fmt.Sprintf("%s_%s_%s.gob", group, gvk.Version, gvk.Kind))
+}
+
+// load returns the cached entry of the kind. A missing or unreadable entry
+// is a miss.
+func (c *schemaCache) load(documentHash string, gvk schema.GroupVersionKind) (*validatorEntry, bool) {
+	path := c.path(documentHash, gvk)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	if now := time.Now(); now.Sub(info.ModTime()) > cachePruneInterval {
+		// Keep the entry from being pruned
+		_ = os.Chtimes(path, now, now)
+	}
+	var cached cachedKind
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&cached); err != nil || cached.Schema == nil || cached.Structural == nil {
+		return nil, false
+	}
+	res := newValidatorEntry(cached.Name, cached.Namespaced, cached.Schema, nil)
+	res.ss = cached.Structural
+	return res, true
+}
+
+// store caches the entry of the kind. Entries of recursive kinds depend on
+// the object being validated and are not cached.
+func (c *schemaCache) store(documentHash string, gvk schema.GroupVersionKind, entry *validatorEntry) error {
+	if entry.definitions != nil {
+		return nil
+	}
+	ss, err := entry.StructuralSchema()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(cachedKind{
+		Name:       entry.name,
+		Namespaced: entry.namespaceScoped,
+		Schema:     entry.Schema,
+		Structural: ss,
+	}); err != nil {
+		return err
+	}
+
+	path := c.path(documentHash, gvk)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so concurrent runs never read a
+	// partially written entry
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close() //nolint:errcheck
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,218 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/openapi"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+)
+
+func validateDocument(t *testing.T, validator *Validator, document string) error {
+	t.Helper()
+	_, parsed, err := validator.Parse([]byte(document))
+	if err != nil {
+		return err
+	}
+	return validator.Validate(parsed)
+}
+
+// errorCauses returns the causes of a validation error, which are not
+// reported in a stable order
+func errorCauses(err error) []string {
+	var statusErr *k8serrors.StatusError
+	if !errors.As(err, &statusErr) {
+		if err == nil {
+			return nil
+		}
+		return []string{err.Error()}
+	}
+	var res []string
+	for _, cause := range statusErr.Status().Details.Causes {
+		res = append(res, fmt.Sprintf("%s: %s", cause.Field, cause.Message))
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestSchemaCache(t *testing.T) {
+	documents := []string{
+		benchmarkDocuments["apps/v1"],
+		`{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "web"}, "spec": {"replicas": "three", "selector": {}, "template": {}}}`,
+		`{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "web"}, "spec": {"replica": 3}}`,
+	}
+	dir := t.TempDir()
+
+	uncached, err := New(openapiclient.NewHardcodedBuiltins("1.30"))
+	require.NoError(t, err)
+	var want [][]string
+	for _, document := range documents {
+		want = append(want, errorCauses(validateDocument(t, uncached, document)))
+	}
+
+	for _, run := range []string{"cold", "warm"} {
+		t.Run(run, func(t *testing.T) {
+			validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"), WithSchemaCache(dir))
+			require.NoError(t, err)
+			for i, document := range documents {
+				assert.Equal(t, want[i], errorCauses(validateDocument(t, validator, document)))
+			}
+			// A warm cache needs no parsing of documents
+			parsed := validator.groupVersions["apis/apps/v1"].definitions != nil
+			assert.Equal(t, run == "cold", parsed)
+		})
+	}
+}
+
+func TestSchemaCacheStale(t *testing.T) {
+	schemas := func(replicasType string) openapi.Client {
+		return openapiclient.NewLocalSchemaFiles(fstest.MapFS{"apis/example.com/v1.json": {Data: []byte(`{
+  "openapi": "3.0.0",
+  "components": {"schemas": {
+    "com.example.v1.Widget": {
+      "type": "object",
+      "x-kubernetes-group-version-kind": [{"group": "example.com", "version": "v1", "kind": "Widget"}],
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "metadata": {"type": "object", "x-kubernetes-preserve-unknown-fields": true},
+        "replicas": {"type": "` + replicasType + `"}
+      }
+    }
+  }}
+}`)}})
+	}
+	document := `{"apiVersion": "example.com/v1", "kind": "Widget", "metadata": {"name": "widget"}, "replicas": "three"}`
+	dir := t.TempDir()
+
+	validator, err := New(schemas("integer"), WithSchemaCache(dir))
+	require.NoError(t, err)
+	require.Error(t, validateDocument(t, validator, document))
+
+	// A changed document does not use the schemas cached for the old one
+	validator, err = New(schemas("string"), WithSchemaCache(dir))
+	require.NoError(t, err)
+	require.NoError(t, validateDocument(t, validator, document))
+
+	// Unreadable entries are recomputed
+	entries, err := filepath.Glob(filepath.Join(dir, "schemas", "*", "*", "*", "*.gob"))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	for _, entry := range entries {
+		require.NoError(t, os.WriteFile(entry, []byte("corrupt"), 0o644))
+	}
+	validator, err = New(schemas("integer"), WithSchemaCache(dir))
+	require.NoError(t, err)
+	require.Error(t, validateDocument(t, validator, document))
+	assert.NotNil(t, validator.groupVersions["apis/example.com/v1"].definitions)
+}
+
+func TestCacheKey(t *testing.T) {
+	assert.Equal(t, cacheKey(30), cacheKey(30))
+	assert.NotEqual(t, cacheKey(30), cacheKey(31))
+
+	// Patches only change the key of the versions they apply to
+	before30, before31 := cacheKey(30), cacheKey(31)
+	original := schemaPatches
+	t.Cleanup(func() { schemaPatches = original })
+	schemaPatches = append(slices.Clone(original), SchemaPatch{Slug: "Test", MinMinorVersion: 31})
+	assert.Equal(t, before30, cacheKey(30))
+	assert.NotEqual(t, before31, cacheKey(31))
+}
+
+func TestModuleVersion(t *testing.T) {
+	fallback := fmt.Sprintf("format:%d", cacheFormatVersion)
+	checkout := []debug.BuildSetting{{Key: "vcs.revision", Value: "abc123"}, {Key: "vcs.modified", Value: "false"}}
+	tests := []struct {
+		name string
+		info *debug.BuildInfo
+		want string
+	}{{
+		name: "no build information",
+		want: fallback,
+	}, {
+		name: "installed",
+		info: &debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "v0.0.5"}},
+		want: "v0.0.5",
+	}, {
+		name: "built from a checkout",
+		info: &debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "(devel)"}, Settings: checkout},
+		want: "abc123",
+	}, {
+		name: "built from a modified checkout",
+		info: &debug.BuildInfo{
+			Main:     debug.Module{Path: modulePath, Version: "(devel)"},
+			Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "abc123"}, {Key: "vcs.modified", Value: "true"}},
+		},
+		want: "abc123+" + fallback,
+	}, {
+		name: "tests",
+		info: &debug.BuildInfo{Main: debug.Module{Path: modulePath}},
+		want: fallback,
+	}, {
+		name: "dependency",
+		info: &debug.BuildInfo{
+			Main:     debug.Module{Path: "example.com/controller", Version: "(devel)"},
+			Deps:     []*debug.Module{{Path: modulePath, Version: "v0.0.4"}},
+			Settings: checkout,
+		},
+		want: "v0.0.4",
+	}, {
+		name: "dependency replaced by a directory",
+		info: &debug.BuildInfo{
+			Main:     debug.Module{Path: modulePath + "/native", Version: "(devel)"},
+			Deps:     []*debug.Module{{Path: modulePath, Version: "v0.0.0", Replace: &debug.Module{Path: "../"}}},
+			Settings: checkout,
+		},
+		want: "abc123",
+	}, {
+		name: "dependency of another checkout",
+		info: &debug.BuildInfo{
+			Main:     debug.Module{Path: "example.com/controller", Version: "(devel)"},
+			Deps:     []*debug.Module{{Path: modulePath, Version: "v0.0.0-20250101000000-abcdef123456"}},
+			Settings: checkout,
+		},
+		want: "v0.0.0-20250101000000-abcdef123456",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, moduleVersion(tt.info))
+		})
+	}
+}
+
+func TestPruneSchemaCache(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	used := filepath.Join(root, "key", "ab", "cdef", "apps_v1_Deployment.gob")
+	unused := filepath.Join(root, "other", "ab", "cdef", "apps_v1_Deployment.gob")
+	for _, path := range []string{used, unused} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+	}
+	old := now.Add(-cacheMaxAge - time.Hour)
+	require.NoError(t, os.Chtimes(unused, old, old))
+
+	pruneSchemaCache(root, now)
+	assert.FileExists(t, used)
+	assert.NoFileExists(t, unused)
+	assert.NoDirExists(t, filepath.Join(root, "other"))
+
+	// Pruned at most once per interval
+	require.NoError(t, os.Chtimes(used, old, old))
+	pruneSchemaCache(root, now.Add(time.Hour))
+	assert.FileExists(t, used)
+	pruneSchemaCache(root, now.Add(cachePruneInterval+time.Hour))
+	assert.NoFileExists(t, used)
+}
@@ -2,6 +2,7 @@ package validator
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
 	apiextensionsapiserver "k8s.io/apiextensions-apiserver/pkg/apiserver"
@@ -107,11 +108,15 @@ func (d schemaCoercingDecoder) Decode(data []byte, defaults *schema.GroupVersion
 		decodingStrictErrs = decodeStrictErr.Errors()
 	}
 	var unknownFields []string
+	var ss *structuralschema.Structural
 	if u, ok := obj.(*unstructured.Unstructured); ok {
 		unknownFields, err = d.validator.apply(u)
 		if err != nil {
 			return nil, gvk, err
 		}
+		if gv, err := schema.ParseGroupVersion(u.GetAPIVersion()); err == nil {
+			ss = d.validator.structuralSchemas[gv.Version]
+		}
 	}
 	if d.validator.returnUnknownFieldPaths && (len(decodingStrictErrs) > 0 || len(unknownFields) > 0) {
 		for _, unknownField := range unknownFields {
@@ -120,7 +125,15 @@ func (d schemaCoercingDecoder) Decode(data []byte, defaults *schema.GroupVersion
 			for i := 1; i < len(components); i++ {
 				path = path.Child(components[i])
 			}
-			decodingStrictErrs = append(decodingStrictErrs, field.Invalid(path, field.OmitValueType{}, "value provided for unknown field"))
+			msg := "value provided for unknown field"
+			suggestion := suggestField(ss, unknownField)
+			if suggestion != "" {
+				msg += fmt.Sprintf(", did you mean %q?", suggestion)
+			}
+			decodingStrictErrs = append(decodingStrictErrs, &UnknownFieldError{
+				FieldError: field.Invalid(path, field.OmitValueType{}, msg),
+				Suggestion: suggestion,
+			})
 		}
 		return obj, gvk, errors.Join(decodingStrictErrs...)
 	}
@@ -0,0 +1,291 @@
+package validator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"sigs.k8s.io/kubectl-validate/pkg/utils"
+)
+
+// openapiDocument is the OpenAPI document of a group version with its parts
+// left unparsed until they are needed
+type openapiDocument struct {
+	Paths      map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]json.RawMessage `json:"schemas"`
+	} `json:"components"`
+}
+
+// kindDefinition is the definition of a kind in an OpenAPI document
+type kindDefinition struct {
+	name       string
+	namespaced bool
+}
+
+// kindDefinitions indexes the definitions of the kinds in the document by
+// GVK, without parsing any other definition
+func (d *openapiDocument) kindDefinitions() map[schema.GroupVersionKind]kindDefinition {
+	gvkExtension := []byte(`"x-kubernetes-group-version-kind"`)
+
+	namespaced := sets.New[schema.GroupVersionKind]()
+	for path, pathInfo := range d.Paths {
+		if !strings.Contains(path, "namespaces/{namespace}") || !bytes.Contains(pathInfo, gvkExtension) {
+			continue
+		}
+		var operations struct {
+			Get, Put, Post, Delete *utils.KindExtensions
+		}
+		if err := json.Unmarshal(pathInfo, &operations); err != nil {
+			continue
+		}
+		for _, operation := range []*utils.KindExtensions{operations.Get, operations.Put, operations.Post, operations.Delete} {
+			if operation != nil {
+				namespaced.Insert(operation.GVKs()...)
+			}
+		}
+	}
+
+	res := map[schema.GroupVersionKind]kindDefinition{}
+	for nam, def := range d.Components.Schemas {
+		if !bytes.Contains(def, gvkExtension) {
+			continue
+		}
+		var extensions utils.KindExtensions
+		if err := json.Unmarshal(def, &extensions); err != nil {
+			continue
+		}
+		for _, gvk := range extensions.GVKs() {
+			// Try to infer the scope from paths
+			kind := kindDefinition{name: nam, namespaced: namespaced.Has(gvk)}
+			// Check schema extensions to see if the scope was manually added
+			if extensions.Scope != nil {
+				kind.namespaced = strings.EqualFold(*extensions.Scope, string(apiextensions.NamespaceScoped))
+			}
+			res[gvk] = kind
+		}
+	}
+	return res
+}
+
+// definitions gives access to the schema definitions of a group version.
+// Definitions are parsed, patched and have their references inlined on first
+// use, so only those reachable from the kinds being validated are ever
+// processed. The results are shared by all kinds of the group version.
+//
+// mu must be held to use definitions, which kinds of the group version may do
+// concurrently.
+type definitions struct {
+	mu sync.Mutex
+
+	gv  schema.GroupVersion
+	raw map[string]json.RawMessage
+	// Minor version of Kubernetes selecting the schema patches applied
+	k8sVersion int
+
+	// Patched definitions, with their references left in place until they
+	// are resolved
+	parsed map[string]*spec.Schema
+	// Definitions with references to all but recursive definitions inlined
+	resolved map[string]*spec.Schema
+
+	// Definitions referred to by each analyzed definition
+	references map[string][]string
+	// Analyzed definitions which refer to themselves, directly or through
+	// other definitions. Inlining references to them would never end.
+	recursive sets.Set[string]
+	// Analyzed definitions which are or refer to recursive definitions
+	reachesRecursive map[string]bool
+
+	// State of the search for strongly connected components among the
+	// references, which find the recursive definitions
+	index   map[string]int
+	lowlink map[string]int
+	stack   []string
+	onStack sets.Set[string]
+}
+
+func newDefinitions(gv schema.GroupVersion, k8sVersion int, raw map[string]json.RawMessage) *definitions {
+	return &definitions{
+		gv:               gv,
+		raw:              raw,
+		k8sVersion:       k8sVersion,
+		parsed:           map[string]*spec.Schema{},
+		resolved:         map[string]*spec.Schema{},
+		references:       map[string][]string{},
+		recursive:        sets.New[string](),
+		reachesRecursive: map[string]bool{},
+		index:            map[string]int{},
+		lowlink:          map[string]int{},
+		onStack:          sets.New[string](),
+	}
+}
+
+// has returns true if the document has a definition by the given name
+func (d *definitions) has(name string) bool {
+	_, ok := d.raw[name]
+	return ok
+}
+
+// get returns the patched definition by the given name, with references left
+// in place
+func (d *definitions) get(name string) (*spec.Schema, error) {
+	if def, ok := d.parsed[name]; ok {
+		return def, nil
+	}
+	raw, ok := d.raw[name]
+	if !ok {
+		return nil, fmt.Errorf("definition %v not found", name)
+	}
+	def := &spec.Schema{}
+	if err := json.Unmarshal(raw, def); err != nil {
+		return nil, fmt.Errorf("error parsing openapi definition %v: %w", name, err)
+	}
+	// Apply our transformations to workaround known k8s schema deficiencies
+	def = ApplySchemaPatches(d.k8sVersion, d.gv, name, def)
+	d.parsed[name] = def
+	return def, nil
+}
+
+// analyze finds the recursive definitions among those reachable from the
+// given one, using Tarjan's strongly connected components algorithm.
+// Definitions on a cycle are those in a component with more than one member,
+// or which refer to themselves.
+func (d *definitions) analyze(name string) error {
+	if _, visited := d.index[name]; visited {
+		return nil
+	}
+	def, err := d.get(name)
+	if err != nil {
+		return err
+	}
+	refs := sets.New[string]()
+	utils.VisitSchema(name, def, utils.PreorderVisitor(func(ctx utils.VisitingContext, sch *spec.Schema) (*spec.Schema, bool) {
+		if ref := referenceName(sch); len(ref) > 0 && d.has(ref) {
+			refs.Insert(ref)
+		}
+		return sch, true
+	}))
+	d.references[name] = sets.List(refs)
+
+	d.index[name] = len(d.index)
+	d.lowlink[name] = d.index[name]
+	d.stack = append(d.stack, name)
+	d.onStack.Insert(name)
+	for _, ref := range d.references[name] {
+		if _, visited := d.index[ref]; !visited {
+			if err := d.analyze(ref); err != nil {
+				return err
+			}
+			d.lowlink[name] = min(d.lowlink[name], d.lowlink[ref])
+		} else if d.onStack.Has(ref) {
+			d.lowlink[name] = min(d.lowlink[name], d.index[ref])
+		}
+	}
+	if d.lowlink[name] != d.index[name] {
+		return nil
+	}
+
+	i := len(d.stack) - 1
+	for d.stack[i] != name {
+		i--
+	}
+	component := d.stack[i:]
+	d.stack = d.stack[:i]
+	d.onStack.Delete(component...)
+	if len(component) > 1 || refs.Has(name) {
+		d.recursive.Insert(component...)
+	}
+	// Components are completed in reverse topological order, so whatever
+	// the members refer to outside of it is known already
+	reaches := d.recursive.Has(name)
+	for _, member := range component {
+		for _, ref := range d.references[member] {
+			reaches = reaches || d.reachesRecursive[ref]
+		}
+	}
+	for _, member := range component {
+		d.reachesRecursive[member] = reaches
+	}
+	return nil
+}
+
+// isRecursive returns true if the definition, or any definition it refers
+// to, is recursive
+func (d *definitions) isRecursive(name string) (bool, error) {
+	if err := d.analyze(name); err != nil {
+		return false, err
+	}
+	return d.reachesRecursive[name], nil
+}
+
+// resolve returns the definition by the given name with references to all
+// but recursive definitions inlined
+func (d *definitions) resolve(name string) (*spec.Schema, error) {
+	if def, ok := d.resolved[name]; ok {
+		return def, nil
+	}
+	if err := d.analyze(name); err != nil {
+		return nil, err
+	}
+	def, err := d.get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replaces subschemas that contain refs with copy of the thing they refer to.
+	// No need for stack/queue approach since we mutate same dictionary/slice instances
+	// destructively.
+	// !TODO: Once Declarative Validation for native types lands we will be
+	//	able to validate against the spec.Schema directly rather than
+	//	StructuralSchema, so this will be able to be removed
+	var referenceErrors []error
+	def = utils.VisitSchema(name, def, utils.PreorderVisitor(func(ctx utils.VisitingContext, sch *spec.Schema) (*spec.Schema, bool) {
+		defName := referenceName(sch)
+		if len(defName) == 0 {
+			// Nothing to do for no references
+			return sch, true
+		}
+
+		if !d.has(defName) {
+			// Can't resolve schema. This is an error.
+			var path []string
+			for cursor := &ctx; cursor != nil; cursor = cursor.Parent {
+				if len(cursor.Key) == 0 {
+					path = append(path, fmt.Sprint(cursor.Index))
+				} else {
+					path = append(path, cursor.Key)
+				}
+			}
+			sort.Stable(sort.Reverse(sort.StringSlice(path)))
+			referenceErrors = append(referenceErrors, fmt.Errorf("cannot resolve reference %v in %v.%v", defName, name, strings.Join(path, ".")))
+			return sch, true
+		} else if d.recursive.Has(defName) {
+			// Resolved per object by expandReferences, as deep as the
+			// object goes
+			return sch, false
+		}
+
+		resolved, err := d.resolve(defName)
+		if err != nil {
+			referenceErrors = append(referenceErrors, err)
+			return sch, false
+		}
+		// Don't explore children. This was a reference node and shares
+		// pointers with its schema which is resolved on its own.
+		return resolveReference(sch, resolved), false
+	}))
+	if len(referenceErrors) > 0 {
+		return nil, errors.Join(referenceErrors...)
+	}
+	d.resolved[name] = def
+	return def, nil
+}
@@ -0,0 +1,67 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// SchemaNotFoundError is returned for objects of a kind no schema is known for
+type SchemaNotFoundError struct {
+	GroupVersionKind schema.GroupVersionKind
+	// True if no schema is known for the group version at all
+	GroupVersionNotFound bool
+	// Hint at what was meant, such as `did you mean "ConfigMap"?` or
+	// "Ingress is served as networking.k8s.io/v1", if any
+	Suggestion string
+}
+
+func (e *SchemaNotFoundError) Error() string {
+	var res string
+	if e.GroupVersionNotFound {
+		res = fmt.Sprintf("failed to locate OpenAPI spec for GV: %v", e.GroupVersionKind.GroupVersion())
+	} else {
+		res = fmt.Sprintf("kind %v not found in %v groupversion", e.GroupVersionKind.Kind, e.GroupVersionKind.GroupVersion())
+	}
+	if e.Suggestion != "" {
+		res += "; " + e.Suggestion
+	}
+	return res
+}
+
+// ErrEmptyGVK is returned, wrapped in a DocumentError, for documents without
+// an apiVersion and kind, such as those which are not Kubernetes objects
+var ErrEmptyGVK = errors.New("GVK cannot be empty")
+
+// DocumentError is returned for documents which cannot be parsed into an
+// object, before any schema is involved
+type DocumentError struct {
+	Err error
+}
+
+func (e *DocumentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DocumentError) Unwrap() error {
+	return e.Err
+}
+
+// UnknownFieldError is returned, joined with the other errors of strict
+// decoding, for each field of a document which its schema does not have
+type UnknownFieldError struct {
+	// The error reported for the field, an invalid value at its path
+	FieldError *field.Error
+	// The field likely meant, if any
+	Suggestion string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return e.FieldError.Error()
+}
+
+func (e *UnknownFieldError) Unwrap() error {
+	return e.FieldError
+}
@@ -1,15 +1,31 @@
 package validator
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/operation"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/warning"
 	"k8s.io/client-go/openapi"
 	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
 	"sigs.k8s.io/yaml"
 )
 
@@ -96,7 +112,7 @@ func TestValidatorFactory_TestPatcher(t *testing.T) {
 			factory, err := New(tt.client)
 			assert.NoError(t, err)
 			assert.NotNil(t, factory)
-			validator, err := factory.infoForGVK(gvk)
+			validator, err := factory.infoForGVK(context.Background(), gvk)
 			assert.NoError(t, err)
 			decoder, err := validator.Decoder(gvk)
 			assert.NoError(t, err)
@@ -111,3 +127,427 @@ func TestValidatorFactory_TestPatcher(t *testing.T) {
 		})
 	}
 }
+
+func TestNativeValidation(t *testing.T) {
+	document := []byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: other
+    spec:
+      containers:
+      - name: web
+        image: nginx
+        ports:
+        - containerPort: 80
+        - containerPort: 80
+`)
+	// Stands for the checks of the native module, which rejects the labels
+	validate := func(_ context.Context, gvk schema.GroupVersionKind, obj *unstructured.Unstructured) (field.ErrorList, error) {
+		assert.Equal(t, "Deployment", gvk.Kind)
+		labels := field.NewPath("spec", "template", "metadata", "labels")
+		return field.ErrorList{field.Invalid(labels, obj.GetLabels(), "`selector` does not match template `labels`")}, nil
+	}
+	for _, native := range []bool{false, true} {
+		var opts []Option
+		if native {
+			opts = append(opts, WithNativeValidation(validate))
+		}
+		validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"), opts...)
+		assert.NoError(t, err)
+
+		_, parsed, err := validator.Parse(document)
+		assert.NoError(t, err)
+
+		err = validator.Validate(parsed)
+		var statusErr *k8serrors.StatusError
+		assert.ErrorAs(t, err, &statusErr)
+		var fields []string
+		for _, cause := range statusErr.ErrStatus.Details.Causes {
+			fields = append(fields, cause.Field)
+		}
+		if native {
+			// Errors from both are merged
+			assert.Equal(t, []string{"spec.template.spec.containers[0].ports[1]", "spec.template.metadata.labels"}, fields)
+		} else {
+			assert.Equal(t, []string{"spec.template.spec.containers[0].ports[1]"}, fields)
+		}
+	}
+}
+
+func TestDeclarativeValidation(t *testing.T) {
+	document := []byte(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+  - name: web
+    image: nginx
+    ports:
+    - containerPort: 80
+    - containerPort: 80
+`)
+	// Register a rule repeating the schema error along with a new one
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	scheme.AddValidationFunc((*corev1.Pod)(nil), func(context.Context, operation.Operation, interface{}, interface{}) field.ErrorList {
+		ports := field.NewPath("spec", "containers").Index(0).Child("ports")
+		return field.ErrorList{
+			field.Duplicate(ports.Index(1), 80),
+			field.Invalid(ports.Index(1).Child("containerPort"), 80, "must be unique"),
+		}
+	})
+
+	validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"), WithDeclarativeValidation(scheme))
+	assert.NoError(t, err)
+
+	_, parsed, err := validator.Parse(document)
+	assert.NoError(t, err)
+
+	err = validator.Validate(parsed)
+	var statusErr *k8serrors.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	var fields []string
+	for _, cause := range statusErr.ErrStatus.Details.Causes {
+		fields = append(fields, cause.Field)
+	}
+	// The duplicate of the schema error is dropped
+	assert.Equal(t, []string{"spec.containers[0].ports[1]", "spec.containers[0].ports[1].containerPort"}, fields)
+}
+
+func TestRecursiveSchema(t *testing.T) {
+	// Node refers to itself directly, and through Branch
+	schemas := fstest.MapFS{"apis/example.com/v1.json": {Data: []byte(`{
+  "openapi": "3.0.0",
+  "components": {"schemas": {
+    "com.example.v1.Tree": {
+      "type": "object",
+      "x-kubernetes-group-version-kind": [{"group": "example.com", "version": "v1", "kind": "Tree"}],
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "metadata": {"type": "object", "x-kubernetes-preserve-unknown-fields": true},
+        "root": {"allOf": [{"$ref": "#/components/schemas/com.example.v1.Node"}], "description": "root of the tree"}
+      }
+    },
+    "com.example.v1.Node": {
+      "type": "object",
+      "properties": {
+        "value": {"type": "integer"},
+        "next": {"$ref": "#/components/schemas/com.example.v1.Node"},
+        "branches": {"type": "array", "items": {"$ref": "#/components/schemas/com.example.v1.Branch"}}
+      }
+    },
+    "com.example.v1.Branch": {
+      "type": "object",
+      "properties": {
+        "weight": {"type": "integer", "default": 1},
+        "node": {"$ref": "#/components/schemas/com.example.v1.Node"}
+      }
+    }
+  }}
+}`)}}
+	recursive := sets.New("com.example.v1.Node", "com.example.v1.Branch")
+
+	tests := []struct {
+		name     string
+		document string
+		want     []string
+	}{{
+		name: "valid",
+		document: `
+apiVersion: example.com/v1
+kind: Tree
+metadata: {name: tree}
+root:
+  value: 1
+  next:
+    value: 2
+    branches:
+    - node: {value: 3, next: {value: 4}}
+`,
+	}, {
+		name: "invalid deep down",
+		document: `
+apiVersion: example.com/v1
+kind: Tree
+metadata: {name: tree}
+root:
+  next:
+    branches:
+    - node: {value: three, next: {valeu: 4}}
+`,
+		want: []string{"root.next.branches[0].node.next.valeu"},
+	}, {
+		name: "invalid type deep down",
+		document: `
+apiVersion: example.com/v1
+kind: Tree
+metadata: {name: tree}
+root:
+  next:
+    branches:
+    - node: {value: three}
+`,
+		want: []string{"root.next.branches[0].node.value"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, err := New(openapiclient.NewLocalSchemaFiles(schemas))
+			require.NoError(t, err)
+
+			_, parsed, err := validator.Parse([]byte(tt.document))
+			if err == nil {
+				err = validator.Validate(parsed)
+			}
+			if len(tt.want) == 0 {
+				assert.NoError(t, err)
+				// Defaults are applied to recursive parts as well
+				weight, _, _ := unstructured.NestedFieldNoCopy(parsed.Object, "root", "next", "branches")
+				assert.Equal(t, int64(1), weight.([]interface{})[0].(map[string]interface{})["weight"])
+
+				entry, err := validator.infoForGVK(context.Background(), parsed.GroupVersionKind())
+				require.NoError(t, err)
+				assert.Equal(t, recursive, entry.definitions.recursive)
+				return
+			}
+			require.Error(t, err)
+			for _, field := range tt.want {
+				assert.Contains(t, err.Error(), field)
+			}
+		})
+	}
+}
+
+func TestRecursiveSchemaExpansionsShared(t *testing.T) {
+	validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"))
+	require.NoError(t, err)
+	crd := func(kind, property string, nested bool) map[string]interface{} {
+		props := map[string]interface{}{"type": "string"}
+		if nested {
+			props = map[string]interface{}{"type": "object", "properties": map[string]interface{}{"inner": props}}
+		}
+		return map[string]interface{}{
+			"apiVersion": "apiextensions.k8s.io/v1",
+			"kind":       "CustomResourceDefinition",
+			"metadata":   map[string]interface{}{"name": kind + "s.example.com"},
+			"spec": map[string]interface{}{
+				"group": "example.com",
+				"names": map[string]interface{}{"kind": kind, "plural": kind + "s"},
+				"scope": "Namespaced",
+				"versions": []interface{}{map[string]interface{}{
+					"name": "v1", "served": true, "storage": true,
+					"schema": map[string]interface{}{"openAPIV3Schema": map[string]interface{}{
+						"type":       "object",
+						"properties": map[string]interface{}{property: props},
+					}},
+				}},
+			},
+		}
+	}
+
+	entry, err := validator.infoForGVK(context.Background(), schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"})
+	require.NoError(t, err)
+	require.NotNil(t, entry.definitions)
+	first, err := entry.forObject(crd("foo", "spec", false))
+	require.NoError(t, err)
+	same, err := entry.forObject(crd("bar", "status", false))
+	require.NoError(t, err)
+	assert.Same(t, first, same, "objects of the same shape share an entry")
+	deeper, err := entry.forObject(crd("baz", "spec", true))
+	require.NoError(t, err)
+	assert.NotSame(t, first, deeper)
+}
+
+func TestDefinitionsResolvedLazily(t *testing.T) {
+	validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"))
+	require.NoError(t, err)
+
+	_, _, err = validator.Parse([]byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config"}}`))
+	require.NoError(t, err)
+	defs := validator.groupVersions["api/v1"].definitions
+	assert.Contains(t, defs.resolved, "io.k8s.api.core.v1.ConfigMap")
+	assert.Contains(t, defs.resolved, "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta")
+	assert.NotContains(t, defs.parsed, "io.k8s.api.core.v1.PodSpec")
+
+	// Definitions are shared by the kinds of a group version
+	objectMeta := defs.resolved["io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"]
+	_, _, err = validator.Parse([]byte(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "pod"}, "spec": {"containers": [{"name": "c", "image": "i"}]}}`))
+	require.NoError(t, err)
+	assert.Contains(t, defs.resolved, "io.k8s.api.core.v1.PodSpec")
+	assert.Same(t, objectMeta, defs.resolved["io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"])
+}
+
+func TestConcurrentValidation(t *testing.T) {
+	validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"))
+	require.NoError(t, err)
+
+	documents := []string{
+		benchmarkDocuments["api/v1"],
+		benchmarkDocuments["apps/v1"],
+		`{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "web"}, "spec": {"replicas": "three"}}`,
+		`{"apiVersion": "apiextensions.k8s.io/v1", "kind": "CustomResourceDefinition", "metadata": {"name": "widgets.example.com"}}`,
+	}
+	got := make([][][]string, 8)
+	var wg sync.WaitGroup
+	for i := range got {
+		got[i] = make([][]string, len(documents))
+		for j, document := range documents {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got[i][j] = errorCauses(validateDocument(t, validator, document))
+			}()
+		}
+	}
+	wg.Wait()
+
+	// Entries built and used concurrently behave as those used on their own
+	for j, document := range documents {
+		fresh, err := New(openapiclient.NewHardcodedBuiltins("1.30"))
+		require.NoError(t, err)
+		want := errorCauses(validateDocument(t, fresh, document))
+		for i := range got {
+			assert.Equal(t, want, got[i][j], document)
+		}
+	}
+}
+
+type httpClient map[string]string
+
+func (c httpClient) Paths() (map[string]openapi.GroupVersion, error) {
+	res := map[string]openapi.GroupVersion{}
+	for path, uri := range c {
+		res[path] = groupversion.NewForHttp(uri)
+	}
+	return res, nil
+}
+
+// Shows that fetching the schema of an object is given up on once the
+// context is done, and that a later attempt fetches it again
+func TestParseContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	validator, err := New(httpClient{"apis/apps/v1": server.URL})
+	require.NoError(t, err)
+
+	document := []byte(benchmarkDocuments["apps/v1"])
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		_, _, err = validator.ParseContext(ctx, document)
+		cancel()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewContext(ctx, openapiclient.NewHardcodedBuiltins("1.30"))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// Shows that objects of other kinds are validated while the schemas of a
+// group version are being fetched
+func TestSlowGroupVersion(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	validator, err := New(openapiclient.NewComposite(
+		openapiclient.NewHardcodedBuiltins("1.30"),
+		httpClient{"apis/example.com/v1": server.URL},
+	))
+	require.NoError(t, err)
+	require.NoError(t, validateDocument(t, validator, benchmarkDocuments["apps/v1"]))
+
+	slow := make(chan error)
+	go func() {
+		slow <- validateDocument(t, validator, `{"apiVersion": "example.com/v1", "kind": "Widget", "metadata": {"name": "widget"}}`)
+	}()
+	<-started
+
+	// A cached kind, and one of a group version fetched meanwhile
+	done := make(chan error)
+	go func() {
+		done <- errors.Join(
+			validateDocument(t, validator, benchmarkDocuments["apps/v1"]),
+			validateDocument(t, validator, benchmarkDocuments["api/v1"]),
+		)
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("validation is held up by the fetch of another group version")
+	}
+
+	close(release)
+	assert.Error(t, <-slow)
+}
+
+type warningRecorder []string
+
+func (r *warningRecorder) AddWarning(agent, text string) {
+	*r = append(*r, text)
+}
+
+func TestFieldValidation(t *testing.T) {
+	unknown := []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config"}, "dat": {"key": "value"}}`)
+	invalid := `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config"}, "dat": {}, "data": "value"}`
+	for _, tt := range []struct {
+		level        FieldValidation
+		wantErr      bool
+		wantWarnings int
+	}{
+		{level: FieldValidationStrict, wantErr: true},
+		{level: FieldValidationWarn, wantWarnings: 1},
+		{level: FieldValidationIgnore},
+	} {
+		t.Run(string(tt.level), func(t *testing.T) {
+			validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"), WithFieldValidation(tt.level))
+			require.NoError(t, err)
+			var warnings warningRecorder
+			_, obj, err := validator.ParseContext(warning.WithWarningRecorder(context.Background(), &warnings), unknown)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "unknown field")
+			} else {
+				require.NoError(t, err)
+				assert.NotContains(t, obj.Object, "dat")
+			}
+			assert.Len(t, warnings, tt.wantWarnings)
+			if tt.wantWarnings > 0 {
+				assert.Contains(t, warnings[0], "dat")
+			}
+
+			// Other errors fail regardless
+			assert.Error(t, validateDocument(t, validator, invalid))
+		})
+	}
+}
+
+func TestSchemaSource(t *testing.T) {
+	validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"))
+	require.NoError(t, err)
+
+	assert.Equal(t, openapiclient.SourceBuiltin, validator.SchemaSource(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}))
+	// Kinds of unknown group versions, or unknown kinds of known ones
+	assert.Empty(t, validator.SchemaSource(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}))
+	assert.Empty(t, validator.SchemaSource(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMapp"}))
+}
@@ -0,0 +1,199 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+// FieldSchema documents what a field accepts, as told by the schema of its
+// kind
+type FieldSchema struct {
+	// Type of the values, such as string or integer, with the format of
+	// their contents, such as int32 or date-time, if any
+	Type   string `json:"type,omitempty"`
+	Format string `json:"format,omitempty"`
+	// Values allowed, if restricted to a set
+	Enum    []interface{} `json:"enum,omitempty"`
+	Pattern string        `json:"pattern,omitempty"`
+	// Bounds of numbers, inclusive unless exclusive
+	Minimum          *float64 `json:"minimum,omitempty"`
+	ExclusiveMinimum bool     `json:"exclusiveMinimum,omitempty"`
+	Maximum          *float64 `json:"maximum,omitempty"`
+	ExclusiveMaximum bool     `json:"exclusiveMaximum,omitempty"`
+	// Bounds of the lengths of strings and arrays
+	MinLength *int64 `json:"minLength,omitempty"`
+	MaxLength *int64 `json:"maxLength,omitempty"`
+	MinItems  *int64 `json:"minItems,omitempty"`
+	MaxItems  *int64 `json:"maxItems,omitempty"`
+	// First sentence of the description of the field
+	Description string `json:"description,omitempty"`
+}
+
+// String returns the field schema in a compact form, such as
+// `string, one of "Always", "Never" - Policy for restarting containers.`
+func (f *FieldSchema) String() string {
+	var parts []string
+	if f.Type != "" {
+		part := f.Type
+		if f.Format != "" {
+			part += " (" + f.Format + ")"
+		}
+		parts = append(parts, part)
+	}
+	if len(f.Enum) > 0 {
+		values := make([]string, len(f.Enum))
+		for i, value := range f.Enum {
+			values[i] = fmt.Sprintf("%q", fmt.Sprint(value))
+		}
+		parts = append(parts, "one of "+strings.Join(values, ", "))
+	}
+	if f.Pattern != "" {
+		parts = append(parts, "matching "+f.Pattern)
+	}
+	if f.Minimum != nil {
+		if f.ExclusiveMinimum {
+			parts = append(parts, fmt.Sprintf("greater than %v", *f.Minimum))
+		} else {
+			parts = append(parts, fmt.Sprintf("at least %v", *f.Minimum))
+		}
+	}
+	if f.Maximum != nil {
+		if f.ExclusiveMaximum {
+			parts = append(parts, fmt.Sprintf("less than %v", *f.Maximum))
+		} else {
+			parts = append(parts, fmt.Sprintf("at most %v", *f.Maximum))
+		}
+	}
+	parts = appendLengthBounds(parts, f.MinLength, f.MaxLength, "characters")
+	parts = appendLengthBounds(parts, f.MinItems, f.MaxItems, "items")
+	res := strings.Join(parts, ", ")
+	if f.Description != "" {
+		if res != "" {
+			res += " - "
+		}
+		res += f.Description
+	}
+	return res
+}
+
+func appendLengthBounds(parts []string, minimum, maximum *int64, unit string) []string {
+	switch {
+	case minimum != nil && maximum != nil:
+		return append(parts, fmt.Sprintf("%d to %d %s", *minimum, *maximum, unit))
+	case minimum != nil:
+		return append(parts, fmt.Sprintf("at least %d %s", *minimum, unit))
+	case maximum != nil:
+		return append(parts, fmt.Sprintf("at most %d %s", *maximum, unit))
+	}
+	return parts
+}
+
+// FieldSchema returns what the field at path of objects of the GVK accepts,
+// such as spec.containers[0].imagePullPolicy as reported by validation. Nil
+// is returned if the schema of the kind or the field is unknown, or if it
+// documents nothing. Fetching the schema of the kind is given up on once ctx
+// is done.
+func (s *Validator) FieldSchema(ctx context.Context, gvk schema.GroupVersionKind, path string) *FieldSchema {
+	entry, err := s.infoForGVK(ctx, gvk)
+	if err != nil {
+		return nil
+	}
+	sch := entry.Schema
+	for path != "" && sch != nil {
+		if path[0] == '[' {
+			// Index of an array or key of a map
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return nil
+			}
+			path = path[end+1:]
+			if sch.Items != nil {
+				sch = sch.Items.Schema
+			} else if sch.AdditionalProperties != nil {
+				sch = sch.AdditionalProperties.Schema
+			} else {
+				return nil
+			}
+		} else {
+			path = strings.TrimPrefix(path, ".")
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			property, ok := sch.Properties[path[:end]]
+			if !ok {
+				return nil
+			}
+			path = path[end:]
+			sch = &property
+		}
+		if sch != nil && entry.definitions != nil && referenceName(sch) != "" {
+			// References to recursive definitions are left in the schema
+			sch = entry.resolveReference(sch)
+		}
+	}
+	if sch == nil {
+		return nil
+	}
+	return newFieldSchema(sch)
+}
+
+// resolveReference returns the definition sch refers to, or nil if it cannot
+// be resolved
+func (v *validatorEntry) resolveReference(sch *spec.Schema) *spec.Schema {
+	v.definitions.mu.Lock()
+	defer v.definitions.mu.Unlock()
+	def, err := v.definitions.resolve(referenceName(sch))
+	if err != nil {
+		return nil
+	}
+	return resolveReference(sch, def)
+}
+
+func newFieldSchema(sch *spec.Schema) *FieldSchema {
+	res := &FieldSchema{
+		Type:             strings.Join(sch.Type, " or "),
+		Format:           sch.Format,
+		Enum:             sch.Enum,
+		Pattern:          sch.Pattern,
+		Minimum:          sch.Minimum,
+		ExclusiveMinimum: sch.ExclusiveMinimum,
+		Maximum:          sch.Maximum,
+		ExclusiveMaximum: sch.ExclusiveMaximum,
+		MinLength:        sch.MinLength,
+		MaxLength:        sch.MaxLength,
+		MinItems:         sch.MinItems,
+		MaxItems:         sch.MaxItems,
+	}
+	if intOrString, _ := sch.Extensions.GetBool("x-kubernetes-int-or-string"); intOrString {
+		res.Type, res.Format = "integer or string", ""
+	}
+	res.Description = firstSentence(sch.Description)
+	if res.String() == "" {
+		return nil
+	}
+	return res
+}
+
+// firstSentence returns the first sentence of a description, ending with a
+// period followed by a space, or else its first line. Abbreviations such as
+// "e.g." do not end sentences.
+func firstSentence(description string) string {
+	description, _, _ = strings.Cut(strings.TrimSpace(description), "\n")
+	for i := strings.IndexByte(description, '.'); i >= 0 && i+1 < len(description); {
+		word := description[strings.LastIndexAny(description[:i], " (")+1 : i]
+		if description[i+1] == ' ' && word != "e.g" && word != "i.e" {
+			return description[:i+1]
+		}
+		next := strings.IndexByte(description[i+1:], '.')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return description
+}
@@ -0,0 +1,65 @@
+package validator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+)
+
+func TestFieldSchema(t *testing.T) {
+	validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"))
+	require.NoError(t, err)
+	pod := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+
+	port := validator.FieldSchema(context.Background(), pod, "spec.containers[0].ports[0].containerPort")
+	require.NotNil(t, port)
+	assert.Equal(t, "integer", port.Type)
+	assert.Equal(t, "int32", port.Format)
+	assert.Equal(t, "Number of port to expose on the pod's IP address.", port.Description)
+
+	// Keys of maps are looked up in their values
+	label := validator.FieldSchema(context.Background(), pod, "metadata.labels[app.kubernetes.io/name]")
+	require.NotNil(t, label)
+	assert.Equal(t, "string", label.Type)
+
+	assert.Nil(t, validator.FieldSchema(context.Background(), pod, "spec.unknown"))
+	assert.Nil(t, validator.FieldSchema(context.Background(), schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, "spec"))
+}
+
+func TestFirstSentence(t *testing.T) {
+	for description, want := range map[string]string{
+		"Data contains the configuration data. Each key must consist of alphanumeric characters.": "Data contains the configuration data.",
+		"Name of the volume, e.g. config. Must be unique.":                                        "Name of the volume, e.g. config.",
+		"Policy for restarting containers\n\nPossible enum values:\n - `Always`":                  "Policy for restarting containers",
+		"Version, such as v1.2.3": "Version, such as v1.2.3",
+		"Ends with a period.":     "Ends with a period.",
+	} {
+		assert.Equal(t, want, firstSentence(description))
+	}
+}
+
+func TestFieldSchemaString(t *testing.T) {
+	for _, tt := range []struct {
+		schema FieldSchema
+		want   string
+	}{{
+		schema: FieldSchema{Type: "string", Enum: []interface{}{"Always", "Never"}, Description: "Restart policy."},
+		want:   `string, one of "Always", "Never" - Restart policy.`,
+	}, {
+		schema: FieldSchema{Type: "integer", Format: "int32", Minimum: ptr.To(0.0), Maximum: ptr.To(10.0), ExclusiveMaximum: true},
+		want:   "integer (int32), at least 0, less than 10",
+	}, {
+		schema: FieldSchema{Type: "string", Pattern: "^[a-z]+$", MinLength: ptr.To[int64](1), MaxLength: ptr.To[int64](63)},
+		want:   "string, matching ^[a-z]+$, 1 to 63 characters",
+	}, {
+		schema: FieldSchema{Type: "array", MaxItems: ptr.To[int64](3)},
+		want:   "array, at most 3 items",
+	}} {
+		assert.Equal(t, tt.want, tt.schema.String())
+	}
+}
@@ -0,0 +1,115 @@
+package validator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
+	"sigs.k8s.io/kubectl-validate/pkg/utils"
+)
+
+// LockedDocument identifies the final OpenAPI document of a group version, as
+// served by the client of a validator with its patches, along with the
+// schema patches the validator applies to it
+type LockedDocument struct {
+	// Sources of the kinds validated against the document, such as
+	// "builtin", separated by commas. Only informative.
+	Source string `json:"source,omitempty"`
+	// Hex encoded SHA-256 hash of the document and of the schema patches
+	// applied to it
+	SHA256 string `json:"sha256"`
+}
+
+// lockHash returns the hash locking a document of the given hash, once the
+// schema patches of the given minor version of Kubernetes are applied to it
+func lockHash(documentHash string, k8sVersion int) string {
+	sum := sha256.Sum256([]byte(documentHash + "\n" + schemaPatchesHash(k8sVersion)))
+	return hex.EncodeToString(sum[:])
+}
+
+// WithLockedDocuments makes objects fail validation if the document of their
+// group version is not the locked one, keyed by the path of the group version
+// such as apis/apps/v1. Group versions missing from locked always fail.
+func WithLockedDocuments(locked map[string]LockedDocument) Option {
+	return func(v *Validator) {
+		v.locked = locked
+		if v.locked == nil {
+			v.locked = map[string]LockedDocument{}
+		}
+	}
+}
+
+// LockMismatchError is returned for objects whose group version does not
+// have its locked document
+type LockMismatchError struct {
+	// Path of the group version, such as apis/apps/v1
+	GroupVersion string
+	// The locked document, nil if the group version is not locked
+	Locked *LockedDocument
+	// The document served instead
+	Got LockedDocument
+}
+
+func (e *LockMismatchError) Error() string {
+	got := e.Got.SHA256
+	if e.Got.Source != "" {
+		got += " from " + e.Got.Source
+	}
+	if e.Locked == nil {
+		return fmt.Sprintf("schemas of %s are not locked, got document %s", e.GroupVersion, got)
+	}
+	locked := e.Locked.SHA256
+	if e.Locked.Source != "" {
+		locked += " from " + e.Locked.Source
+	}
+	return fmt.Sprintf("schemas of %s changed since they were locked: expected document %s, got %s", e.GroupVersion, locked, got)
+}
+
+// checkLocked returns an error if the document of gv is not the locked one
+func checkLocked(gvPath string, locked map[string]LockedDocument, gv *groupVersion) error {
+	expected, ok := locked[gvPath]
+	if ok && expected.SHA256 == gv.lockHash {
+		return nil
+	}
+	res := &LockMismatchError{
+		GroupVersion: gvPath,
+		Got:          LockedDocument{Source: groupversion.SchemaSource(gv.fetcher, ""), SHA256: gv.lockHash},
+	}
+	if ok {
+		res.Locked = &expected
+	}
+	return res
+}
+
+// LockedDocuments returns the documents of the group versions used so far,
+// keyed by their path, to lock with WithLockedDocuments
+func (s *Validator) LockedDocuments() map[string]LockedDocument {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sources := map[string]sets.Set[string]{}
+	for gvk, entry := range s.validatorCache {
+		gvPath := utils.GroupVersionPath(gvk.GroupVersion())
+		gv, ok := s.groupVersions[gvPath]
+		if !ok || !gv.isFetched() {
+			continue
+		}
+		if sources[gvPath] == nil {
+			sources[gvPath] = sets.New[string]()
+		}
+		if source := groupversion.SchemaSource(gv.fetcher, entry.name); source != "" {
+			sources[gvPath].Insert(source)
+		}
+	}
+
+	res := map[string]LockedDocument{}
+	for gvPath, gv := range s.groupVersions {
+		if !gv.isFetched() || gv.err != nil {
+			continue
+		}
+		res[gvPath] = LockedDocument{Source: strings.Join(sets.List(sources[gvPath]), ","), SHA256: gv.lockHash}
+	}
+	return res
+}
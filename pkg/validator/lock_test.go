@@ -0,0 +1,71 @@
+package validator
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+)
+
+func TestLockedDocuments(t *testing.T) {
+	configMap := `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config"}}`
+	deployment := benchmarkDocuments["apps/v1"]
+
+	validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"))
+	require.NoError(t, err)
+	require.NoError(t, validateDocument(t, validator, configMap))
+	locked := validator.LockedDocuments()
+	require.Contains(t, locked, "api/v1")
+	assert.Equal(t, openapiclient.SourceBuiltin, locked["api/v1"].Source)
+	assert.Len(t, locked["api/v1"].SHA256, 64)
+	assert.NotContains(t, locked, "apis/apps/v1")
+
+	validator, err = New(openapiclient.NewHardcodedBuiltins("1.30"), WithLockedDocuments(locked))
+	require.NoError(t, err)
+	require.NoError(t, validateDocument(t, validator, configMap))
+
+	// Group versions which are not locked fail
+	var mismatch *LockMismatchError
+	err = validateDocument(t, validator, deployment)
+	require.True(t, errors.As(err, &mismatch), "got %v", err)
+	assert.Equal(t, "apis/apps/v1", mismatch.GroupVersion)
+	assert.Nil(t, mismatch.Locked)
+	assert.ErrorContains(t, err, "schemas of apis/apps/v1 are not locked")
+
+	// As do those whose document changed
+	changed := map[string]LockedDocument{"api/v1": {SHA256: "0123"}}
+	validator, err = New(openapiclient.NewHardcodedBuiltins("1.30"), WithLockedDocuments(changed))
+	require.NoError(t, err)
+	err = validateDocument(t, validator, configMap)
+	require.True(t, errors.As(err, &mismatch), "got %v", err)
+	require.NotNil(t, mismatch.Locked)
+	assert.Equal(t, "0123", mismatch.Locked.SHA256)
+	assert.Equal(t, locked["api/v1"].SHA256, mismatch.Got.SHA256)
+	assert.ErrorContains(t, err, "schemas of api/v1 changed since they were locked")
+}
+
+// Shows that changes to the schema patches applied to a document fail
+// validation like changes to the document do
+func TestLockedDocumentsPatches(t *testing.T) {
+	configMap := `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config"}}`
+
+	validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"), WithKubernetesVersion(version.MajorMinor(1, 30)))
+	require.NoError(t, err)
+	require.NoError(t, validateDocument(t, validator, configMap))
+	locked := validator.LockedDocuments()
+
+	original := schemaPatches
+	t.Cleanup(func() { schemaPatches = original })
+	schemaPatches = append(slices.Clone(original), SchemaPatch{Slug: "Test", MinMinorVersion: 30})
+
+	validator, err = New(openapiclient.NewHardcodedBuiltins("1.30"), WithKubernetesVersion(version.MajorMinor(1, 30)), WithLockedDocuments(locked))
+	require.NoError(t, err)
+	var mismatch *LockMismatchError
+	err = validateDocument(t, validator, configMap)
+	require.True(t, errors.As(err, &mismatch), "got %v", err)
+	assert.Equal(t, "api/v1", mismatch.GroupVersion)
+}
@@ -0,0 +1,307 @@
+package validator
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+// referenceName returns the name of the definition sch refers to, or an
+// empty string if it is not a reference.
+func referenceName(sch *spec.Schema) string {
+	ref := sch.Ref.String()
+	if len(sch.AllOf) == 1 && len(sch.AllOf[0].Ref.String()) > 0 {
+		// SPECIAL CASE
+		// OpenAPIV3 does not support having Refs in schemas with fields like
+		// Description, Default filled in. So k8s stuffs the Ref into a standalone
+		// AllOf in these cases.
+		// But structural schema doesn't like schemas that specify fields inside AllOf
+		// SO in the case of
+		// Properties
+		//	-> AllOf
+		//		-> Ref
+		ref = sch.AllOf[0].Ref.String()
+	}
+	if len(ref) == 0 {
+		return ""
+	}
+	return path.Base(ref)
+}
+
+// resolveReference returns a copy of the definition resolved with the
+// fields sch, which refers to it, is allowed to override
+func resolveReference(sch *spec.Schema, resolved *spec.Schema) *spec.Schema {
+	resolvedCopy := *resolved
+
+	if sch.Default != nil {
+		resolvedCopy.Default = sch.Default
+	}
+
+	// NOTE: No way to tell if field overrides nullable
+	// or if it is unset. Right now if the referred schema is
+	// nullable we will resolve to a nullable schema.
+	// There are no upstream schemas where nullable is used as a field
+	// level override, so we will assume `false` means `unset`.
+	// But this should be fixed in kube-openapi.
+	resolvedCopy.Nullable = resolvedCopy.Nullable || sch.Nullable
+
+	if len(sch.Type) > 0 {
+		resolvedCopy.Type = sch.Type
+	}
+
+	if len(sch.Description) > 0 {
+		resolvedCopy.Description = sch.Description
+	}
+
+	newExtensions := spec.Extensions{}
+	for k, v := range resolvedCopy.Extensions {
+		newExtensions.Add(k, v)
+	}
+	for k, v := range sch.Extensions {
+		newExtensions.Add(k, v)
+	}
+	if len(newExtensions) > 0 {
+		resolvedCopy.Extensions = newExtensions
+	}
+
+	return &resolvedCopy
+}
+
+// expandReferences returns a copy of sch in which the references left to
+// recursive definitions are resolved as deep as the given values of sch go.
+//
+// A recursive schema cannot be turned into a structural schema, which is a
+// tree. But any object is finite, so the part of the schema needed to
+// validate it is too. References below the fields set in the values are
+// replaced by the referred definition without its subschemas.
+func expandReferences(sch *spec.Schema, definitions *definitions, values []interface{}) (*spec.Schema, error) {
+	return expand(sch, definitions, values, nil)
+}
+
+// expand implements expandReferences. seen holds the definitions resolved at
+// the current depth of the values, to catch definitions which are nothing but
+// a reference to themselves.
+func expand(sch *spec.Schema, definitions *definitions, values []interface{}, seen []string) (*spec.Schema, error) {
+	if ref := referenceName(sch); len(ref) > 0 {
+		if slices.Contains(seen, ref) {
+			return nil, fmt.Errorf("definition %v refers to itself without nesting", ref)
+		}
+		def, err := definitions.resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		resolved := resolveReference(sch, def)
+		if len(values) == 0 {
+			// Nothing to validate beneath this point
+			resolved.Properties = nil
+			resolved.PatternProperties = nil
+			resolved.AdditionalProperties = nil
+			resolved.Items = nil
+			resolved.AdditionalItems = nil
+			resolved.AllOf = nil
+			resolved.AnyOf = nil
+			resolved.OneOf = nil
+			resolved.Not = nil
+			return resolved, nil
+		}
+		return expand(resolved, definitions, values, append(slices.Clip(seen), ref))
+	}
+
+	res := *sch
+	var err error
+	expandAll := func(schemas []spec.Schema, values []interface{}, seen []string) []spec.Schema {
+		if schemas == nil || err != nil {
+			return schemas
+		}
+		expanded := make([]spec.Schema, len(schemas))
+		for i := range schemas {
+			var e *spec.Schema
+			if e, err = expand(&schemas[i], definitions, values, seen); err != nil {
+				return nil
+			}
+			expanded[i] = *e
+		}
+		return expanded
+	}
+	res.AllOf = expandAll(sch.AllOf, values, seen)
+	res.AnyOf = expandAll(sch.AnyOf, values, seen)
+	res.OneOf = expandAll(sch.OneOf, values, seen)
+	if sch.Not != nil && err == nil {
+		res.Not, err = expand(sch.Not, definitions, values, seen)
+	}
+
+	// Subschemas are of nested values, which can refer to any definition again
+	var objects []map[string]interface{}
+	var fields, elements []interface{}
+	for _, value := range values {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			objects = append(objects, value)
+			for _, v := range value {
+				fields = append(fields, v)
+			}
+		case []interface{}:
+			elements = append(elements, value...)
+		}
+	}
+	if sch.Properties != nil && err == nil {
+		res.Properties = map[string]spec.Schema{}
+		for k, v := range sch.Properties {
+			var fieldValues []interface{}
+			for _, object := range objects {
+				if value, ok := object[k]; ok {
+					fieldValues = append(fieldValues, value)
+				} else if def := propertyDefault(&v, definitions); def != nil {
+					// Defaults are applied before validation, so they need
+					// a schema too
+					fieldValues = append(fieldValues, def)
+				}
+			}
+			var e *spec.Schema
+			if e, err = expand(&v, definitions, fieldValues, nil); err != nil {
+				break
+			}
+			res.Properties[k] = *e
+		}
+	}
+	if sch.PatternProperties != nil && err == nil {
+		res.PatternProperties = map[string]spec.Schema{}
+		for k, v := range sch.PatternProperties {
+			var e *spec.Schema
+			if e, err = expand(&v, definitions, fields, nil); err != nil {
+				break
+			}
+			res.PatternProperties[k] = *e
+		}
+	}
+	if sch.AdditionalProperties != nil && sch.AdditionalProperties.Schema != nil && err == nil {
+		res.AdditionalProperties = &spec.SchemaOrBool{Allows: sch.AdditionalProperties.Allows}
+		res.AdditionalProperties.Schema, err = expand(sch.AdditionalProperties.Schema, definitions, fields, nil)
+	}
+	if sch.Items != nil && err == nil {
+		res.Items = &spec.SchemaOrArray{}
+		if sch.Items.Schema != nil {
+			res.Items.Schema, err = expand(sch.Items.Schema, definitions, elements, nil)
+		}
+		res.Items.Schemas = expandAll(sch.Items.Schemas, elements, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// expansionKey identifies the schema expandReferences returns for the given
+// values. The expansion only depends on the values through which references
+// have values beneath them, so the key records that for each reference, in
+// the order expand reaches them. Objects of the same shape share a key,
+// whatever their scalar values and the names of their additional properties.
+func expansionKey(sch *spec.Schema, definitions *definitions, values []interface{}) (string, error) {
+	var key strings.Builder
+	if err := writeExpansionKey(&key, sch, definitions, values, nil); err != nil {
+		return "", err
+	}
+	return key.String(), nil
+}
+
+// writeExpansionKey implements expansionKey by walking sch like expand,
+// without copying it
+func writeExpansionKey(key *strings.Builder, sch *spec.Schema, definitions *definitions, values []interface{}, seen []string) error {
+	if ref := referenceName(sch); len(ref) > 0 {
+		if slices.Contains(seen, ref) {
+			return fmt.Errorf("definition %v refers to itself without nesting", ref)
+		}
+		def, err := definitions.resolve(ref)
+		if err != nil {
+			return err
+		}
+		if len(values) == 0 {
+			key.WriteByte('0')
+			return nil
+		}
+		key.WriteByte('1')
+		return writeExpansionKey(key, def, definitions, values, append(slices.Clip(seen), ref))
+	}
+
+	for _, schemas := range [][]spec.Schema{sch.AllOf, sch.AnyOf, sch.OneOf} {
+		for i := range schemas {
+			if err := writeExpansionKey(key, &schemas[i], definitions, values, seen); err != nil {
+				return err
+			}
+		}
+	}
+	if sch.Not != nil {
+		if err := writeExpansionKey(key, sch.Not, definitions, values, seen); err != nil {
+			return err
+		}
+	}
+
+	var objects []map[string]interface{}
+	var fields, elements []interface{}
+	for _, value := range values {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			objects = append(objects, value)
+			for _, v := range value {
+				fields = append(fields, v)
+			}
+		case []interface{}:
+			elements = append(elements, value...)
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(sch.Properties)) {
+		v := sch.Properties[k]
+		var fieldValues []interface{}
+		for _, object := range objects {
+			if value, ok := object[k]; ok {
+				fieldValues = append(fieldValues, value)
+			} else if def := propertyDefault(&v, definitions); def != nil {
+				fieldValues = append(fieldValues, def)
+			}
+		}
+		if err := writeExpansionKey(key, &v, definitions, fieldValues, nil); err != nil {
+			return err
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(sch.PatternProperties)) {
+		v := sch.PatternProperties[k]
+		if err := writeExpansionKey(key, &v, definitions, fields, nil); err != nil {
+			return err
+		}
+	}
+	if sch.AdditionalProperties != nil && sch.AdditionalProperties.Schema != nil {
+		if err := writeExpansionKey(key, sch.AdditionalProperties.Schema, definitions, fields, nil); err != nil {
+			return err
+		}
+	}
+	if sch.Items != nil {
+		if sch.Items.Schema != nil {
+			if err := writeExpansionKey(key, sch.Items.Schema, definitions, elements, nil); err != nil {
+				return err
+			}
+		}
+		for i := range sch.Items.Schemas {
+			if err := writeExpansionKey(key, &sch.Items.Schemas[i], definitions, elements, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// propertyDefault returns the default of a property, set on the property or
+// the definition it refers to
+func propertyDefault(sch *spec.Schema, definitions *definitions) interface{} {
+	if sch.Default != nil {
+		return sch.Default
+	} else if ref := referenceName(sch); len(ref) > 0 && definitions.has(ref) {
+		if def, err := definitions.get(ref); err == nil {
+			return def.Default
+		}
+	}
+	return nil
+}
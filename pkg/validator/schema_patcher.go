@@ -1,8 +1,12 @@
 package validator
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"path"
 	"reflect"
+	"slices"
 	"strings"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -47,6 +51,19 @@ var invalidDefaultSchemas sets.Set[string] = func() sets.Set[string] {
 	return res
 }()
 
+// isAPIExtensionsDefinition returns a function matching the definitions of the
+// given types in any version of apiextensions
+func isAPIExtensionsDefinition(types ...string) func(string) bool {
+	return func(defName string) bool {
+		versioned, ok := strings.CutPrefix(defName, "io.k8s.apiextensions-apiserver.pkg.apis.apiextensions.")
+		if !ok {
+			return false
+		}
+		_, typ, ok := strings.Cut(versioned, ".")
+		return ok && slices.Contains(types, typ)
+	}
+}
+
 func isBuiltInType(gv schema.GroupVersion) bool {
 	// filter out non built-in types
 	if gv.Group == "" {
@@ -118,6 +135,32 @@ var schemaPatches []SchemaPatch = []SchemaPatch{
 			}
 		}),
 	},
+	{
+		Slug:                "JSONSchemaPropsOrArrayDefinition",
+		AppliesToDefinition: isAPIExtensionsDefinition("JSONSchemaPropsOrArray"),
+		Description:         "JSONSchemaPropsOrArray is published without a schema since it is custom marshalled. The array form is never allowed in CRDs, so validate it as JSONSchemaProps",
+		Transformer: utils.PreorderVisitor(func(ctx utils.VisitingContext, s *spec.Schema) (*spec.Schema, bool) {
+			propsRef := "#/components/schemas/" + strings.TrimSuffix(ctx.Key, "OrArray")
+			return &spec.Schema{
+				SchemaProps: spec.SchemaProps{
+					Description: s.Description,
+					AllOf:       []spec.Schema{*spec.RefSchema(propsRef)},
+				},
+			}, false
+		}),
+	},
+	{
+		Slug:                "PreserveUnknownFieldsOfJSONDefinitions",
+		AppliesToDefinition: isAPIExtensionsDefinition("JSON", "JSONSchemaPropsOrBool", "JSONSchemaPropsOrStringArray"),
+		Description:         "Definitions of apiextensions which hold arbitrary JSON, or either of several types, are published without a schema and would have all of their fields pruned",
+		Transformer: utils.PreorderVisitor(func(ctx utils.VisitingContext, s *spec.Schema) (*spec.Schema, bool) {
+			if s.Extensions == nil {
+				s.Extensions = spec.Extensions{}
+			}
+			s.Extensions["x-kubernetes-preserve-unknown-fields"] = true
+			return s, false
+		}),
+	},
 	{
 		Slug:        "RemoveInvalidDefaults",
 		Description: "Kubernetes publishes a {} default for any struct type. This doesn't make sense if the type is special with custom marshalling",
@@ -143,11 +186,30 @@ var schemaPatches []SchemaPatch = []SchemaPatch{
 	},
 }
 
+// appliesToVersion returns true if the patch applies to the given minor
+// version of Kubernetes
+func (p SchemaPatch) appliesToVersion(k8sVersion int) bool {
+	return (p.MinMinorVersion == 0 || p.MinMinorVersion <= k8sVersion) &&
+		(p.MaxMinorVersion == 0 || p.MaxMinorVersion >= k8sVersion)
+}
+
+// schemaPatchesHash identifies the patches applied to the schemas of the
+// given minor version of Kubernetes, by their slugs, descriptions and version
+// ranges
+func schemaPatchesHash(k8sVersion int) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "k8s:%d\n", k8sVersion)
+	for _, p := range schemaPatches {
+		if p.appliesToVersion(k8sVersion) {
+			fmt.Fprintf(h, "%s:%d-%d:%s\n", p.Slug, p.MinMinorVersion, p.MaxMinorVersion, p.Description)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func ApplySchemaPatches(k8sVersion int, gv schema.GroupVersion, defName string, schema *spec.Schema) *spec.Schema {
 	for _, p := range schemaPatches {
-		if p.MinMinorVersion != 0 && p.MinMinorVersion > k8sVersion {
-			continue
-		} else if p.MaxMinorVersion != 0 && p.MaxMinorVersion < k8sVersion {
+		if !p.appliesToVersion(k8sVersion) {
 			continue
 		} else if p.AppliesToGV != nil && !p.AppliesToGV(gv) {
 			continue
@@ -0,0 +1,132 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apiextensions-apiserver/pkg/apiserver"
+	"k8s.io/apiextensions-apiserver/pkg/registry/customresourcedefinition"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+// strategy extends the CRD strategy used to validate every object with
+// checks for native types. Their errors are merged with the schema errors.
+//
+// CustomResourceDefinitions always get the handwritten checks of the
+// apiserver, as their schema cannot express what makes a valid schema.
+type strategy struct {
+	rest.RESTCreateStrategy
+
+	// GVK of the object as written, before it was adapted to the CRD strategy
+	gvk schema.GroupVersionKind
+
+	// Checks of native types, if enabled
+	native NativeValidationFunc
+	// Scheme of the declarative validation functions, if enabled
+	declarative *runtime.Scheme
+}
+
+func (s strategy) Validate(ctx context.Context, obj runtime.Object) field.ErrorList {
+	allErrs := s.RESTCreateStrategy.Validate(ctx, obj)
+	u := obj.(*unstructured.Unstructured)
+	if s.gvk.GroupKind() == customResourceDefinitionGK {
+		crdErrs, err := validateCustomResourceDefinition(ctx, u)
+		if err != nil {
+			return withConversionError(allErrs, err)
+		}
+		allErrs = appendUnique(allErrs, crdErrs)
+	}
+	if s.native != nil {
+		nativeErrs, err := s.native(ctx, s.gvk, u)
+		if err != nil {
+			return withConversionError(allErrs, err)
+		}
+		// The upstream checks also cover what the OpenAPI schema does, such
+		// as required fields
+		allErrs = appendUnique(allErrs, nativeErrs)
+	}
+	if s.declarative != nil {
+		declarativeErrs, err := validateDeclarative(ctx, s.declarative, s.gvk, u)
+		if err != nil {
+			return withConversionError(allErrs, err)
+		}
+		// Declarative rules are mostly migrated from the OpenAPI schema and
+		// handwritten checks, so they often report the same problem again.
+		allErrs = appendUnique(allErrs, declarativeErrs)
+	}
+	return allErrs
+}
+
+var customResourceDefinitionGK = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+var (
+	customResourceDefinitionDecoder  = serializer.NewCodecFactory(apiserver.Scheme).UniversalDecoder()
+	customResourceDefinitionStrategy = customresourcedefinition.NewStrategy(apiserver.Scheme)
+)
+
+// validateCustomResourceDefinition runs the checks the apiserver performs
+// for CustomResourceDefinitions against obj
+func validateCustomResourceDefinition(ctx context.Context, obj *unstructured.Unstructured) (field.ErrorList, error) {
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	crd, _, err := customResourceDefinitionDecoder.Decode(data, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	customResourceDefinitionStrategy.PrepareForCreate(ctx, crd)
+	return customResourceDefinitionStrategy.Validate(ctx, crd), nil
+}
+
+// validateDeclarative runs the validation functions registered in scheme
+// for the type of the given GVK against obj, such as those generated from the
+// +k8s: tags of native types. obj is defaulted first, like the apiserver
+// does. Objects of types unknown to scheme return no errors.
+func validateDeclarative(ctx context.Context, scheme *runtime.Scheme, gvk schema.GroupVersionKind, obj *unstructured.Unstructured) (field.ErrorList, error) {
+	typed, err := scheme.New(gvk)
+	if err != nil {
+		return nil, nil
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), typed); err != nil {
+		return nil, fmt.Errorf("failed to convert %v to its native type: %w", gvk, err)
+	}
+	scheme.Default(typed)
+	return scheme.Validate(ctx, nil, typed), nil
+}
+
+// withConversionError adds the error of converting an object to its native
+// type. Objects with schema errors may not fit their native type. Those
+// errors are enough to go on.
+func withConversionError(allErrs field.ErrorList, err error) field.ErrorList {
+	if len(allErrs) == 0 {
+		allErrs = append(allErrs, field.InternalError(field.NewPath(""), err))
+	}
+	return allErrs
+}
+
+// appendUnique appends the errors of newErrs not already in errs
+func appendUnique(errs field.ErrorList, newErrs field.ErrorList) field.ErrorList {
+	for _, e := range newErrs {
+		if !containsError(errs, e) {
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+// containsError returns true if errs has an error of the same type for the
+// same field as e
+func containsError(errs field.ErrorList, e *field.Error) bool {
+	for _, other := range errs {
+		if other.Type == e.Type && other.Field == e.Field {
+			return true
+		}
+	}
+	return false
+}
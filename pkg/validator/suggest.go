@@ -0,0 +1,145 @@
+package validator
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kubectl-validate/pkg/utils"
+)
+
+// closest returns the candidate most likely meant by name: one equal to it
+// ignoring case, or else the one within the smallest edit distance of it, up
+// to a third of its length. An empty string is returned if none is close.
+func closest(name string, candidates []string) string {
+	candidates = slices.Sorted(slices.Values(candidates))
+	best, bestDistance := "", max(1, len(name)/3)+1
+	for _, candidate := range candidates {
+		if candidate == name {
+			continue
+		} else if strings.EqualFold(candidate, name) {
+			return candidate
+		}
+		if d := editDistance(strings.ToLower(name), strings.ToLower(candidate)); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// suggestField returns the property most likely meant by the unknown field
+// at path, as reported by pruning such as spec.containers[0].ports[0].port,
+// among those of its parent in the structural schema ss
+func suggestField(ss *structuralschema.Structural, path string) string {
+	components := strings.Split(path, ".")
+	for _, component := range components[:len(components)-1] {
+		if ss == nil {
+			return ""
+		}
+		name, _, _ := strings.Cut(component, "[")
+		if property, ok := ss.Properties[name]; ok {
+			ss = &property
+		} else if ss.AdditionalProperties != nil {
+			ss = ss.AdditionalProperties.Structural
+		} else {
+			return ""
+		}
+		for range strings.Count(component, "[") {
+			if ss == nil {
+				return ""
+			}
+			ss = ss.Items
+		}
+	}
+	if ss == nil || len(ss.Properties) == 0 {
+		return ""
+	}
+	properties := make([]string, 0, len(ss.Properties))
+	for property := range ss.Properties {
+		properties = append(properties, property)
+	}
+	return closest(components[len(components)-1], properties)
+}
+
+// suggestKind returns a hint at what was meant by an object of the GVK no
+// schema is known for: a kind of its group version with a close name, the
+// group versions known to serve its kind, or the served versions of its
+// group. gv is nil if the group version is not served. s.mu must be held.
+func (s *Validator) suggestKind(gvk schema.GroupVersionKind, gv *groupVersion) string {
+	if gv != nil {
+		kinds := make([]string, 0, len(gv.kinds))
+		for other := range gv.kinds {
+			kinds = append(kinds, other.Kind)
+		}
+		if kind := closest(gvk.Kind, kinds); kind != "" {
+			return fmt.Sprintf("did you mean %q?", kind)
+		}
+	}
+
+	// Kinds are only known for native types and the group versions fetched
+	// so far, the others are not fetched for a hint
+	served := map[string]bool{}
+	kind := gvk.Kind
+	add := func(other schema.GroupVersionKind) {
+		if !strings.EqualFold(other.Kind, gvk.Kind) || other.GroupVersion() == gvk.GroupVersion() {
+			return
+		}
+		if _, ok := s.gvs[utils.GroupVersionPath(other.GroupVersion())]; ok {
+			served[other.GroupVersion().String()] = true
+			kind = other.Kind
+		}
+	}
+	for other := range s.scheme.AllKnownTypes() {
+		add(other)
+	}
+	for other := range s.validatorCache {
+		add(other)
+	}
+	for _, other := range s.groupVersions {
+		for k := range other.kinds {
+			add(k)
+		}
+	}
+	if len(served) > 0 {
+		return fmt.Sprintf("%s is served as %s", kind, strings.Join(slices.Sorted(maps.Keys(served)), ", "))
+	}
+
+	if gv == nil && gvk.Group != "" {
+		var versions []string
+		for gvPath := range s.gvs {
+			if other, err := utils.ParseGroupVersionPath(gvPath); err == nil && other.Group == gvk.Group {
+				versions = append(versions, other.String())
+			}
+		}
+		if len(versions) > 0 {
+			slices.Sort(versions)
+			return fmt.Sprintf("%s is served as %s", gvk.Group, strings.Join(versions, ", "))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package validator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+)
+
+func TestClosest(t *testing.T) {
+	candidates := []string{"containerPort", "hostPort", "name", "protocol"}
+	for name, want := range map[string]string{
+		"containerport": "containerPort",
+		"contianerPort": "containerPort",
+		"protocl":       "protocol",
+		"nam":           "name",
+		"image":         "",
+		"name":          "",
+	} {
+		assert.Equal(t, want, closest(name, candidates), name)
+	}
+	assert.Equal(t, 0, editDistance("kind", "kind"))
+	assert.Equal(t, 3, editDistance("kitten", "sitting"))
+}
+
+func TestSuggestions(t *testing.T) {
+	validator, err := New(openapiclient.NewHardcodedBuiltins("1.30"))
+	require.NoError(t, err)
+
+	err = validateDocument(t, validator, `{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "pod"}, "spec": {"containers": [{"name": "web", "image": "nginx", "ports": [{"containerport": 80}]}]}}`)
+	assert.ErrorContains(t, err, `spec.containers[0].ports[0].containerport: Invalid value: value provided for unknown field, did you mean "containerPort"?`)
+	var unknownErr *UnknownFieldError
+	require.True(t, errors.As(err, &unknownErr), "got %v", err)
+	assert.Equal(t, "spec.containers[0].ports[0].containerport", unknownErr.FieldError.Field)
+	assert.Equal(t, "containerPort", unknownErr.Suggestion)
+
+	for document, want := range map[string]string{
+		`{"apiVersion": "apps/v1", "kind": "Deploymnet", "metadata": {"name": "web"}}`:         `kind Deploymnet not found in apps/v1 groupversion; did you mean "Deployment"?`,
+		`{"apiVersion": "apps/v1", "kind": "Ingress", "metadata": {"name": "web"}}`:            `kind Ingress not found in apps/v1 groupversion; Ingress is served as networking.k8s.io/v1`,
+		`{"apiVersion": "extensions/v1beta1", "kind": "Ingress", "metadata": {"name": "web"}}`: `failed to locate OpenAPI spec for GV: extensions/v1beta1; Ingress is served as networking.k8s.io/v1`,
+		`{"apiVersion": "apps/v1beta3", "kind": "Replicator", "metadata": {"name": "web"}}`:    `failed to locate OpenAPI spec for GV: apps/v1beta3; apps is served as apps/v1`,
+		`{"apiVersion": "example.com/v1", "kind": "Widget", "metadata": {"name": "widget"}}`:   `failed to locate OpenAPI spec for GV: example.com/v1`,
+	} {
+		err := validateDocument(t, validator, document)
+		var notFound *SchemaNotFoundError
+		require.True(t, errors.As(err, &notFound), "got %v", err)
+		assert.Equal(t, want, notFound.Error())
+	}
+}
@@ -5,79 +5,206 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"path"
-	"sort"
 	"strings"
+	"sync"
 
 	"golang.org/x/exp/maps"
-	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
-	"k8s.io/apiextensions-apiserver/pkg/registry/customresource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/warning"
+	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/openapi"
-	"k8s.io/kube-openapi/pkg/spec3"
-	"k8s.io/kube-openapi/pkg/validation/spec"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient/groupversion"
 	"sigs.k8s.io/kubectl-validate/pkg/utils"
 	"sigs.k8s.io/yaml"
 )
 
+// Validator parses and validates objects against the schemas of their kinds.
+// It is safe for concurrent use.
 type Validator struct {
-	gvs            map[string]openapi.GroupVersion
+	gvs map[string]openapi.GroupVersion
+
+	// mu guards the schemas loaded so far
+	mu             sync.Mutex
+	groupVersions  map[string]*groupVersion
 	validatorCache map[schema.GroupVersionKind]*validatorEntry
+
+	nativeValidation  NativeValidationFunc
+	declarativeScheme *runtime.Scheme
+	// Minor version of Kubernetes selecting the schema patches applied
+	k8sVersion      int
+	schemaCacheDir  string
+	schemaCache     *schemaCache
+	scheme          *runtime.Scheme
+	fieldValidation FieldValidation
+	// Documents group versions must have, if locked
+	locked map[string]LockedDocument
+}
+
+// Option configures optional behavior of a Validator
+type Option func(*Validator)
+
+// NativeValidationFunc runs the checks the apiserver performs for the native
+// type of the given GVK against obj. Objects of types without checks return
+// no errors.
+type NativeValidationFunc func(ctx context.Context, gvk schema.GroupVersionKind, obj *unstructured.Unstructured) (field.ErrorList, error)
+
+// WithNativeValidation enables the checks the apiserver performs for native
+// types beyond their OpenAPI schemas, run by validate. Their errors are merged
+// with the schema errors. The checks of k8s.io/kubernetes are provided by the
+// nativevalidation package of the native module, which keeps that dependency
+// out of this one.
+func WithNativeValidation(validate NativeValidationFunc) Option {
+	return func(v *Validator) {
+		v.nativeValidation = validate
+	}
+}
+
+// WithDeclarativeValidation runs the validation functions registered in
+// scheme for the types of objects, such as those generated from the +k8s:
+// tags of native types, along with the other checks. Only register rules the
+// targeted Kubernetes version enforces. See nativevalidation.DeclarativeScheme
+// of the native module for those of k8s.io/kubernetes.
+func WithDeclarativeValidation(scheme *runtime.Scheme) Option {
+	return func(v *Validator) {
+		v.declarativeScheme = scheme
+	}
+}
+
+// WithSchemaCache persists the schemas of kinds in the given directory once
+// resolved, so later validators skip parsing the OpenAPI documents they come
+// from. Entries are recomputed if their document or the schema patches change.
+func WithSchemaCache(dir string) Option {
+	return func(v *Validator) {
+		v.schemaCacheDir = dir
+	}
+}
+
+// WithKubernetesVersion sets the version of Kubernetes the schemas are those
+// of, selecting the schema patches applied to them. By default, only the
+// patches of every version are applied.
+func WithKubernetesVersion(k8sVersion *version.Version) Option {
+	return func(v *Validator) {
+		v.k8sVersion = int(k8sVersion.Minor())
+	}
+}
+
+// WithScheme sets the scheme ValidateObject looks up the kinds of typed
+// objects in. Defaults to the scheme of native types of client-go.
+func WithScheme(scheme *runtime.Scheme) Option {
+	return func(v *Validator) {
+		v.scheme = scheme
+	}
 }
 
-func New(client openapi.Client) (*Validator, error) {
-	gvs, err := client.Paths()
+// FieldValidation is how objects with unknown or duplicate fields are
+// treated, as with the field validation of the apiserver
+type FieldValidation string
+
+const (
+	// Unknown and duplicate fields fail parsing, the default
+	FieldValidationStrict FieldValidation = "Strict"
+	// Unknown fields are dropped and duplicate fields keep their last value,
+	// each being reported with warning.AddWarning on the context of parsing,
+	// from FieldWarningAgent
+	FieldValidationWarn FieldValidation = "Warn"
+	// Unknown fields are dropped and duplicate fields keep their last value
+	FieldValidationIgnore FieldValidation = "Ignore"
+)
+
+// FieldWarningAgent is the agent of the warnings about unknown or duplicate
+// fields, telling them from those of the checks of kinds
+const FieldWarningAgent = "field-validation"
+
+// WithFieldValidation sets how objects with unknown or duplicate fields are
+// treated. Defaults to FieldValidationStrict.
+func WithFieldValidation(level FieldValidation) Option {
+	return func(v *Validator) {
+		v.fieldValidation = level
+	}
+}
+
+func New(client openapi.Client, opts ...Option) (*Validator, error) {
+	return NewContext(context.Background(), client, opts...)
+}
+
+// NewContext is like New, giving up on listing the paths of the client once
+// ctx is done
+func NewContext(ctx context.Context, client openapi.Client, opts ...Option) (*Validator, error) {
+	gvs, err := openapiclient.Paths(ctx, client)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Validator{
-		gvs:            gvs,
-		validatorCache: map[schema.GroupVersionKind]*validatorEntry{},
-	}, nil
+	res := &Validator{
+		gvs:             gvs,
+		groupVersions:   map[string]*groupVersion{},
+		validatorCache:  map[schema.GroupVersionKind]*validatorEntry{},
+		scheme:          scheme.Scheme,
+		fieldValidation: FieldValidationStrict,
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	if res.schemaCacheDir != "" {
+		res.schemaCache = newSchemaCache(res.schemaCacheDir, res.k8sVersion)
+	}
+	return res, nil
 }
 
 // Parse parses JSON or YAML text and parses it into unstructured.Unstructured.
 // Unset fields with defaults in their schema will have the defaults populated.
 //
 // It will return errors when there is an issue parsing the object, or if
-// it contains fields unknown to the schema, or if the schema was recursive.
+// it contains fields unknown to the schema.
 func (s *Validator) Parse(document []byte) (schema.GroupVersionKind, *unstructured.Unstructured, error) {
+	return s.ParseContext(context.Background(), document)
+}
+
+// ParseContext is like Parse, giving up on fetching the schema of the object
+// once ctx is done
+func (s *Validator) ParseContext(ctx context.Context, document []byte) (schema.GroupVersionKind, *unstructured.Unstructured, error) {
 	metadata := metav1.TypeMeta{}
 	if err := yaml.Unmarshal(document, &metadata); err != nil {
-		return schema.GroupVersionKind{}, nil, fmt.Errorf("failed to parse yaml: %w", err)
+		return schema.GroupVersionKind{}, nil, &DocumentError{fmt.Errorf("failed to parse yaml: %w", err)}
 	}
 
 	gvk := metadata.GetObjectKind().GroupVersionKind()
 	if gvk.Empty() {
-		return schema.GroupVersionKind{}, nil, fmt.Errorf("GVK cannot be empty")
+		return schema.GroupVersionKind{}, nil, &DocumentError{ErrEmptyGVK}
 	}
 
-	validators, err := s.infoForGVK(gvk)
+	validators, err := s.infoForGVK(ctx, gvk)
 	if err != nil {
 		return gvk, nil, fmt.Errorf("failed to retrieve validator: %w", err)
 	}
+	if validators.definitions != nil {
+		var obj interface{}
+		if err := yaml.Unmarshal(document, &obj); err != nil {
+			return gvk, nil, &DocumentError{fmt.Errorf("failed to parse yaml: %w", err)}
+		}
+		if validators, err = validators.forObject(obj); err != nil {
+			return gvk, nil, err
+		}
+	}
 
 	// Fetch a decoder to decode this object from its structural schema
-	decoder, err := validators.Decoder(gvk)
+	dec, err := validators.StrictDecoder(gvk)
 	if err != nil {
 		return gvk, nil, err
 	}
 
-	const mediaType = runtime.ContentTypeYAML
-	info, ok := runtime.SerializerInfoForMediaType(decoder.SupportedMediaTypes(), mediaType)
-	if !ok {
-		return gvk, nil, fmt.Errorf("unsupported media type %q", mediaType)
-	}
-
-	dec := decoder.DecoderToVersion(info.StrictSerializer, gvk.GroupVersion())
 	runtimeObj, _, err := dec.Decode(document, &gvk, &unstructured.Unstructured{})
+	if err != nil && s.fieldValidation != FieldValidationStrict {
+		runtimeObj, err = s.decodeLenient(ctx, validators, gvk, document, err)
+	}
 	if err != nil {
 		return gvk, nil, err
 	}
@@ -85,9 +212,39 @@ func (s *Validator) Parse(document []byte) (schema.GroupVersionKind, *unstructur
 	return gvk, runtimeObj.(*unstructured.Unstructured), nil
 }
 
+// decodeLenient decodes a document which failed strict decoding with
+// strictErr, ignoring unknown and duplicate fields. strictErr is returned if
+// the document fails for other reasons.
+func (s *Validator) decodeLenient(ctx context.Context, validators *validatorEntry, gvk schema.GroupVersionKind, document []byte, strictErr error) (runtime.Object, error) {
+	dec, err := validators.LenientDecoder(gvk)
+	if err != nil {
+		return nil, err
+	}
+	obj, _, err := dec.Decode(document, &gvk, &unstructured.Unstructured{})
+	if err != nil {
+		return nil, strictErr
+	}
+	if s.fieldValidation == FieldValidationWarn {
+		errs := []error{strictErr}
+		if joined, ok := strictErr.(interface{ Unwrap() []error }); ok {
+			errs = joined.Unwrap()
+		}
+		for _, e := range errs {
+			warning.AddWarning(ctx, FieldWarningAgent, e.Error())
+		}
+	}
+	return obj, nil
+}
+
 // Validate takes a parsed resource as input and validates it against
 // its schema.
 func (s *Validator) Validate(obj *unstructured.Unstructured) error {
+	return s.ValidateContext(context.Background(), obj)
+}
+
+// ValidateContext is like Validate, giving up on fetching the schema of the
+// object once ctx is done. The context is passed on to the checks of the kind.
+func (s *Validator) ValidateContext(ctx context.Context, obj *unstructured.Unstructured) error {
 	if obj == nil || obj.Object == nil {
 		return errors.New("passed object cannot be nil")
 	}
@@ -96,10 +253,15 @@ func (s *Validator) Validate(obj *unstructured.Unstructured) error {
 	// deep copy metadata object
 	obj.Object["metadata"] = runtime.DeepCopyJSONValue(obj.Object["metadata"])
 	gvk := obj.GroupVersionKind()
-	validators, err := s.infoForGVK(gvk)
+	originalGVK := gvk
+	validators, err := s.infoForGVK(ctx, gvk)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve validator: %w", err)
 	}
+	validators, err = validators.forObject(obj.Object)
+	if err != nil {
+		return err
+	}
 
 	isNamespaced := validators.IsNamespaceScoped()
 	if isNamespaced && obj.GetNamespace() == "" {
@@ -113,206 +275,267 @@ func (s *Validator) Validate(obj *unstructured.Unstructured) error {
 		obj.SetAPIVersion("core/v1")
 	}
 
-	ss, err := validators.StructuralSchema()
-	if err != nil || ss == nil {
+	strat, err := validators.Strategy(gvk)
+	if err != nil {
 		return err
 	}
-
-	strat := customresource.NewStrategy(validators.ObjectTyper(gvk), isNamespaced, gvk, validators.SchemaValidator(), nil,
-		ss,
-		nil, nil, nil)
+	strat = strategy{
+		RESTCreateStrategy: strat,
+		gvk:                originalGVK,
+		native:             s.nativeValidation,
+		declarative:        s.declarativeScheme,
+	}
 
 	rest.FillObjectMetaSystemFields(obj)
-	return rest.BeforeCreate(strat, request.WithNamespace(context.TODO(), obj.GetNamespace()), obj)
+	return rest.BeforeCreate(strat, request.WithNamespace(ctx, obj.GetNamespace()), obj)
 }
 
-func (s *Validator) infoForGVK(gvk schema.GroupVersionKind) (*validatorEntry, error) {
-	if existing, ok := s.validatorCache[gvk]; ok {
-		return existing, nil
-	}
+// ValidateObject validates a typed object, such as an *appsv1.Deployment,
+// as if it was parsed from a manifest. Objects whose apiVersion and kind are
+// not set are looked up in the scheme of the validator. Defaults are populated
+// in a copy of the object, which is left unchanged.
+func (s *Validator) ValidateObject(obj runtime.Object) error {
+	return s.ValidateObjectContext(context.Background(), obj)
+}
 
-	// Otherwise, fetch the open API schema for this GV and do the above
-	// Lookup gvk in client
-	// Guess the rest mapping since we don't have a rest mapper for the target
-	// cluster
-	gvPath := "apis/" + gvk.Group + "/" + gvk.Version
-	if len(gvk.Group) == 0 {
-		gvPath = "api/" + gvk.Version
+// ValidateObjectContext is like ValidateObject, giving up on fetching the
+// schema of the object once ctx is done
+func (s *Validator) ValidateObjectContext(ctx context.Context, obj runtime.Object) error {
+	if obj == nil {
+		return errors.New("passed object cannot be nil")
 	}
-	gvFetcher, exists := s.gvs[gvPath]
-	if !exists {
-		return nil, fmt.Errorf("failed to locate OpenAPI spec for GV: %v", gvk.GroupVersion())
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		gvks, _, err := s.scheme.ObjectKinds(obj)
+		if err != nil {
+			return fmt.Errorf("failed to find the kind of the object: %w", err)
+		}
+		gvk = gvks[0]
 	}
-
-	documentBytes, err := gvFetcher.Schema("application/json")
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching openapi at path %s: %w", gvPath, err)
-	}
-
-	openapiSpec := spec3.OpenAPI{}
-	if err := json.Unmarshal(documentBytes, &openapiSpec); err != nil {
-		return nil, fmt.Errorf("error parsing openapi spec: %w", err)
-	}
-
-	// Apply our transformations to workaround known k8s schema deficiencies
-	for nam, def := range openapiSpec.Components.Schemas {
-		//!TODO: would be useful to know which version of k8s each schema is believed
-		// to come from.
-		openapiSpec.Components.Schemas[nam] = ApplySchemaPatches(0, gvk.GroupVersion(), nam, def)
-	}
-
-	// Remove all references/indirection.
-	// This is kinda hacky because we still do allow recursive schemas via
-	// pointer trickery.
-	// No need for stack/queue approach since we mutate same dictionary/slice instances
-	// destructively.
-	// Replaces subschemas that contain refs with copy of the thing they refer to
-	// !TODO validate that no cyces are created by this process. If so, do not
-	// allow structural schema creation via JSON
-	// !TODO: track unresolved references?
-	// !TODO: Once Declarative Validation for native types lands we will be
-	//	able to validate against the spec.Schema directly rather than
-	//	StructuralSchema, so this will be able to be removed
-	var referenceErrors []error
-	for nam, def := range openapiSpec.Components.Schemas {
-		// This hack only works because top level schemas never have references
-		// so we can reliably copy them knowing they wont change and pointer-share
-		// their subfields. The only schemas being modified here should be sub-fields.
-		openapiSpec.Components.Schemas[nam] = utils.VisitSchema(nam, def, utils.PreorderVisitor(func(ctx utils.VisitingContext, sch *spec.Schema) (*spec.Schema, bool) {
-			defName := sch.Ref.String()
-
-			if len(sch.AllOf) == 1 && len(sch.AllOf[0].Ref.String()) > 0 {
-				// SPECIAL CASE
-				// OpenAPIV3 does not support having Refs in schemas with fields like
-				// Description, Default filled in. So k8s stuffs the Ref into a standalone
-				// AllOf in these cases.
-				// But structural schema doesn't like schemas that specify fields inside AllOf
-				// SO in the case of
-				// Properties
-				//	-> AllOf
-				//		-> Ref
-				defName = sch.AllOf[0].Ref.String()
-			}
+		return fmt.Errorf("failed to convert the object to unstructured: %w", err)
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(gvk)
+	document, err := u.MarshalJSON()
+	if err != nil {
+		return err
+	}
 
-			if len(defName) == 0 {
-				// Nothing to do for no references
-				return sch, true
-			}
+	// Go through parsing for the defaults and pruning manifests get
+	_, parsed, err := s.ParseContext(ctx, document)
+	if err != nil {
+		return err
+	}
+	return s.ValidateContext(ctx, parsed)
+}
 
-			defName = path.Base(defName)
-			resolved, ok := openapiSpec.Components.Schemas[defName]
-			if !ok {
-				// Can't resolve schema. This is an error.
-				var path []string
-				for cursor := &ctx; cursor != nil; cursor = cursor.Parent {
-					if len(cursor.Key) == 0 {
-						path = append(path, fmt.Sprint(cursor.Index))
-					} else {
-						path = append(path, cursor.Key)
-					}
-				}
-				sort.Stable(sort.Reverse(sort.StringSlice(path)))
-				referenceErrors = append(referenceErrors, fmt.Errorf("cannot resolve reference %v in %v.%v", defName, nam, strings.Join(path, ".")))
-				return sch, true
-			}
+func (s *Validator) infoForGVK(ctx context.Context, gvk schema.GroupVersionKind) (*validatorEntry, error) {
+	s.mu.Lock()
+	existing, ok := s.validatorCache[gvk]
+	s.mu.Unlock()
+	if ok {
+		return existing, nil
+	}
 
-			resolvedCopy := *resolved
+	gv, err := s.groupVersionFor(ctx, gvk.GroupVersion())
+	var notFound *SchemaNotFoundError
+	if errors.As(err, &notFound) {
+		notFound.GroupVersionKind = gvk
+		s.mu.Lock()
+		notFound.Suggestion = s.suggestKind(gvk, nil)
+		s.mu.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.schemaCache != nil {
+		if cached, ok := s.schemaCache.load(gv.hash, gvk); ok {
+			return s.storeEntry(gvk, cached), nil
+		}
+	}
+	if err := s.parse(gv); err != nil {
+		return nil, err
+	}
+	kind, ok := gv.kinds[gvk]
+	if !ok {
+		// Schema catalogs serve kinds whose case they do not know in lower
+		// case, which no kind of the apiserver is
+		kind, ok = gv.kinds[gvk.GroupVersion().WithKind(strings.ToLower(gvk.Kind))]
+	}
+	if !ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return nil, &SchemaNotFoundError{GroupVersionKind: gvk, Suggestion: s.suggestKind(gvk, gv)}
+	}
 
-			if sch.Default != nil {
-				resolvedCopy.Default = sch.Default
-			}
+	gv.definitions.mu.Lock()
+	def, err := gv.definitions.resolve(kind.name)
+	var recursive bool
+	if err == nil {
+		recursive, err = gv.definitions.isRecursive(kind.name)
+	}
+	gv.definitions.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var defs *definitions
+	if recursive {
+		defs = gv.definitions
+	}
+	val := newValidatorEntry(kind.name, kind.namespaced, def, defs)
+	if s.schemaCache != nil {
+		// The cache is an optimization, failing to write it is not an error
+		_ = s.schemaCache.store(gv.hash, gvk, val)
+	}
+	return s.storeEntry(gvk, val), nil
+}
 
-			// NOTE: No way to tell if field overrides nullable
-			// or if it is unset. Right now if the referred schema is
-			// nullable we will resolve to a nullable schema.
-			// There are no upstream schemas where nullable is used as a field
-			// level override, so we will assume `false` means `unset`.
-			// But this should be fixed in kube-openapi.
-			resolvedCopy.Nullable = resolvedCopy.Nullable || sch.Nullable
+// storeEntry keeps the entry of the kind for its later objects, unless
+// another one was stored while it was being built
+func (s *Validator) storeEntry(gvk schema.GroupVersionKind, entry *validatorEntry) *validatorEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.validatorCache[gvk]; ok {
+		return existing
+	}
+	s.validatorCache[gvk] = entry
+	return entry
+}
 
-			if len(sch.Type) > 0 {
-				resolvedCopy.Type = sch.Type
-			}
+// SchemaSource returns where the schema of the kind comes from, such as
+// "builtin" or "local-crds", or an empty string if it is unknown. See
+// package openapiclient.
+func (s *Validator) SchemaSource(gvk schema.GroupVersionKind) string {
+	entry, err := s.infoForGVK(context.Background(), gvk)
+	if err != nil {
+		return ""
+	}
+	s.mu.Lock()
+	gv, ok := s.groupVersions[utils.GroupVersionPath(gvk.GroupVersion())]
+	s.mu.Unlock()
+	if !ok || gv == nil || gv.fetcher == nil {
+		return ""
+	}
+	return groupversion.SchemaSource(gv.fetcher, entry.name)
+}
 
-			if len(sch.Description) > 0 {
-				resolvedCopy.Description = sch.Description
-			}
+// groupVersion holds what is known of the OpenAPI document of a group
+// version, shared by all of its kinds
+type groupVersion struct {
+	gv      schema.GroupVersion
+	fetcher openapi.GroupVersion
+
+	// Closed once the document is fetched, or failed to be. The fields
+	// below are set by then.
+	fetched  chan struct{}
+	document []byte
+	// hash of the document, keying the cached schemas of its kinds
+	hash string
+	// hash of the document and the schema patches applied to it, to lock it
+	lockHash string
+	// Error the group version fails with, such as its document not being
+	// the locked one
+	err error
+
+	// parseMu makes the document parsed once. kinds and definitions are set
+	// with the mutex of the validator held too.
+	parseMu     sync.Mutex
+	kinds       map[schema.GroupVersionKind]kindDefinition
+	definitions *definitions
+}
 
-			newExtensions := spec.Extensions{}
-			for k, v := range resolvedCopy.Extensions {
-				newExtensions.Add(k, v)
-			}
-			for k, v := range sch.Extensions {
-				newExtensions.Add(k, v)
-			}
-			if len(newExtensions) > 0 {
-				resolvedCopy.Extensions = newExtensions
-			}
+// isFetched returns true if the document was fetched, or failed to be
+func (g *groupVersion) isFetched() bool {
+	select {
+	case <-g.fetched:
+		return true
+	default:
+		return false
+	}
+}
 
-			// Sanity check that schemas generated by k8s do not have a ref
-			// AND override fields other than these
-			// schCopy := *sch
-			// if len(sch.AllOf) == 1 && len(sch.AllOf[0].Ref.String()) > 0 {
-			// 	  schCopy.AllOf = nil
-			// }
-			// schCopy.Extensions = nil
-			// schCopy.Type = nil
-			// schCopy.Description = ""
-			// schCopy.Nullable = false
-			// schCopy.Default = nil
-
-			// if !reflect.DeepEqual(schCopy, spec.Schema{}) {
-			// 	referenceErrors = append(referenceErrors, fmt.Errorf("not empty %#v", sch))
-			// }
-
-			// Don't explore children. This was a reference node and shares
-			// pointers with its schema which will be traversed in this loop.
-			return &resolvedCopy, false
-		}))
-	}
-
-	if len(referenceErrors) > 0 {
-		return nil, errors.Join(referenceErrors...)
-	}
-
-	namespaced := sets.New[schema.GroupVersionKind]()
-	if openapiSpec.Paths != nil {
-		for path, pathInfo := range openapiSpec.Paths.Paths {
-			for _, gvk := range utils.ExtractPathGVKs(pathInfo) {
-				if !namespaced.Has(gvk) {
-					if strings.Contains(path, "namespaces/{namespace}") {
-						namespaced.Insert(gvk)
-					}
-				}
-			}
-		}
+// parse parses the document of gv on first use. Kinds found in the schema
+// cache never need it.
+func (s *Validator) parse(g *groupVersion) error {
+	g.parseMu.Lock()
+	defer g.parseMu.Unlock()
+	if g.definitions != nil {
+		return nil
+	}
+	document := openapiDocument{}
+	if err := json.Unmarshal(g.document, &document); err != nil {
+		return fmt.Errorf("error parsing openapi spec: %w", err)
 	}
+	kinds := document.kindDefinitions()
+	definitions := newDefinitions(g.gv, s.k8sVersion, document.Components.Schemas)
+	s.mu.Lock()
+	g.kinds, g.definitions = kinds, definitions
+	s.mu.Unlock()
+	return nil
+}
 
-	for nam, def := range openapiSpec.Components.Schemas {
-		gvks := utils.ExtractExtensionGVKs(def.Extensions)
-		if len(gvks) == 0 {
-			continue
+// groupVersionFor fetches the OpenAPI document of the group version on first
+// use. It is only parsed once needed by a kind.
+//
+// The document is fetched without holding the mutex of the validator, so
+// objects of other group versions are not held up by a slow source. Objects
+// of the same group version wait for the first fetch.
+func (s *Validator) groupVersionFor(ctx context.Context, gv schema.GroupVersion) (*groupVersion, error) {
+	// Lookup gv in client
+	// Guess the rest mapping since we don't have a rest mapper for the target
+	// cluster
+	gvPath := utils.GroupVersionPath(gv)
+	for {
+		s.mu.Lock()
+		existing, ok := s.groupVersions[gvPath]
+		if !ok {
+			gvFetcher, exists := s.gvs[gvPath]
+			if !exists {
+				s.mu.Unlock()
+				return nil, &SchemaNotFoundError{GroupVersionKind: gv.WithKind(""), GroupVersionNotFound: true}
+			}
+			res := &groupVersion{gv: gv, fetcher: gvFetcher, fetched: make(chan struct{})}
+			s.groupVersions[gvPath] = res
+			s.mu.Unlock()
+			s.fetch(ctx, gvPath, res)
+			return res, res.err
 		}
+		s.mu.Unlock()
 
-		// Try to infer the scope from paths
-		nsScoped := namespaced.Has(gvk)
-		// Check schema extensions to see if the scope was manually added
-		if scope, ok := def.Extensions.GetString("x-kubectl-validate-scope"); ok {
-			nsScoped = strings.EqualFold(scope, string(apiextensions.NamespaceScoped))
+		select {
+		case <-existing.fetched:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("error fetching openapi at path %s: %w", gvPath, ctx.Err())
 		}
-
-		val := newValidatorEntry(nam, nsScoped, def)
-
-		for _, specGVK := range gvks {
-			s.validatorCache[specGVK] = val
+		// A fetch given up on by its caller is tried again with the context
+		// of this one
+		if existing.document == nil && ctx.Err() == nil &&
+			(errors.Is(existing.err, context.Canceled) || errors.Is(existing.err, context.DeadlineExceeded)) {
+			continue
 		}
+		return existing, existing.err
 	}
+}
 
-	// Check again to see if the desired GVK was added to the spec cache.
-	// If so, create validator for it
-	if existing, ok := s.validatorCache[gvk]; ok {
-		return existing, nil
+// fetch fetches the document of the group version into res. Failed fetches
+// are forgotten, for the next object of the group version to try again.
+func (s *Validator) fetch(ctx context.Context, gvPath string, res *groupVersion) {
+	defer close(res.fetched)
+	documentBytes, err := groupversion.Schema(ctx, res.fetcher, "application/json")
+	if err != nil {
+		res.err = fmt.Errorf("error fetching openapi at path %s: %w", gvPath, err)
+		s.mu.Lock()
+		delete(s.groupVersions, gvPath)
+		s.mu.Unlock()
+		return
 	}
 
-	return nil, fmt.Errorf("kind %v not found in %v groupversion", gvk.Kind, gvk.GroupVersion())
+	res.document = documentBytes
+	res.hash = documentHash(documentBytes)
+	res.lockHash = lockHash(res.hash, s.k8sVersion)
+	if s.locked != nil {
+		res.err = checkLocked(gvPath, s.locked, res)
+	}
 }
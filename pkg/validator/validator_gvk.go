@@ -2,30 +2,117 @@ package validator
 
 import (
 	"encoding/json"
+	"fmt"
+	"sync"
 
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apiextensions-apiserver/pkg/apiserver/conversion"
 	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
 	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/apiextensions-apiserver/pkg/registry/customresource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/registry/rest"
 	"k8s.io/kube-openapi/pkg/validation/spec"
 	"k8s.io/kube-openapi/pkg/validation/strfmt"
 	"k8s.io/kube-openapi/pkg/validation/validate"
 )
 
+// validatorEntry holds the schema of a kind and what is built from it to
+// decode and validate objects. Everything is built on first use and reused by
+// all objects of the kind, so it is safe for concurrent use.
 type validatorEntry struct {
 	*spec.Schema
 	name            string
 	namespaceScoped bool
-	schemaValidator validation.SchemaValidator
-	ss              *structuralschema.Structural
+
+	schemaValidatorOnce sync.Once
+	schemaValidator     validation.SchemaValidator
+
+	ssOnce sync.Once
+	ss     *structuralschema.Structural
+	ssErr  error
+
+	// Typers, decoders and strategies by the GVK they were built for. An
+	// entry serves a single kind, but core types are validated under the
+	// "core" group while decoded under the empty one.
+	mu         sync.Mutex
+	typers     map[schema.GroupVersion]runtime.ObjectTyper
+	decoders   map[schema.GroupVersionKind]runtime.Decoder
+	lenient    map[schema.GroupVersionKind]runtime.Decoder
+	strategies map[schema.GroupVersionKind]rest.RESTCreateStrategy
+
+	// definitions to resolve the references to recursive definitions left in
+	// Schema. Nil if there are none.
+	definitions *definitions
+
+	// Entries for the schemas expanded for objects of the recursive kind, by
+	// the expansionKey of the objects. Objects of the same shape share an
+	// entry along with its structural schema, decoders and strategies.
+	expansionsMu sync.Mutex
+	expansions   map[string]*validatorEntry
 }
 
-func newValidatorEntry(name string, namespaceScoped bool, openapiSchema *spec.Schema) *validatorEntry {
-	return &validatorEntry{Schema: openapiSchema, name: name, namespaceScoped: namespaceScoped}
+// maxExpansions bounds the number of expanded schemas kept per recursive
+// kind. Objects of further shapes get an entry of their own.
+const maxExpansions = 256
+
+func newValidatorEntry(name string, namespaceScoped bool, openapiSchema *spec.Schema, definitions *definitions) *validatorEntry {
+	return &validatorEntry{
+		Schema:          openapiSchema,
+		name:            name,
+		namespaceScoped: namespaceScoped,
+		typers:          map[schema.GroupVersion]runtime.ObjectTyper{},
+		decoders:        map[schema.GroupVersionKind]runtime.Decoder{},
+		lenient:         map[schema.GroupVersionKind]runtime.Decoder{},
+		strategies:      map[schema.GroupVersionKind]rest.RESTCreateStrategy{},
+		definitions:     definitions,
+		expansions:      map[string]*validatorEntry{},
+	}
+}
+
+// forObject returns an entry able to validate the given object. The schema of
+// recursive kinds is infinite, so they get one resolved as deep as the
+// object goes, shared with the objects of the same shape. Others are returned
+// as is.
+func (v *validatorEntry) forObject(obj interface{}) (*validatorEntry, error) {
+	if v.definitions == nil {
+		return v, nil
+	}
+	v.definitions.mu.Lock()
+	key, err := expansionKey(v.Schema, v.definitions, []interface{}{obj})
+	v.definitions.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve recursive schema %v: %w", v.name, err)
+	}
+
+	v.expansionsMu.Lock()
+	entry, ok := v.expansions[key]
+	v.expansionsMu.Unlock()
+	if ok {
+		return entry, nil
+	}
+
+	v.definitions.mu.Lock()
+	expanded, err := expandReferences(v.Schema, v.definitions, []interface{}{obj})
+	v.definitions.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve recursive schema %v: %w", v.name, err)
+	}
+	entry = newValidatorEntry(v.name, v.namespaceScoped, expanded, nil)
+
+	v.expansionsMu.Lock()
+	defer v.expansionsMu.Unlock()
+	if existing, ok := v.expansions[key]; ok {
+		// Expanded concurrently for another object of the same shape
+		return existing, nil
+	}
+	if len(v.expansions) < maxExpansions {
+		v.expansions[key] = entry
+	}
+	return entry, nil
 }
 
 func (v *validatorEntry) IsNamespaceScoped() bool {
@@ -33,25 +120,82 @@ func (v *validatorEntry) IsNamespaceScoped() bool {
 }
 
 func (v *validatorEntry) SchemaValidator() validation.SchemaValidator {
-	if v.schemaValidator != nil {
-		return v.schemaValidator
-	}
-
-	v.schemaValidator = &basicValidatorAdapter{SchemaValidator: validate.NewSchemaValidator(v.Schema, nil, "", strfmt.Default)}
+	v.schemaValidatorOnce.Do(func() {
+		v.schemaValidator = &basicValidatorAdapter{SchemaValidator: validate.NewSchemaValidator(v.Schema, nil, "", strfmt.Default)}
+	})
 	return v.schemaValidator
 }
 
 func (v *validatorEntry) ObjectTyper(gvk schema.GroupVersionKind) runtime.ObjectTyper {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.objectTyper(gvk.GroupVersion())
+}
+
+// objectTyper implements ObjectTyper. v.mu must be held.
+func (v *validatorEntry) objectTyper(gv schema.GroupVersion) runtime.ObjectTyper {
+	if typer, ok := v.typers[gv]; ok {
+		return typer
+	}
 	parameterScheme := runtime.NewScheme()
-	parameterScheme.AddUnversionedTypes(schema.GroupVersion{Group: gvk.Group, Version: gvk.Version},
+	parameterScheme.AddUnversionedTypes(gv,
 		&metav1.ListOptions{},
 		&metav1.GetOptions{},
 		&metav1.DeleteOptions{},
 	)
-	return newUnstructuredObjectTyper(parameterScheme)
+	typer := newUnstructuredObjectTyper(parameterScheme)
+	v.typers[gv] = typer
+	return typer
+}
+
+// StrictDecoder returns the decoder of YAML or JSON objects of the GVK, which
+// fails on unknown and duplicate fields
+func (v *validatorEntry) StrictDecoder(gvk schema.GroupVersionKind) (runtime.Decoder, error) {
+	return v.yamlDecoder(gvk, true)
+}
+
+// LenientDecoder returns the decoder of YAML or JSON objects of the GVK,
+// which drops unknown fields and keeps the last of duplicate fields
+func (v *validatorEntry) LenientDecoder(gvk schema.GroupVersionKind) (runtime.Decoder, error) {
+	return v.yamlDecoder(gvk, false)
+}
+
+func (v *validatorEntry) yamlDecoder(gvk schema.GroupVersionKind, strict bool) (runtime.Decoder, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	decoders := v.lenient
+	if strict {
+		decoders = v.decoders
+	}
+	if decoder, ok := decoders[gvk]; ok {
+		return decoder, nil
+	}
+
+	serializer, err := v.decoder(gvk)
+	if err != nil {
+		return nil, err
+	}
+	const mediaType = runtime.ContentTypeYAML
+	info, ok := runtime.SerializerInfoForMediaType(serializer.SupportedMediaTypes(), mediaType)
+	if !ok {
+		return nil, fmt.Errorf("unsupported media type %q", mediaType)
+	}
+	decoder := serializer.DecoderToVersion(info.Serializer, gvk.GroupVersion())
+	if strict {
+		decoder = serializer.DecoderToVersion(info.StrictSerializer, gvk.GroupVersion())
+	}
+	decoders[gvk] = decoder
+	return decoder, nil
 }
 
 func (v *validatorEntry) Decoder(gvk schema.GroupVersionKind) (runtime.NegotiatedSerializer, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.decoder(gvk)
+}
+
+// decoder implements Decoder. v.mu must be held.
+func (v *validatorEntry) decoder(gvk schema.GroupVersionKind) (runtime.NegotiatedSerializer, error) {
 	ssMap := map[string]*structuralschema.Structural{}
 	ss, err := v.StructuralSchema()
 	if err != nil {
@@ -86,7 +230,7 @@ func (v *validatorEntry) Decoder(gvk schema.GroupVersionKind) (runtime.Negotiate
 
 	preserve, _ := v.Extensions.GetBool("x-kubernetes-preserve-unknown-fields")
 	return unstructuredNegotiatedSerializer{
-		typer:                 v.ObjectTyper(gvk),
+		typer:                 v.objectTyper(gvk.GroupVersion()),
 		creator:               unstructuredCreator{},
 		converter:             safeConverter,
 		structuralSchemas:     ssMap,
@@ -95,34 +239,53 @@ func (v *validatorEntry) Decoder(gvk schema.GroupVersionKind) (runtime.Negotiate
 	}, nil
 }
 
-func (v *validatorEntry) StructuralSchema() (*structuralschema.Structural, error) {
-	if v.ss == nil {
-		//!TODO: dont try to marshal a potentially recursive schema. should validate
-		// that schema (except CRD) is not recursive before moving foreward
-		jsonText, err := json.Marshal(v.Schema)
-		if err != nil {
-			return nil, err
-		}
+// Strategy returns the strategy validating objects of the GVK against the
+// schema
+func (v *validatorEntry) Strategy(gvk schema.GroupVersionKind) (rest.RESTCreateStrategy, error) {
+	ss, err := v.StructuralSchema()
+	if err != nil {
+		return nil, err
+	}
+	schemaValidator := v.SchemaValidator()
 
-		propsdv1 := apiextensionsv1.JSONSchemaProps{}
-		if err := json.Unmarshal(jsonText, &propsdv1); err != nil {
-			return nil, err
-		}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if strat, ok := v.strategies[gvk]; ok {
+		return strat, nil
+	}
+	strat := customresource.NewStrategy(v.objectTyper(gvk.GroupVersion()), v.namespaceScoped, gvk, schemaValidator, nil,
+		ss,
+		nil, nil, nil)
+	v.strategies[gvk] = strat
+	return strat, nil
+}
 
-		propsd := apiextensions.JSONSchemaProps{}
-		if err := apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(&propsdv1, &propsd, nil); err != nil {
-			return nil, err
+func (v *validatorEntry) StructuralSchema() (*structuralschema.Structural, error) {
+	v.ssOnce.Do(func() {
+		if v.ss == nil {
+			v.ss, v.ssErr = newStructuralSchema(v.Schema)
 		}
+	})
+	return v.ss, v.ssErr
+}
 
-		ss, err := structuralschema.NewStructural(&propsd)
-		if err != nil {
-			return nil, err
-		}
+func newStructuralSchema(sch *spec.Schema) (*structuralschema.Structural, error) {
+	jsonText, err := json.Marshal(sch)
+	if err != nil {
+		return nil, err
+	}
 
-		v.ss = ss
+	propsdv1 := apiextensionsv1.JSONSchemaProps{}
+	if err := json.Unmarshal(jsonText, &propsdv1); err != nil {
+		return nil, err
+	}
+
+	propsd := apiextensions.JSONSchemaProps{}
+	if err := apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(&propsdv1, &propsd, nil); err != nil {
+		return nil, err
 	}
 
-	return v.ss, nil
+	return structuralschema.NewStructural(&propsd)
 }
 
 type basicValidatorAdapter struct {
@@ -0,0 +1,125 @@
+// Package validatortest provides assertions that objects built in Go, such as
+// those created by controllers, would be accepted by the apiserver.
+package validatortest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/openapi"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+	"sigs.k8s.io/kubectl-validate/pkg/validator"
+)
+
+// Validator asserts the validity of objects against the schemas of a client
+type Validator struct {
+	*validator.Validator
+}
+
+// New creates a Validator using the schemas of client, failing the test if it
+// cannot be created. To validate custom resources, pass a client serving the
+// schemas of their CRDs, such as openapiclient.NewLocalCRDFiles, and register
+// their types in a scheme passed with validator.WithScheme.
+func New(t testing.TB, client openapi.Client, opts ...validator.Option) *Validator {
+	t.Helper()
+	v, err := validator.New(client, opts...)
+	if err != nil {
+		t.Fatalf("failed to create validator: %v", err)
+	}
+	return &Validator{v}
+}
+
+// AssertValid checks that obj is valid, reporting its errors otherwise
+func (v *Validator) AssertValid(t testing.TB, obj runtime.Object) bool {
+	t.Helper()
+	if err := v.ValidateObject(obj); err != nil {
+		t.Errorf("expected %T to be valid: %v", obj, err)
+		return false
+	}
+	return true
+}
+
+// AssertInvalid checks that obj is invalid because of the field at the given
+// path, such as spec.replicas or spec.template.spec.containers[0].image. An
+// empty path accepts any error.
+func (v *Validator) AssertInvalid(t testing.TB, obj runtime.Object, fieldPath string) bool {
+	t.Helper()
+	err := v.ValidateObject(obj)
+	if err == nil {
+		t.Errorf("expected %T to be invalid", obj)
+		return false
+	} else if fieldPath == "" {
+		return true
+	}
+	fields := errorFields(err)
+	for _, field := range fields {
+		if field == fieldPath {
+			return true
+		}
+	}
+	t.Errorf("expected %T to be invalid at %s, got errors at %v: %v", obj, fieldPath, fields, err)
+	return false
+}
+
+// errorFields returns the paths of the fields err was reported for
+func errorFields(err error) []string {
+	var statusErr *k8serrors.StatusError
+	if !errors.As(err, &statusErr) || statusErr.ErrStatus.Details == nil {
+		return nil
+	}
+	var res []string
+	for _, cause := range statusErr.ErrStatus.Details.Causes {
+		res = append(res, cause.Field)
+	}
+	sort.Strings(res)
+	return res
+}
+
+var defaultValidator = sync.OnceValues(func() (*Validator, error) {
+	versions := make([]*version.Version, 0, len(openapiclient.HardcodedBuiltinVersions))
+	for _, v := range openapiclient.HardcodedBuiltinVersions {
+		versions = append(versions, version.MustParseGeneric(v))
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].LessThan(versions[j]) })
+	latest := versions[len(versions)-1]
+
+	v, err := validator.New(openapiclient.NewHardcodedBuiltins(fmt.Sprintf("%d.%d", latest.Major(), latest.Minor())))
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{v}, nil
+})
+
+// Default returns the validator used by AssertValid and AssertInvalid, which
+// checks native types against the schemas of the latest Kubernetes version
+// built in. For the checks the apiserver performs beyond their schemas, pass
+// validator.WithNativeValidation(nativevalidation.Validate) of the native
+// module to New.
+func Default(t testing.TB) *Validator {
+	t.Helper()
+	v, err := defaultValidator()
+	if err != nil {
+		t.Fatalf("failed to create validator: %v", err)
+	}
+	return v
+}
+
+// AssertValid checks that the native object is valid, reporting its errors
+// otherwise. See Default.
+func AssertValid(t testing.TB, obj runtime.Object) bool {
+	t.Helper()
+	return Default(t).AssertValid(t, obj)
+}
+
+// AssertInvalid checks that the native object is invalid because of the field
+// at the given path. See Default.
+func AssertInvalid(t testing.TB, obj runtime.Object, fieldPath string) bool {
+	t.Helper()
+	return Default(t).AssertInvalid(t, obj, fieldPath)
+}
@@ -0,0 +1,94 @@
+package validatortest_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/kubectl-validate/pkg/openapiclient"
+	"sigs.k8s.io/kubectl-validate/pkg/validator/validatortest"
+)
+
+// recorder records the errors reported to it instead of failing the test
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func deployment() *appsv1.Deployment {
+	labels := map[string]string{"app": "web"}
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To[int32](3),
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "web", Image: "nginx"}},
+				},
+			},
+		},
+	}
+}
+
+// duplicatePorts returns a deployment whose container lists a port twice,
+// which its schema rejects
+func duplicatePorts() *appsv1.Deployment {
+	obj := deployment()
+	obj.Spec.Template.Spec.Containers[0].Ports = []corev1.ContainerPort{{ContainerPort: 80}, {ContainerPort: 80}}
+	return obj
+}
+
+func TestAssertValid(t *testing.T) {
+	obj := deployment()
+	validatortest.AssertValid(t, obj)
+	// The object is left as is
+	assert.Empty(t, obj.Kind)
+	assert.Empty(t, obj.Spec.Template.Spec.RestartPolicy)
+
+	r := &recorder{TB: t}
+	assert.False(t, validatortest.AssertValid(r, duplicatePorts()))
+	assert.Len(t, r.errors, 1)
+}
+
+func TestAssertInvalid(t *testing.T) {
+	invalid := duplicatePorts()
+	validatortest.AssertInvalid(t, invalid, "spec.template.spec.containers[0].ports[1]")
+	validatortest.AssertInvalid(t, invalid, "")
+
+	// Checks beyond the schema are those of the native module
+	mismatchedSelector := deployment()
+	mismatchedSelector.Spec.Selector.MatchLabels = map[string]string{"app": "api"}
+	validatortest.AssertValid(t, mismatchedSelector)
+
+	r := &recorder{TB: t}
+	assert.False(t, validatortest.AssertInvalid(r, deployment(), "spec.replicas"))
+	assert.False(t, validatortest.AssertInvalid(r, invalid, "spec.paused"))
+	assert.Len(t, r.errors, 2)
+}
+
+func TestCustomResources(t *testing.T) {
+	v := validatortest.New(t, openapiclient.NewLocalCRDFiles(os.DirFS("../../../testcases/crds")))
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "stable.example.com/v1",
+		"kind":       "CELBasic",
+		"metadata":   map[string]interface{}{"name": "positive", "namespace": "default"},
+		"value":      int64(2),
+	}}
+	v.AssertValid(t, obj)
+
+	obj.Object["value"] = int64(-1)
+	v.AssertInvalid(t, obj, "value")
+}